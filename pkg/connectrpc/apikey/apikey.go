@@ -0,0 +1,136 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// Config holds configuration for the API key interceptor.
+type Config struct {
+	// Header is the request header carrying the API key ("<id>.<secret>").
+	// Default: "X-API-Key"
+	Header string `koanf:"header"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+func DefaultConfig() Config {
+	return Config{
+		Header: "X-API-Key",
+	}
+}
+
+// Validate checks that all required fields are set.
+// Returns nil if configuration is valid.
+func (c Config) Validate() error {
+	if c.Header == "" {
+		return ErrHeaderRequired
+	}
+	return nil
+}
+
+// NewInterceptor creates a server-side interceptor that authenticates requests
+// by API key. The key ID is looked up in store, the secret is verified against
+// the stored hash, and claims with the key's service identity, tenant and scopes
+// are injected into the context using ctxutil.WithClaims.
+// Returns error if cfg is invalid or store is nil.
+func NewInterceptor(cfg Config, store Store) (connect.Interceptor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create apikey interceptor: %w", err)
+	}
+	if store == nil {
+		return nil, fmt.Errorf("create apikey interceptor: %w", ErrStoreRequired)
+	}
+	return &interceptor{
+		header: cfg.Header,
+		store:  store,
+		now:    time.Now,
+	}, nil
+}
+
+type interceptor struct {
+	header string
+	store  Store
+	now    func() time.Time
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+
+		ctx, err := i.authenticate(ctx, req.Header())
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		ctx, err := i.authenticate(ctx, conn.RequestHeader())
+		if err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *interceptor) authenticate(ctx context.Context, headers http.Header) (context.Context, error) {
+	raw := headers.Get(i.header)
+	if raw == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, ErrMissingKey)
+	}
+
+	id, secret, ok := strings.Cut(raw, ".")
+	if !ok || id == "" || secret == "" {
+		return nil, connect.NewError(connect.CodeUnauthenticated, ErrInvalidKeyFormat)
+	}
+
+	key, err := i.store.Find(ctx, id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, connect.NewError(connect.CodeUnauthenticated, ErrInvalidKey)
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "api key lookup failed", "key_id", id, "error", err)
+		return nil, connect.NewError(connect.CodeUnavailable, ErrLookupFailed)
+	}
+
+	match, err := verify(secret, key.Hash)
+	if err != nil {
+		slog.WarnContext(ctx, "api key hash invalid", "key_id", id, "error", err)
+		return nil, connect.NewError(connect.CodeUnauthenticated, ErrInvalidKey)
+	}
+	if !match {
+		return nil, connect.NewError(connect.CodeUnauthenticated, ErrInvalidKey)
+	}
+
+	now := i.now()
+	if err := key.validAt(now); err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+
+	if err := i.store.TouchLastUsed(ctx, id, now); err != nil {
+		slog.WarnContext(ctx, "api key last-used update failed", "key_id", id, "error", err)
+	}
+
+	return ctxutil.WithClaims(ctx, ctxutil.Claims{
+		UserID:      key.ServiceID,
+		TenantID:    key.TenantID,
+		Permissions: key.Scopes,
+	}), nil
+}
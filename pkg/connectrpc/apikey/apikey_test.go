@@ -0,0 +1,330 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr error
+	}{
+		{
+			name:    "default config",
+			cfg:     DefaultConfig(),
+			wantErr: nil,
+		},
+		{
+			name:    "missing header",
+			cfg:     Config{},
+			wantErr: ErrHeaderRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.cfg.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInterceptor_Validation(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewInterceptor(Config{}, NewInMemoryStore()); !errors.Is(err, ErrHeaderRequired) {
+		t.Errorf("NewInterceptor() error = %v, want %v", err, ErrHeaderRequired)
+	}
+	if _, err := NewInterceptor(DefaultConfig(), nil); !errors.Is(err, ErrStoreRequired) {
+		t.Errorf("NewInterceptor() error = %v, want %v", err, ErrStoreRequired)
+	}
+}
+
+func TestInterceptor_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	active := mustGenerate(t, SchemeSHA256)
+	argon := mustGenerate(t, SchemeArgon2id)
+	expired := mustGenerate(t, SchemeSHA256)
+	future := mustGenerate(t, SchemeSHA256)
+
+	store := NewInMemoryStore(
+		Key{ID: active.ID, Hash: active.Hash, ServiceID: "billing", TenantID: "t1", Scopes: []string{"invoices:read"}},
+		Key{ID: argon.ID, Hash: argon.Hash, ServiceID: "reports"},
+		Key{ID: expired.ID, Hash: expired.Hash, ServiceID: "old", ExpiresAt: now},
+		Key{ID: future.ID, Hash: future.Hash, ServiceID: "new", NotBefore: now.Add(time.Minute)},
+	)
+	i := newTestInterceptor(t, store, now)
+
+	tests := []struct {
+		name     string
+		key      string
+		wantErr  error
+		wantCode connect.Code
+		want     ctxutil.Claims
+	}{
+		{
+			name: "valid sha256 key",
+			key:  active.Plaintext,
+			want: ctxutil.Claims{UserID: "billing", TenantID: "t1", Permissions: []string{"invoices:read"}},
+		},
+		{
+			name: "valid argon2id key",
+			key:  argon.Plaintext,
+			want: ctxutil.Claims{UserID: "reports"},
+		},
+		{
+			name:     "missing key",
+			key:      "",
+			wantErr:  ErrMissingKey,
+			wantCode: connect.CodeUnauthenticated,
+		},
+		{
+			name:     "no separator",
+			key:      "abcdef",
+			wantErr:  ErrInvalidKeyFormat,
+			wantCode: connect.CodeUnauthenticated,
+		},
+		{
+			name:     "unknown id",
+			key:      "unknown.secret",
+			wantErr:  ErrInvalidKey,
+			wantCode: connect.CodeUnauthenticated,
+		},
+		{
+			name:     "wrong secret",
+			key:      active.ID + ".wrong",
+			wantErr:  ErrInvalidKey,
+			wantCode: connect.CodeUnauthenticated,
+		},
+		{
+			name:     "expired key",
+			key:      expired.Plaintext,
+			wantErr:  ErrKeyExpired,
+			wantCode: connect.CodeUnauthenticated,
+		},
+		{
+			name:     "not yet valid key",
+			key:      future.Plaintext,
+			wantErr:  ErrKeyNotYetValid,
+			wantCode: connect.CodeUnauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			headers := http.Header{}
+			if tt.key != "" {
+				headers.Set("X-API-Key", tt.key)
+			}
+
+			ctx, err := i.authenticate(context.Background(), headers)
+			if tt.wantErr != nil {
+				var connectErr *connect.Error
+				if !errors.As(err, &connectErr) {
+					t.Fatalf("expected connect.Error, got %T", err)
+				}
+				if connectErr.Code() != tt.wantCode {
+					t.Errorf("code = %v, want %v", connectErr.Code(), tt.wantCode)
+				}
+				if !errors.Is(connectErr.Unwrap(), tt.wantErr) {
+					t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticate() error = %v", err)
+			}
+
+			claims, ok := ctxutil.GetClaims(ctx)
+			if !ok {
+				t.Fatal("claims not found in context")
+			}
+			if claims.UserID != tt.want.UserID || claims.TenantID != tt.want.TenantID {
+				t.Errorf("claims = %+v, want %+v", claims, tt.want)
+			}
+			if len(claims.Permissions) != len(tt.want.Permissions) {
+				t.Errorf("Permissions = %v, want %v", claims.Permissions, tt.want.Permissions)
+			}
+		})
+	}
+}
+
+func TestInterceptor_RotationOverlap(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	oldKey := mustGenerate(t, SchemeSHA256)
+	newKey := mustGenerate(t, SchemeSHA256)
+
+	ctx := context.Background()
+	store := NewInMemoryStore(Key{ID: oldKey.ID, Hash: oldKey.Hash, ServiceID: "billing"})
+	i := newTestInterceptor(t, store, now)
+
+	// Rotate: issue the new key and keep the old one valid for another hour.
+	if err := store.Create(ctx, Key{ID: newKey.ID, Hash: newKey.Hash, ServiceID: "billing"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := store.Expire(ctx, oldKey.ID, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Expire() error = %v", err)
+	}
+
+	for _, key := range []string{oldKey.Plaintext, newKey.Plaintext} {
+		if _, err := i.authenticate(ctx, http.Header{"X-Api-Key": {key}}); err != nil {
+			t.Errorf("authenticate() during overlap error = %v", err)
+		}
+	}
+
+	i.now = func() time.Time { return now.Add(time.Hour) }
+	if _, err := i.authenticate(ctx, http.Header{"X-Api-Key": {oldKey.Plaintext}}); !errors.Is(err, ErrKeyExpired) {
+		t.Errorf("authenticate() old key after overlap error = %v, want %v", err, ErrKeyExpired)
+	}
+	if _, err := i.authenticate(ctx, http.Header{"X-Api-Key": {newKey.Plaintext}}); err != nil {
+		t.Errorf("authenticate() new key after overlap error = %v", err)
+	}
+
+	if err := store.Create(ctx, Key{ID: newKey.ID}); !errors.Is(err, ErrKeyExists) {
+		t.Errorf("Create() duplicate error = %v, want %v", err, ErrKeyExists)
+	}
+}
+
+func TestInterceptor_RecordsLastUsed(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	g := mustGenerate(t, SchemeSHA256)
+	store := NewInMemoryStore(Key{ID: g.ID, Hash: g.Hash, ServiceID: "billing"})
+	i := newTestInterceptor(t, store, now)
+
+	wrapped := i.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+	if _, err := wrapped(context.Background(), &mockRequest{header: http.Header{"X-Api-Key": {g.Plaintext}}}); err != nil {
+		t.Fatalf("WrapUnary() error = %v", err)
+	}
+
+	k, err := store.Find(context.Background(), g.ID)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if !k.LastUsedAt.Equal(now) {
+		t.Errorf("LastUsedAt = %v, want %v", k.LastUsedAt, now)
+	}
+}
+
+func TestInterceptor_StoreFailure(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, failingStore{}, time.Now())
+
+	_, err := i.authenticate(context.Background(), http.Header{"X-Api-Key": {"id.secret"}})
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		t.Fatalf("expected connect.Error, got %T", err)
+	}
+	if connectErr.Code() != connect.CodeUnavailable {
+		t.Errorf("code = %v, want %v", connectErr.Code(), connect.CodeUnavailable)
+	}
+	if !errors.Is(connectErr.Unwrap(), ErrLookupFailed) {
+		t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), ErrLookupFailed)
+	}
+}
+
+func TestInterceptor_SkipsClientAndStreams(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, NewInMemoryStore(), time.Now())
+
+	wrapped := i.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+	if _, err := wrapped(context.Background(), &mockRequest{isClient: true}); err != nil {
+		t.Errorf("client call error = %v", err)
+	}
+
+	stream := i.WrapStreamingHandler(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		return nil
+	})
+	if err := stream(context.Background(), &mockStreamingConn{}); connect.CodeOf(err) != connect.CodeUnauthenticated {
+		t.Errorf("stream code = %v, want %v", connect.CodeOf(err), connect.CodeUnauthenticated)
+	}
+}
+
+func newTestInterceptor(t *testing.T, store Store, now time.Time) *interceptor {
+	t.Helper()
+
+	ic, err := NewInterceptor(DefaultConfig(), store)
+	if err != nil {
+		t.Fatalf("NewInterceptor() error = %v", err)
+	}
+	i := ic.(*interceptor)
+	i.now = func() time.Time { return now }
+	return i
+}
+
+func mustGenerate(t *testing.T, scheme Scheme) Generated {
+	t.Helper()
+
+	g, err := Generate(scheme)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	return g
+}
+
+type failingStore struct{}
+
+func (failingStore) Find(context.Context, string) (Key, error) {
+	return Key{}, errors.New("connection refused")
+}
+
+func (failingStore) TouchLastUsed(context.Context, string, time.Time) error {
+	return errors.New("connection refused")
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	header   http.Header
+	isClient bool
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return connect.Spec{Procedure: "/test.Service/Method", IsClient: r.isClient}
+}
+
+func (r *mockRequest) Header() http.Header {
+	return r.header
+}
+
+type mockResponse struct {
+	connect.AnyResponse
+}
+
+type mockStreamingConn struct {
+	connect.StreamingHandlerConn
+}
+
+func (c *mockStreamingConn) Spec() connect.Spec {
+	return connect.Spec{Procedure: "/test.Service/Stream"}
+}
+
+func (c *mockStreamingConn) RequestHeader() http.Header {
+	return http.Header{}
+}
@@ -0,0 +1,40 @@
+// Package apikey provides API key authentication for Connect RPC services.
+package apikey
+
+import "errors"
+
+// Sentinel errors for API key authentication.
+var (
+	// ErrMissingKey is returned when the request has no API key header.
+	ErrMissingKey = errors.New("missing api key")
+
+	// ErrInvalidKeyFormat is returned when the API key is not of the form "<id>.<secret>".
+	ErrInvalidKeyFormat = errors.New("invalid api key format")
+
+	// ErrInvalidKey is returned when the key ID is unknown or the secret does not match.
+	ErrInvalidKey = errors.New("invalid api key")
+
+	// ErrKeyExpired is returned when the key is past its expiry time.
+	ErrKeyExpired = errors.New("api key has expired")
+
+	// ErrKeyNotYetValid is returned when the key's validity has not started yet.
+	ErrKeyNotYetValid = errors.New("api key not yet valid")
+
+	// ErrKeyNotFound is returned by a Store when no key has the given ID.
+	ErrKeyNotFound = errors.New("api key not found")
+
+	// ErrKeyExists is returned by a Store when a key with the same ID already exists.
+	ErrKeyExists = errors.New("api key already exists")
+
+	// ErrLookupFailed is returned when the key store cannot be queried.
+	ErrLookupFailed = errors.New("api key lookup failed")
+
+	// ErrUnsupportedScheme is returned when a stored hash uses an unknown scheme.
+	ErrUnsupportedScheme = errors.New("unsupported hash scheme")
+
+	// ErrHeaderRequired is returned when Config.Header is empty.
+	ErrHeaderRequired = errors.New("header is required")
+
+	// ErrStoreRequired is returned when no Store is passed to NewInterceptor.
+	ErrStoreRequired = errors.New("store is required")
+)
@@ -0,0 +1,184 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Scheme identifies how a key secret is hashed at rest.
+type Scheme string
+
+const (
+	// SchemeSHA256 stores "sha256:<hex>". Keys are high-entropy random values,
+	// so a fast hash is sufficient and keeps verification cheap on the hot path.
+	SchemeSHA256 Scheme = "sha256"
+
+	// SchemeArgon2id stores a PHC-formatted argon2id hash. Use it when keys
+	// may be low-entropy or policy requires a slow hash; it costs several
+	// milliseconds per request.
+	SchemeArgon2id Scheme = "argon2id"
+)
+
+// Argon2id parameters (OWASP recommended minimum).
+const (
+	argon2Memory  = 19 * 1024
+	argon2Time    = 2
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// Key is a stored API key. The plaintext secret is never stored.
+type Key struct {
+	// ID identifies the key. It is the part before "." in the presented key.
+	ID string
+
+	// Hash is the encoded hash of the secret (see Scheme).
+	Hash string
+
+	// ServiceID is the calling service's identity, exposed as ctxutil.Claims.UserID.
+	ServiceID string
+
+	// TenantID is exposed as ctxutil.Claims.TenantID.
+	TenantID string
+
+	// Scopes are exposed as ctxutil.Claims.Permissions.
+	Scopes []string
+
+	// NotBefore is when the key becomes valid. Zero means immediately.
+	NotBefore time.Time
+
+	// ExpiresAt is when the key stops being valid. Zero means never.
+	// Rotation issues a new key and sets ExpiresAt on the old one, so both
+	// are accepted during the overlap.
+	ExpiresAt time.Time
+
+	// LastUsedAt is updated on every successful authentication.
+	LastUsedAt time.Time
+}
+
+// validAt reports whether the key is within its validity window at now.
+func (k Key) validAt(now time.Time) error {
+	if !k.NotBefore.IsZero() && now.Before(k.NotBefore) {
+		return ErrKeyNotYetValid
+	}
+	if !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt) {
+		return ErrKeyExpired
+	}
+	return nil
+}
+
+// Generated is a freshly generated API key.
+type Generated struct {
+	// ID is the key ID to store in Key.ID.
+	ID string
+
+	// Hash is the encoded hash to store in Key.Hash.
+	Hash string
+
+	// Plaintext is the full key ("<id>.<secret>") to hand to the client once.
+	Plaintext string
+}
+
+// Generate creates a random API key and hashes its secret with scheme.
+func Generate(scheme Scheme) (Generated, error) {
+	id := make([]byte, 8)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return Generated{}, fmt.Errorf("generate key id: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return Generated{}, fmt.Errorf("generate key secret: %w", err)
+	}
+
+	g := Generated{ID: hex.EncodeToString(id)}
+	encodedSecret := base64.RawURLEncoding.EncodeToString(secret)
+
+	hash, err := Hash(encodedSecret, scheme)
+	if err != nil {
+		return Generated{}, err
+	}
+	g.Hash = hash
+	g.Plaintext = g.ID + "." + encodedSecret
+	return g, nil
+}
+
+// Hash encodes secret with scheme for storage in Key.Hash.
+func Hash(secret string, scheme Scheme) (string, error) {
+	switch scheme {
+	case SchemeSHA256:
+		sum := sha256.Sum256([]byte(secret))
+		return string(SchemeSHA256) + ":" + hex.EncodeToString(sum[:]), nil
+	case SchemeArgon2id:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("generate salt: %w", err)
+		}
+		sum := argon2.IDKey([]byte(secret), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(sum),
+		), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedScheme, scheme)
+	}
+}
+
+// verify reports whether secret matches the encoded hash in constant time.
+func verify(secret, encoded string) (bool, error) {
+	if hexSum, ok := strings.CutPrefix(encoded, string(SchemeSHA256)+":"); ok {
+		want, err := hex.DecodeString(hexSum)
+		if err != nil {
+			return false, fmt.Errorf("decode sha256 hash: %w", err)
+		}
+		got := sha256.Sum256([]byte(secret))
+		return subtle.ConstantTimeCompare(got[:], want) == 1, nil
+	}
+
+	if strings.HasPrefix(encoded, "$argon2id$") {
+		return verifyArgon2id(secret, encoded)
+	}
+
+	return false, ErrUnsupportedScheme
+}
+
+// verifyArgon2id checks secret against a PHC string
+// "$argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>".
+func verifyArgon2id(secret, encoded string) (bool, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, fmt.Errorf("decode argon2id hash: %w", ErrUnsupportedScheme)
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("decode argon2id version %q: %w", parts[2], ErrUnsupportedScheme)
+	}
+
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, fmt.Errorf("decode argon2id params: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("decode argon2id salt: %w", err)
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("decode argon2id hash: %w", err)
+	}
+
+	got := argon2.IDKey([]byte(secret), salt, iterations, memory, threads, uint32(len(want)))
+	return subtle.ConstantTimeCompare(got, want) == 1, nil
+}
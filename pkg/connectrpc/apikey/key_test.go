@@ -0,0 +1,72 @@
+package apikey
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	for _, scheme := range []Scheme{SchemeSHA256, SchemeArgon2id} {
+		t.Run(string(scheme), func(t *testing.T) {
+			t.Parallel()
+
+			g, err := Generate(scheme)
+			if err != nil {
+				t.Fatalf("Generate() error = %v", err)
+			}
+
+			id, secret, ok := strings.Cut(g.Plaintext, ".")
+			if !ok || id != g.ID {
+				t.Fatalf("Plaintext = %q, want prefix %q", g.Plaintext, g.ID+".")
+			}
+			if strings.Contains(g.Hash, secret) {
+				t.Error("Hash contains the plaintext secret")
+			}
+
+			match, err := verify(secret, g.Hash)
+			if err != nil || !match {
+				t.Errorf("verify(secret) = %v, %v, want true, nil", match, err)
+			}
+			match, err = verify(secret+"x", g.Hash)
+			if err != nil || match {
+				t.Errorf("verify(wrong secret) = %v, %v, want false, nil", match, err)
+			}
+		})
+	}
+}
+
+func TestHash_UnsupportedScheme(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Hash("secret", "md5"); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Errorf("Hash() error = %v, want %v", err, ErrUnsupportedScheme)
+	}
+}
+
+func TestVerify_InvalidEncoding(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "unknown scheme", encoded: "md5:abc"},
+		{name: "bad sha256 hex", encoded: "sha256:zz"},
+		{name: "truncated argon2id", encoded: "$argon2id$v=19$m=1,t=1,p=1$c2FsdA"},
+		{name: "wrong argon2 version", encoded: "$argon2id$v=16$m=1,t=1,p=1$c2FsdA$aGFzaA"},
+		{name: "bad argon2id params", encoded: "$argon2id$v=19$x$c2FsdA$aGFzaA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			match, err := verify("secret", tt.encoded)
+			if err == nil || match {
+				t.Errorf("verify() = %v, %v, want false and an error", match, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package apikey
+
+import (
+	"context"
+	"time"
+)
+
+// Store looks up API keys. Implementations must be safe for concurrent use.
+type Store interface {
+	// Find returns the key with the given ID, or ErrKeyNotFound.
+	Find(ctx context.Context, id string) (Key, error)
+
+	// TouchLastUsed records that the key was used at the given time.
+	TouchLastUsed(ctx context.Context, id string, at time.Time) error
+}
@@ -0,0 +1,81 @@
+package apikey
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// InMemoryStore implements Store with a map. Intended for tests and static key sets.
+type InMemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]Key
+}
+
+// NewInMemoryStore creates a store holding the given keys.
+func NewInMemoryStore(keys ...Key) *InMemoryStore {
+	s := &InMemoryStore{keys: make(map[string]Key, len(keys))}
+	for _, k := range keys {
+		s.keys[k.ID] = k
+	}
+	return s
+}
+
+// Find returns the key with the given ID, or ErrKeyNotFound.
+func (s *InMemoryStore) Find(_ context.Context, id string) (Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	k.Scopes = slices.Clone(k.Scopes)
+	return k, nil
+}
+
+// TouchLastUsed records that the key was used at the given time.
+func (s *InMemoryStore) TouchLastUsed(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	k.LastUsedAt = at
+	s.keys[id] = k
+	return nil
+}
+
+// Create adds a new key. Returns ErrKeyExists if the ID is taken.
+func (s *InMemoryStore) Create(_ context.Context, k Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[k.ID]; ok {
+		return ErrKeyExists
+	}
+	k.Scopes = slices.Clone(k.Scopes)
+	s.keys[k.ID] = k
+	return nil
+}
+
+// Expire sets the key's expiry. During rotation, pass a time in the future
+// so the old key stays valid alongside its replacement.
+func (s *InMemoryStore) Expire(_ context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	k.ExpiresAt = at
+	s.keys[id] = k
+	return nil
+}
+
+// compile-time check
+var _ Store = (*InMemoryStore)(nil)
@@ -0,0 +1,138 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/deepworx/go-utils/pkg/postgres"
+)
+
+// DefaultTable is the table used by PgStore when none is given.
+const DefaultTable = "api_keys"
+
+// PgStore implements Store using a PostgreSQL table.
+type PgStore struct {
+	pool  *pgxpool.Pool
+	table string
+}
+
+// NewPgStore creates a Store backed by the given pool.
+// If table is empty, DefaultTable is used; it may be schema-qualified
+// ("schema.table"). Call CreateTable to create it.
+func NewPgStore(pool *pgxpool.Pool, table string) *PgStore {
+	if table == "" {
+		table = DefaultTable
+	}
+	return &PgStore{
+		pool:  pool,
+		table: postgres.QuoteTable(table),
+	}
+}
+
+// CreateTable creates the key table if it does not exist.
+func (s *PgStore) CreateTable(ctx context.Context) error {
+	_, err := s.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+		id           TEXT PRIMARY KEY,
+		hash         TEXT NOT NULL,
+		service_id   TEXT NOT NULL,
+		tenant_id    TEXT NOT NULL DEFAULT '',
+		scopes       TEXT[] NOT NULL DEFAULT '{}',
+		not_before   TIMESTAMPTZ,
+		expires_at   TIMESTAMPTZ,
+		last_used_at TIMESTAMPTZ,
+		created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("create api key table: %w", err)
+	}
+	return nil
+}
+
+// Find returns the key with the given ID, or ErrKeyNotFound.
+func (s *PgStore) Find(ctx context.Context, id string) (Key, error) {
+	var (
+		k                             Key
+		notBefore, expiresAt, lastUse *time.Time
+	)
+	err := s.pool.QueryRow(ctx, `SELECT id, hash, service_id, tenant_id, scopes, not_before, expires_at, last_used_at
+		FROM `+s.table+` WHERE id = $1`, id).
+		Scan(&k.ID, &k.Hash, &k.ServiceID, &k.TenantID, &k.Scopes, &notBefore, &expiresAt, &lastUse)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Key{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return Key{}, fmt.Errorf("find api key: %w", err)
+	}
+
+	k.NotBefore = deref(notBefore)
+	k.ExpiresAt = deref(expiresAt)
+	k.LastUsedAt = deref(lastUse)
+	return k, nil
+}
+
+// TouchLastUsed records that the key was used at the given time.
+func (s *PgStore) TouchLastUsed(ctx context.Context, id string, at time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE `+s.table+` SET last_used_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("touch api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+// Create inserts a new key. Returns ErrKeyExists if the ID is taken.
+func (s *PgStore) Create(ctx context.Context, k Key) error {
+	scopes := k.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	_, err := s.pool.Exec(ctx, `INSERT INTO `+s.table+` (id, hash, service_id, tenant_id, scopes, not_before, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		k.ID, k.Hash, k.ServiceID, k.TenantID, scopes, nullable(k.NotBefore), nullable(k.ExpiresAt))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return ErrKeyExists
+		}
+		return fmt.Errorf("create api key: %w", err)
+	}
+	return nil
+}
+
+// Expire sets the key's expiry. During rotation, pass a time in the future
+// so the old key stays valid alongside its replacement.
+func (s *PgStore) Expire(ctx context.Context, id string, at time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE `+s.table+` SET expires_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("expire api key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+func deref(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+func nullable(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// compile-time check
+var _ Store = (*PgStore)(nil)
@@ -0,0 +1,206 @@
+package authz
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// Match defines how a list of required values is evaluated.
+type Match string
+
+const (
+	// MatchAll requires the caller to hold every listed value.
+	MatchAll Match = "all"
+
+	// MatchAny requires the caller to hold at least one listed value.
+	MatchAny Match = "any"
+)
+
+// Policy defines the authorization requirements for a single procedure.
+type Policy struct {
+	// Procedure is the full Connect procedure name (e.g., "/pkg.Service/Method").
+	// Required.
+	Procedure string `koanf:"procedure"`
+
+	// Roles lists roles the caller must hold. All roles are required.
+	Roles []string `koanf:"roles"`
+
+	// Permissions lists permissions the caller must hold.
+	Permissions []string `koanf:"permissions"`
+
+	// PermissionMatch selects whether all or any of Permissions are required.
+	// Default: MatchAll
+	PermissionMatch Match `koanf:"permission_match"`
+
+	// MatchTenant requires the caller to have a tenant ID. If the request
+	// carries the configured tenant header, it must equal the caller's tenant.
+	MatchTenant bool `koanf:"match_tenant"`
+
+	// AllowAnonymous permits requests without claims in the context.
+	// Role, permission, and tenant requirements are skipped for such requests.
+	AllowAnonymous bool `koanf:"allow_anonymous"`
+}
+
+// Config holds configuration for the authorization interceptor.
+type Config struct {
+	// Policies is the per-procedure policy table.
+	// Procedures without a policy are denied.
+	Policies []Policy `koanf:"policies"`
+
+	// TenantHeader is the HTTP header carrying the tenant a request targets.
+	// Used by policies with MatchTenant.
+	TenantHeader string `koanf:"tenant_header"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+// Policies must be set by the caller.
+func DefaultConfig() Config {
+	return Config{
+		TenantHeader: "X-Tenant-ID",
+	}
+}
+
+// Validate checks that every policy is well-formed.
+// Returns nil if configuration is valid.
+func (c Config) Validate() error {
+	seen := make(map[string]struct{}, len(c.Policies))
+	for _, p := range c.Policies {
+		if p.Procedure == "" {
+			return ErrProcedureRequired
+		}
+		if _, ok := seen[p.Procedure]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicatePolicy, p.Procedure)
+		}
+		seen[p.Procedure] = struct{}{}
+
+		switch p.PermissionMatch {
+		case "", MatchAll, MatchAny:
+		default:
+			return fmt.Errorf("%w: %q for %s", ErrInvalidMatch, p.PermissionMatch, p.Procedure)
+		}
+	}
+	return nil
+}
+
+// NewInterceptor creates a Connect RPC interceptor that enforces the policy table
+// against ctxutil.Claims. It must run after an authentication interceptor.
+// Violations return connect.CodePermissionDenied; requests without claims
+// return connect.CodeUnauthenticated unless the policy allows anonymous access.
+// Returns error if the configuration is invalid.
+func NewInterceptor(cfg Config) (connect.Interceptor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create authz interceptor: %w", err)
+	}
+
+	tenantHeader := cfg.TenantHeader
+	if tenantHeader == "" {
+		tenantHeader = "X-Tenant-ID"
+	}
+
+	policies := make(map[string]Policy, len(cfg.Policies))
+	for _, p := range cfg.Policies {
+		if p.PermissionMatch == "" {
+			p.PermissionMatch = MatchAll
+		}
+		policies[p.Procedure] = p
+	}
+
+	return &interceptor{
+		policies:     policies,
+		tenantHeader: tenantHeader,
+	}, nil
+}
+
+type interceptor struct {
+	policies     map[string]Policy
+	tenantHeader string
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		if err := i.authorize(ctx, req.Spec().Procedure, req.Header()); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if err := i.authorize(ctx, conn.Spec().Procedure, conn.RequestHeader()); err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+func (i *interceptor) authorize(ctx context.Context, procedure string, headers http.Header) error {
+	policy, ok := i.policies[procedure]
+	if !ok {
+		return connect.NewError(connect.CodePermissionDenied, ErrNoPolicy)
+	}
+
+	claims, ok := ctxutil.GetClaims(ctx)
+	if !ok {
+		if policy.AllowAnonymous {
+			return nil
+		}
+		return connect.NewError(connect.CodeUnauthenticated, ErrMissingClaims)
+	}
+
+	for _, role := range policy.Roles {
+		if !slices.Contains(claims.Roles, role) {
+			return connect.NewError(connect.CodePermissionDenied, ErrMissingRole)
+		}
+	}
+
+	if !hasPermissions(claims.Permissions, policy.Permissions, policy.PermissionMatch) {
+		return connect.NewError(connect.CodePermissionDenied, ErrMissingPermission)
+	}
+
+	if policy.MatchTenant {
+		if claims.TenantID == "" {
+			return connect.NewError(connect.CodePermissionDenied, ErrTenantMismatch)
+		}
+		if target := headers.Get(i.tenantHeader); target != "" && target != claims.TenantID {
+			return connect.NewError(connect.CodePermissionDenied, ErrTenantMismatch)
+		}
+	}
+
+	return nil
+}
+
+// hasPermissions reports whether held satisfies required under the given match mode.
+// An empty required list is always satisfied.
+func hasPermissions(held, required []string, match Match) bool {
+	if len(required) == 0 {
+		return true
+	}
+	if match == MatchAny {
+		for _, p := range required {
+			if slices.Contains(held, p) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, p := range required {
+		if !slices.Contains(held, p) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,316 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr error
+	}{
+		{
+			name:    "empty table",
+			cfg:     Config{},
+			wantErr: nil,
+		},
+		{
+			name: "valid policies",
+			cfg: Config{Policies: []Policy{
+				{Procedure: "/test.Service/A", Roles: []string{"admin"}},
+				{Procedure: "/test.Service/B", Permissions: []string{"read"}, PermissionMatch: MatchAny},
+			}},
+			wantErr: nil,
+		},
+		{
+			name:    "missing procedure",
+			cfg:     Config{Policies: []Policy{{Roles: []string{"admin"}}}},
+			wantErr: ErrProcedureRequired,
+		},
+		{
+			name: "duplicate procedure",
+			cfg: Config{Policies: []Policy{
+				{Procedure: "/test.Service/A"},
+				{Procedure: "/test.Service/A"},
+			}},
+			wantErr: ErrDuplicatePolicy,
+		},
+		{
+			name:    "invalid match",
+			cfg:     Config{Policies: []Policy{{Procedure: "/test.Service/A", PermissionMatch: "some"}}},
+			wantErr: ErrInvalidMatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.cfg.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInterceptor_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewInterceptor(Config{Policies: []Policy{{}}})
+	if !errors.Is(err, ErrProcedureRequired) {
+		t.Errorf("NewInterceptor() error = %v, want %v", err, ErrProcedureRequired)
+	}
+}
+
+func TestInterceptor_Authorize(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, Config{Policies: []Policy{
+		{Procedure: "/test.Service/Open"},
+		{Procedure: "/test.Service/Public", AllowAnonymous: true},
+		{Procedure: "/test.Service/Admin", Roles: []string{"admin", "staff"}},
+		{Procedure: "/test.Service/ReadWrite", Permissions: []string{"read", "write"}},
+		{Procedure: "/test.Service/ReadOrWrite", Permissions: []string{"read", "write"}, PermissionMatch: MatchAny},
+		{Procedure: "/test.Service/Tenant", MatchTenant: true},
+	}})
+
+	tests := []struct {
+		name      string
+		procedure string
+		claims    *ctxutil.Claims
+		headers   http.Header
+		wantCode  connect.Code
+		wantErr   error
+	}{
+		{
+			name:      "procedure without policy is denied",
+			procedure: "/test.Service/Unknown",
+			claims:    &ctxutil.Claims{UserID: "u1", Roles: []string{"admin"}},
+			wantCode:  connect.CodePermissionDenied,
+			wantErr:   ErrNoPolicy,
+		},
+		{
+			name:      "empty policy allows authenticated caller",
+			procedure: "/test.Service/Open",
+			claims:    &ctxutil.Claims{UserID: "u1"},
+		},
+		{
+			name:      "empty policy rejects anonymous caller",
+			procedure: "/test.Service/Open",
+			wantCode:  connect.CodeUnauthenticated,
+			wantErr:   ErrMissingClaims,
+		},
+		{
+			name:      "anonymous allowed",
+			procedure: "/test.Service/Public",
+		},
+		{
+			name:      "all roles present",
+			procedure: "/test.Service/Admin",
+			claims:    &ctxutil.Claims{UserID: "u1", Roles: []string{"staff", "admin"}},
+		},
+		{
+			name:      "one role missing",
+			procedure: "/test.Service/Admin",
+			claims:    &ctxutil.Claims{UserID: "u1", Roles: []string{"admin"}},
+			wantCode:  connect.CodePermissionDenied,
+			wantErr:   ErrMissingRole,
+		},
+		{
+			name:      "all permissions present",
+			procedure: "/test.Service/ReadWrite",
+			claims:    &ctxutil.Claims{UserID: "u1", Permissions: []string{"read", "write"}},
+		},
+		{
+			name:      "all permissions required",
+			procedure: "/test.Service/ReadWrite",
+			claims:    &ctxutil.Claims{UserID: "u1", Permissions: []string{"read"}},
+			wantCode:  connect.CodePermissionDenied,
+			wantErr:   ErrMissingPermission,
+		},
+		{
+			name:      "any permission suffices",
+			procedure: "/test.Service/ReadOrWrite",
+			claims:    &ctxutil.Claims{UserID: "u1", Permissions: []string{"write"}},
+		},
+		{
+			name:      "any permission none held",
+			procedure: "/test.Service/ReadOrWrite",
+			claims:    &ctxutil.Claims{UserID: "u1", Permissions: []string{"delete"}},
+			wantCode:  connect.CodePermissionDenied,
+			wantErr:   ErrMissingPermission,
+		},
+		{
+			name:      "tenant matches header",
+			procedure: "/test.Service/Tenant",
+			claims:    &ctxutil.Claims{UserID: "u1", TenantID: "t1"},
+			headers:   http.Header{"X-Tenant-Id": {"t1"}},
+		},
+		{
+			name:      "tenant without header",
+			procedure: "/test.Service/Tenant",
+			claims:    &ctxutil.Claims{UserID: "u1", TenantID: "t1"},
+		},
+		{
+			name:      "tenant mismatch",
+			procedure: "/test.Service/Tenant",
+			claims:    &ctxutil.Claims{UserID: "u1", TenantID: "t1"},
+			headers:   http.Header{"X-Tenant-Id": {"t2"}},
+			wantCode:  connect.CodePermissionDenied,
+			wantErr:   ErrTenantMismatch,
+		},
+		{
+			name:      "caller without tenant",
+			procedure: "/test.Service/Tenant",
+			claims:    &ctxutil.Claims{UserID: "u1"},
+			wantCode:  connect.CodePermissionDenied,
+			wantErr:   ErrTenantMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = ctxutil.WithClaims(ctx, *tt.claims)
+			}
+			headers := tt.headers
+			if headers == nil {
+				headers = http.Header{}
+			}
+
+			err := i.authorize(ctx, tt.procedure, headers)
+
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("authorize() error = %v", err)
+				}
+				return
+			}
+
+			var connectErr *connect.Error
+			if !errors.As(err, &connectErr) {
+				t.Fatalf("expected connect.Error, got %T", err)
+			}
+			if connectErr.Code() != tt.wantCode {
+				t.Errorf("code = %v, want %v", connectErr.Code(), tt.wantCode)
+			}
+			if !errors.Is(connectErr.Unwrap(), tt.wantErr) {
+				t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, Config{Policies: []Policy{
+		{Procedure: "/test.Service/Admin", Roles: []string{"admin"}},
+	}})
+
+	called := false
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		called = true
+		return &mockResponse{}, nil
+	})
+
+	ctx := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "u1", Roles: []string{"user"}})
+	_, err := wrapped(ctx, &mockRequest{procedure: "/test.Service/Admin"})
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("code = %v, want %v", connect.CodeOf(err), connect.CodePermissionDenied)
+	}
+	if called {
+		t.Error("handler should not be called when authorization fails")
+	}
+}
+
+func TestInterceptor_WrapUnary_SkipsClient(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, Config{})
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+
+	_, err := wrapped(context.Background(), &mockRequest{procedure: "/test.Service/Any", isClient: true})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestInterceptor_WrapStreamingHandler(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, Config{Policies: []Policy{
+		{Procedure: "/test.Service/Stream", Permissions: []string{"stream"}},
+	}})
+
+	wrapped := i.WrapStreamingHandler(func(_ context.Context, _ connect.StreamingHandlerConn) error {
+		return nil
+	})
+
+	ctx := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "u1", Permissions: []string{"stream"}})
+	if err := wrapped(ctx, &mockStreamingConn{procedure: "/test.Service/Stream"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	ctx = ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "u1"})
+	err := wrapped(ctx, &mockStreamingConn{procedure: "/test.Service/Stream"})
+	if connect.CodeOf(err) != connect.CodePermissionDenied {
+		t.Errorf("code = %v, want %v", connect.CodeOf(err), connect.CodePermissionDenied)
+	}
+}
+
+func newTestInterceptor(t *testing.T, cfg Config) *interceptor {
+	t.Helper()
+
+	i, err := NewInterceptor(cfg)
+	if err != nil {
+		t.Fatalf("NewInterceptor() error = %v", err)
+	}
+	return i.(*interceptor)
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	procedure string
+	isClient  bool
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return connect.Spec{Procedure: r.procedure, IsClient: r.isClient}
+}
+
+func (r *mockRequest) Header() http.Header {
+	return http.Header{}
+}
+
+type mockResponse struct {
+	connect.AnyResponse
+}
+
+type mockStreamingConn struct {
+	connect.StreamingHandlerConn
+	procedure string
+}
+
+func (c *mockStreamingConn) Spec() connect.Spec {
+	return connect.Spec{Procedure: c.procedure}
+}
+
+func (c *mockStreamingConn) RequestHeader() http.Header {
+	return http.Header{}
+}
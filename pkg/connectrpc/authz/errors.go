@@ -0,0 +1,31 @@
+// Package authz provides role and permission based authorization for Connect RPC services.
+package authz
+
+import "errors"
+
+// Sentinel errors for authorization.
+var (
+	// ErrNoPolicy is returned when a procedure has no authorization policy.
+	ErrNoPolicy = errors.New("no authorization policy for procedure")
+
+	// ErrMissingClaims is returned when the request context carries no claims.
+	ErrMissingClaims = errors.New("missing authentication claims")
+
+	// ErrMissingRole is returned when the caller lacks a required role.
+	ErrMissingRole = errors.New("missing required role")
+
+	// ErrMissingPermission is returned when the caller lacks required permissions.
+	ErrMissingPermission = errors.New("missing required permission")
+
+	// ErrTenantMismatch is returned when the caller's tenant does not match the request tenant.
+	ErrTenantMismatch = errors.New("tenant mismatch")
+
+	// ErrProcedureRequired is returned when a policy has an empty procedure.
+	ErrProcedureRequired = errors.New("policy procedure is required")
+
+	// ErrDuplicatePolicy is returned when a procedure has more than one policy.
+	ErrDuplicatePolicy = errors.New("duplicate policy for procedure")
+
+	// ErrInvalidMatch is returned when a permission match mode is not "all" or "any".
+	ErrInvalidMatch = errors.New("invalid permission match mode")
+)
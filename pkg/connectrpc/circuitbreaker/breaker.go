@@ -0,0 +1,253 @@
+package circuitbreaker
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/deepworx/go-utils/pkg/grpchealth"
+)
+
+// State is the state of a Breaker.
+type State int
+
+// Breaker states.
+const (
+	// StateClosed lets all calls through and tracks their failure rate.
+	StateClosed State = iota
+
+	// StateHalfOpen lets a limited number of probe calls through.
+	StateHalfOpen
+
+	// StateOpen fails all calls fast.
+	StateOpen
+)
+
+// String returns the state name used in metrics and logs.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// windowBuckets is the number of buckets the rolling window is divided into.
+const windowBuckets = 10
+
+// outcome classifies a finished call.
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+	outcomeIgnored
+)
+
+// settings are shared by all breakers of an interceptor.
+type settings struct {
+	window           time.Duration
+	failureRate      float64
+	minRequests      int
+	openTimeout      time.Duration
+	halfOpenRequests int
+
+	transitions metric.Int64Counter
+	now         func() time.Time
+}
+
+// Breaker is the circuit breaker for a single key.
+// It implements grpchealth.HealthChecker, reporting unhealthy while open.
+type Breaker struct {
+	key      string
+	settings *settings
+
+	mu    sync.Mutex
+	state State
+
+	// generation increments on every state change, so outcomes of calls
+	// started in an earlier state are ignored.
+	generation uint64
+	openedAt   time.Time
+	window     window
+	probes     int
+	successes  int
+}
+
+func newBreaker(key string, s *settings) *Breaker {
+	return &Breaker{
+		key:      key,
+		settings: s,
+		window:   window{width: max(s.window/windowBuckets, 1)},
+	}
+}
+
+// Key returns the procedure or host guarded by the breaker.
+func (b *Breaker) Key() string {
+	return b.key
+}
+
+// State returns the current state. An open breaker whose OpenTimeout has
+// passed reports StateHalfOpen, since the next call will probe.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.settings.now().Sub(b.openedAt) >= b.settings.openTimeout {
+		return StateHalfOpen
+	}
+	return b.state
+}
+
+// Check implements grpchealth.HealthChecker. It returns false while the breaker is open.
+func (b *Breaker) Check(_ context.Context) bool {
+	return b.State() != StateOpen
+}
+
+// allow reports whether a call may proceed and returns the generation its
+// outcome must be recorded with.
+func (b *Breaker) allow(ctx context.Context) (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.settings.now()
+	if b.state == StateOpen {
+		if now.Sub(b.openedAt) < b.settings.openTimeout {
+			return 0, false
+		}
+		b.transition(ctx, StateHalfOpen, now)
+	}
+	if b.state == StateHalfOpen {
+		if b.probes >= b.settings.halfOpenRequests {
+			return 0, false
+		}
+		b.probes++
+	}
+	return b.generation, true
+}
+
+// record updates the breaker with the outcome of a call allowed in generation.
+func (b *Breaker) record(ctx context.Context, generation uint64, result outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	now := b.settings.now()
+	switch b.state {
+	case StateClosed:
+		if result == outcomeIgnored {
+			return
+		}
+		b.window.add(now, result == outcomeFailure)
+		if result == outcomeFailure && b.tripped(now) {
+			b.transition(ctx, StateOpen, now)
+		}
+	case StateHalfOpen:
+		switch result {
+		case outcomeIgnored:
+			b.probes--
+		case outcomeFailure:
+			b.transition(ctx, StateOpen, now)
+		case outcomeSuccess:
+			b.successes++
+			if b.successes >= b.settings.halfOpenRequests {
+				b.transition(ctx, StateClosed, now)
+			}
+		}
+	}
+}
+
+// tripped reports whether the window's failure rate should open the breaker.
+func (b *Breaker) tripped(now time.Time) bool {
+	total, failures := b.window.counts(now)
+	return total >= b.settings.minRequests &&
+		float64(failures) >= b.settings.failureRate*float64(total)
+}
+
+// transition moves the breaker to state, resetting its counters, and reports
+// the change. Callers must hold b.mu.
+func (b *Breaker) transition(ctx context.Context, to State, now time.Time) {
+	from := b.state
+	total, failures := b.window.counts(now)
+
+	b.state = to
+	b.generation++
+	b.window.reset()
+	b.probes = 0
+	b.successes = 0
+	if to == StateOpen {
+		b.openedAt = now
+	}
+
+	b.settings.transitions.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("key", b.key),
+		attribute.String("from", from.String()),
+		attribute.String("to", to.String()),
+	))
+
+	level := slog.LevelInfo
+	if to == StateOpen {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "circuit breaker state changed",
+		"key", b.key,
+		"from", from.String(),
+		"to", to.String(),
+		"requests", total,
+		"failures", failures,
+	)
+}
+
+// window counts calls and failures over a rolling period of windowBuckets buckets.
+type window struct {
+	width   time.Duration
+	buckets [windowBuckets]bucket
+}
+
+type bucket struct {
+	epoch    int64
+	total    int
+	failures int
+}
+
+func (w *window) add(now time.Time, failed bool) {
+	epoch := now.UnixNano() / int64(w.width)
+	b := &w.buckets[epoch%windowBuckets]
+	if b.epoch != epoch {
+		*b = bucket{epoch: epoch}
+	}
+	b.total++
+	if failed {
+		b.failures++
+	}
+}
+
+func (w *window) counts(now time.Time) (total, failures int) {
+	epoch := now.UnixNano() / int64(w.width)
+	for _, b := range w.buckets {
+		if epoch-b.epoch < windowBuckets {
+			total += b.total
+			failures += b.failures
+		}
+	}
+	return total, failures
+}
+
+func (w *window) reset() {
+	w.buckets = [windowBuckets]bucket{}
+}
+
+// compile-time check
+var _ grpchealth.HealthChecker = (*Breaker)(nil)
@@ -0,0 +1,316 @@
+// Package circuitbreaker provides a circuit breaking Connect RPC client interceptor.
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const meterName = "github.com/deepworx/go-utils/pkg/connectrpc/circuitbreaker"
+
+// Breaker keys select what a breaker guards.
+const (
+	// KeyProcedure keeps one breaker per procedure, e.g. "/pkg.Service/Method".
+	KeyProcedure = "procedure"
+
+	// KeyHost keeps one breaker per downstream host, e.g. "users.internal:8080".
+	KeyHost = "host"
+)
+
+// ErrOpen is wrapped in the CodeUnavailable error returned while a breaker is open.
+var ErrOpen = errors.New("circuit breaker open")
+
+// Sentinel errors for invalid configuration.
+var (
+	// ErrInvalidKey is returned when Key is neither KeyProcedure nor KeyHost.
+	ErrInvalidKey = errors.New("key must be procedure or host")
+
+	// ErrInvalidWindow is returned when Window is not positive.
+	ErrInvalidWindow = errors.New("window must be positive")
+
+	// ErrInvalidFailureRate is returned when FailureRate is outside (0, 1].
+	ErrInvalidFailureRate = errors.New("failure_rate must be greater than 0 and at most 1")
+
+	// ErrInvalidMinRequests is returned when MinRequests is less than 1.
+	ErrInvalidMinRequests = errors.New("min_requests must be at least 1")
+
+	// ErrInvalidOpenTimeout is returned when OpenTimeout is not positive.
+	ErrInvalidOpenTimeout = errors.New("open_timeout must be positive")
+
+	// ErrInvalidHalfOpenRequests is returned when HalfOpenRequests is less than 1.
+	ErrInvalidHalfOpenRequests = errors.New("half_open_requests must be at least 1")
+)
+
+// Config holds configuration for the circuit breaker interceptor.
+type Config struct {
+	// Key selects what a breaker guards: KeyProcedure or KeyHost.
+	// Default: KeyProcedure
+	Key string `koanf:"key"`
+
+	// Window is the rolling window over which the failure rate is computed.
+	// Default: 10s
+	Window time.Duration `koanf:"window"`
+
+	// FailureRate is the fraction of failed calls in the window that opens
+	// the breaker, in (0, 1].
+	// Default: 0.5
+	FailureRate float64 `koanf:"failure_rate"`
+
+	// MinRequests is the number of calls the window must hold before the
+	// failure rate is evaluated, so a few early failures do not open the breaker.
+	// Default: 20
+	MinRequests int `koanf:"min_requests"`
+
+	// OpenTimeout is how long an open breaker fails fast before probing the
+	// downstream again.
+	// Default: 30s
+	OpenTimeout time.Duration `koanf:"open_timeout"`
+
+	// HalfOpenRequests is the number of probe calls let through while half-open.
+	// All of them must succeed to close the breaker; any failure reopens it.
+	// Default: 3
+	HalfOpenRequests int `koanf:"half_open_requests"`
+
+	// Codes lists the codes that count as failures.
+	// Default: unavailable, deadline_exceeded, internal, unknown, data_loss
+	Codes []connect.Code `koanf:"codes"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+func DefaultConfig() Config {
+	return Config{
+		Key:              KeyProcedure,
+		Window:           10 * time.Second,
+		FailureRate:      0.5,
+		MinRequests:      20,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenRequests: 3,
+		Codes:            defaultCodes(),
+	}
+}
+
+// Validate checks that all fields are within range.
+// Returns nil if configuration is valid.
+func (c Config) Validate() error {
+	if c.Key != "" && c.Key != KeyProcedure && c.Key != KeyHost {
+		return ErrInvalidKey
+	}
+	if c.Window <= 0 {
+		return ErrInvalidWindow
+	}
+	if c.FailureRate <= 0 || c.FailureRate > 1 {
+		return ErrInvalidFailureRate
+	}
+	if c.MinRequests < 1 {
+		return ErrInvalidMinRequests
+	}
+	if c.OpenTimeout <= 0 {
+		return ErrInvalidOpenTimeout
+	}
+	if c.HalfOpenRequests < 1 {
+		return ErrInvalidHalfOpenRequests
+	}
+	return nil
+}
+
+func defaultCodes() []connect.Code {
+	return []connect.Code{
+		connect.CodeUnavailable,
+		connect.CodeDeadlineExceeded,
+		connect.CodeInternal,
+		connect.CodeUnknown,
+		connect.CodeDataLoss,
+	}
+}
+
+// Interceptor is a Connect RPC client interceptor that keeps a Breaker per
+// procedure or host. Create it with NewInterceptor.
+type Interceptor struct {
+	key      string
+	settings settings
+	codes    []connect.Code
+
+	mu       sync.Mutex
+	breakers map[string]*Breaker
+
+	rejected metric.Int64Counter
+}
+
+// NewInterceptor creates a Connect RPC client interceptor that stops calling
+// a failing downstream for a while instead of waiting for each call to time out.
+//
+// Breakers start closed. Once the window holds at least MinRequests calls and
+// the share of calls failing with one of Config.Codes reaches FailureRate, the
+// breaker opens and calls fail immediately with CodeUnavailable wrapping ErrOpen.
+// After OpenTimeout it lets HalfOpenRequests probe calls through: if all
+// succeed it closes, otherwise it opens again. Calls canceled by the caller
+// are not counted.
+//
+// State changes are logged with slog and recorded through the global
+// OpenTelemetry MeterProvider:
+//   - rpc.client.circuit_breaker.state: current state by key (0 closed, 1 half-open, 2 open)
+//   - rpc.client.circuit_breaker.transitions: state changes by key, from, and to
+//   - rpc.client.circuit_breaker.rejected: calls failed fast by key
+//
+// Handlers and streaming calls pass through unchanged.
+// Returns error if cfg is invalid or metric instruments cannot be created.
+func NewInterceptor(cfg Config) (*Interceptor, error) {
+	return newInterceptor(cfg, otel.GetMeterProvider())
+}
+
+func newInterceptor(cfg Config, mp metric.MeterProvider) (*Interceptor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create circuit breaker interceptor: %w", err)
+	}
+
+	meter := mp.Meter(meterName)
+
+	transitions, err := meter.Int64Counter(
+		"rpc.client.circuit_breaker.transitions",
+		metric.WithDescription("Number of circuit breaker state changes"),
+		metric.WithUnit("{transition}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create transitions metric: %w", err)
+	}
+
+	rejected, err := meter.Int64Counter(
+		"rpc.client.circuit_breaker.rejected",
+		metric.WithDescription("Number of calls failed fast by an open circuit breaker"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create rejected metric: %w", err)
+	}
+
+	i := &Interceptor{
+		key: cfg.Key,
+		settings: settings{
+			window:           cfg.Window,
+			failureRate:      cfg.FailureRate,
+			minRequests:      cfg.MinRequests,
+			openTimeout:      cfg.OpenTimeout,
+			halfOpenRequests: cfg.HalfOpenRequests,
+			transitions:      transitions,
+			now:              time.Now,
+		},
+		codes:    cfg.Codes,
+		breakers: make(map[string]*Breaker),
+		rejected: rejected,
+	}
+	if i.key == "" {
+		i.key = KeyProcedure
+	}
+	if len(i.codes) == 0 {
+		i.codes = defaultCodes()
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"rpc.client.circuit_breaker.state",
+		metric.WithDescription("Current circuit breaker state (0 closed, 1 half-open, 2 open)"),
+		metric.WithUnit("{state}"),
+		metric.WithInt64Callback(i.observeStates),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create state metric: %w", err)
+	}
+
+	return i, nil
+}
+
+// Breaker returns the breaker for key, creating it in the closed state if needed.
+// Keys are procedures ("/pkg.Service/Method") or hosts ("users.internal:8080"),
+// depending on Config.Key. Use it to register a breaker as a health checker
+// before the first call.
+func (i *Interceptor) Breaker(key string) *Breaker {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	b, ok := i.breakers[key]
+	if !ok {
+		b = newBreaker(key, &i.settings)
+		i.breakers[key] = b
+	}
+	return b
+}
+
+// WrapUnary implements connect.Interceptor.
+func (i *Interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if !req.Spec().IsClient {
+			return next(ctx, req)
+		}
+
+		b := i.Breaker(i.keyOf(req))
+		generation, ok := b.allow(ctx)
+		if !ok {
+			i.rejected.Add(ctx, 1, metric.WithAttributes(attribute.String("key", b.key)))
+			return nil, connect.NewError(connect.CodeUnavailable, fmt.Errorf("%w: %s", ErrOpen, b.key))
+		}
+
+		resp, err := next(ctx, req)
+		b.record(ctx, generation, i.outcomeOf(err))
+		return resp, err
+	}
+}
+
+// WrapStreamingClient implements connect.Interceptor.
+func (i *Interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+// WrapStreamingHandler implements connect.Interceptor.
+func (i *Interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// keyOf returns the breaker key of req.
+func (i *Interceptor) keyOf(req connect.AnyRequest) string {
+	if i.key == KeyHost {
+		return req.Peer().Addr
+	}
+	return req.Spec().Procedure
+}
+
+// outcomeOf classifies a call result.
+func (i *Interceptor) outcomeOf(err error) outcome {
+	if err == nil {
+		return outcomeSuccess
+	}
+	code := connect.CodeOf(err)
+	switch {
+	case code == connect.CodeCanceled:
+		return outcomeIgnored
+	case slices.Contains(i.codes, code):
+		return outcomeFailure
+	default:
+		return outcomeSuccess
+	}
+}
+
+// observeStates reports the state of every breaker.
+func (i *Interceptor) observeStates(_ context.Context, o metric.Int64Observer) error {
+	i.mu.Lock()
+	breakers := make([]*Breaker, 0, len(i.breakers))
+	for _, b := range i.breakers {
+		breakers = append(breakers, b)
+	}
+	i.mu.Unlock()
+
+	for _, b := range breakers {
+		o.Observe(int64(b.State()), metric.WithAttributes(attribute.String("key", b.key)))
+	}
+	return nil
+}
+
+// compile-time check
+var _ connect.Interceptor = (*Interceptor)(nil)
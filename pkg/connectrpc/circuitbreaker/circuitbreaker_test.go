@@ -0,0 +1,403 @@
+package circuitbreaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr error
+	}{
+		{name: "default config", modify: func(*Config) {}},
+		{name: "host key", modify: func(c *Config) { c.Key = KeyHost }},
+		{name: "empty key", modify: func(c *Config) { c.Key = "" }},
+		{name: "invalid key", modify: func(c *Config) { c.Key = "tenant" }, wantErr: ErrInvalidKey},
+		{name: "zero window", modify: func(c *Config) { c.Window = 0 }, wantErr: ErrInvalidWindow},
+		{name: "zero failure rate", modify: func(c *Config) { c.FailureRate = 0 }, wantErr: ErrInvalidFailureRate},
+		{name: "failure rate above 1", modify: func(c *Config) { c.FailureRate = 1.5 }, wantErr: ErrInvalidFailureRate},
+		{name: "zero min requests", modify: func(c *Config) { c.MinRequests = 0 }, wantErr: ErrInvalidMinRequests},
+		{name: "zero open timeout", modify: func(c *Config) { c.OpenTimeout = 0 }, wantErr: ErrInvalidOpenTimeout},
+		{name: "zero half-open requests", modify: func(c *Config) { c.HalfOpenRequests = 0 }, wantErr: ErrInvalidHalfOpenRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			if err := cfg.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInterceptor_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewInterceptor(Config{})
+	if !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("NewInterceptor() error = %v, want %v", err, ErrInvalidWindow)
+	}
+}
+
+func TestInterceptor_StateMachine(t *testing.T) {
+	t.Parallel()
+
+	i, clock := newTestInterceptor(t, noop.NewMeterProvider())
+	call := client(i)
+	b := i.Breaker(procedure)
+
+	// Below MinRequests failures do not open the breaker.
+	for range 3 {
+		_ = call(errUnavailable)
+	}
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("state after 3 failures = %v, want %v", got, StateClosed)
+	}
+
+	// 4 failures of 4 calls reach the failure rate.
+	_ = call(errUnavailable)
+	if got := b.State(); got != StateOpen {
+		t.Fatalf("state after 4 failures = %v, want %v", got, StateOpen)
+	}
+	if b.Check(context.Background()) {
+		t.Error("Check() = true while open, want false")
+	}
+
+	// Open breakers fail fast without calling next.
+	err := call(nil)
+	if connect.CodeOf(err) != connect.CodeUnavailable || !errors.Is(err, ErrOpen) {
+		t.Fatalf("error while open = %v, want unavailable wrapping ErrOpen", err)
+	}
+
+	// After OpenTimeout, HalfOpenRequests probes are let through.
+	clock.advance(time.Minute)
+	if got := b.State(); got != StateHalfOpen {
+		t.Fatalf("state after open timeout = %v, want %v", got, StateHalfOpen)
+	}
+	if !b.Check(context.Background()) {
+		t.Error("Check() = false after open timeout, want true")
+	}
+	g1, ok1 := b.allow(context.Background())
+	g2, ok2 := b.allow(context.Background())
+	if _, ok := b.allow(context.Background()); !ok1 || !ok2 || ok {
+		t.Fatalf("half-open allowed %v, %v, %v; want true, true, false", ok1, ok2, ok)
+	}
+
+	// A canceled probe frees its slot.
+	b.record(context.Background(), g1, outcomeIgnored)
+	g3, ok3 := b.allow(context.Background())
+	if !ok3 {
+		t.Fatal("probe slot not freed after canceled call")
+	}
+
+	// All probes succeeding closes the breaker.
+	b.record(context.Background(), g2, outcomeSuccess)
+	b.record(context.Background(), g3, outcomeSuccess)
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("state after successful probes = %v, want %v", got, StateClosed)
+	}
+
+	// Outcomes from an earlier generation are ignored.
+	b.record(context.Background(), g1, outcomeFailure)
+	if got := b.State(); got != StateClosed {
+		t.Fatalf("state after stale outcome = %v, want %v", got, StateClosed)
+	}
+}
+
+func TestInterceptor_HalfOpenFailureReopens(t *testing.T) {
+	t.Parallel()
+
+	i, clock := newTestInterceptor(t, noop.NewMeterProvider())
+	call := client(i)
+	b := i.Breaker(procedure)
+
+	for range 4 {
+		_ = call(errUnavailable)
+	}
+	clock.advance(time.Minute)
+
+	if err := call(errUnavailable); connect.CodeOf(err) != connect.CodeUnavailable || errors.Is(err, ErrOpen) {
+		t.Fatalf("probe error = %v, want downstream error", err)
+	}
+	if got := b.State(); got != StateOpen {
+		t.Fatalf("state after failed probe = %v, want %v", got, StateOpen)
+	}
+	if err := call(nil); !errors.Is(err, ErrOpen) {
+		t.Errorf("error after failed probe = %v, want %v", err, ErrOpen)
+	}
+}
+
+func TestInterceptor_FailureRate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		errs      []error
+		advance   time.Duration
+		wantState State
+	}{
+		{
+			name:      "rate below threshold",
+			errs:      []error{nil, nil, nil, errUnavailable},
+			wantState: StateClosed,
+		},
+		{
+			name:      "rate at threshold",
+			errs:      []error{nil, nil, errUnavailable, errUnavailable},
+			wantState: StateOpen,
+		},
+		{
+			name:      "non-failure codes count as success",
+			errs:      []error{errNotFound, errNotFound, errNotFound, errUnavailable},
+			wantState: StateClosed,
+		},
+		{
+			name:      "canceled calls are not counted",
+			errs:      []error{errCanceled, errCanceled, errCanceled, errUnavailable},
+			wantState: StateClosed,
+		},
+		{
+			name:      "failures expire with the window",
+			errs:      []error{errUnavailable, errUnavailable, errUnavailable},
+			advance:   11 * time.Second,
+			wantState: StateClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i, clock := newTestInterceptor(t, noop.NewMeterProvider())
+			call := client(i)
+
+			for _, err := range tt.errs {
+				_ = call(err)
+			}
+			clock.advance(tt.advance)
+			_ = call(errUnavailable)
+
+			if got := i.Breaker(procedure).State(); got != tt.wantState {
+				t.Errorf("state = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestInterceptor_Keys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		key      string
+		req      *mockRequest
+		wantKey  string
+		otherKey string
+	}{
+		{
+			name:     "per procedure",
+			key:      KeyProcedure,
+			req:      &mockRequest{spec: connect.Spec{Procedure: procedure, IsClient: true}, peer: connect.Peer{Addr: "users:8080"}},
+			wantKey:  procedure,
+			otherKey: "users:8080",
+		},
+		{
+			name:     "per host",
+			key:      KeyHost,
+			req:      &mockRequest{spec: connect.Spec{Procedure: procedure, IsClient: true}, peer: connect.Peer{Addr: "users:8080"}},
+			wantKey:  "users:8080",
+			otherKey: procedure,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := testConfig()
+			cfg.Key = tt.key
+			i, err := newInterceptor(cfg, noop.NewMeterProvider())
+			if err != nil {
+				t.Fatalf("newInterceptor() error = %v", err)
+			}
+
+			wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				return nil, errUnavailable
+			})
+			for range 4 {
+				_, _ = wrapped(context.Background(), tt.req)
+			}
+
+			if got := i.Breaker(tt.wantKey).State(); got != StateOpen {
+				t.Errorf("state of %q = %v, want %v", tt.wantKey, got, StateOpen)
+			}
+			if got := i.Breaker(tt.otherKey).State(); got != StateClosed {
+				t.Errorf("state of %q = %v, want %v", tt.otherKey, got, StateClosed)
+			}
+		})
+	}
+}
+
+func TestInterceptor_HandlerPassesThrough(t *testing.T) {
+	t.Parallel()
+
+	i, _ := newTestInterceptor(t, noop.NewMeterProvider())
+	wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return nil, errUnavailable
+	})
+
+	req := &mockRequest{spec: connect.Spec{Procedure: procedure}}
+	for range 10 {
+		_, _ = wrapped(context.Background(), req)
+	}
+
+	if got := i.Breaker(procedure).State(); got != StateClosed {
+		t.Errorf("state = %v, want %v", got, StateClosed)
+	}
+}
+
+func TestInterceptor_Metrics(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	i, _ := newTestInterceptor(t, mp)
+	call := client(i)
+	for range 4 {
+		_ = call(errUnavailable)
+	}
+	_ = call(nil)
+	_ = call(nil)
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	key := attribute.String("key", procedure)
+	opened := attribute.NewSet(key, attribute.String("from", "closed"), attribute.String("to", "open"))
+	if got := int64Value(rm, "rpc.client.circuit_breaker.transitions", opened); got != 1 {
+		t.Errorf("transitions closed->open = %d, want 1", got)
+	}
+	if got := int64Value(rm, "rpc.client.circuit_breaker.rejected", attribute.NewSet(key)); got != 2 {
+		t.Errorf("rejected = %d, want 2", got)
+	}
+	if got := int64Value(rm, "rpc.client.circuit_breaker.state", attribute.NewSet(key)); got != int64(StateOpen) {
+		t.Errorf("state = %d, want %d", got, StateOpen)
+	}
+}
+
+const procedure = "/test.Service/Get"
+
+var (
+	errUnavailable = connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+	errNotFound    = connect.NewError(connect.CodeNotFound, errors.New("not found"))
+	errCanceled    = connect.NewError(connect.CodeCanceled, context.Canceled)
+)
+
+// testConfig opens after 4 calls at a 50% failure rate.
+func testConfig() Config {
+	cfg := DefaultConfig()
+	cfg.MinRequests = 4
+	cfg.HalfOpenRequests = 2
+	return cfg
+}
+
+func newTestInterceptor(t *testing.T, mp metric.MeterProvider) (*Interceptor, *fakeClock) {
+	t.Helper()
+
+	i, err := newInterceptor(testConfig(), mp)
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	i.settings.now = clock.Now
+	return i, clock
+}
+
+// client returns a function that makes a client call whose downstream returns err.
+func client(i *Interceptor) func(err error) error {
+	req := &mockRequest{spec: connect.Spec{Procedure: procedure, IsClient: true}}
+	return func(downstreamErr error) error {
+		wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+			if downstreamErr != nil {
+				return nil, downstreamErr
+			}
+			return &mockResponse{}, nil
+		})
+		_, err := wrapped(context.Background(), req)
+		return err
+	}
+}
+
+func int64Value(rm metricdata.ResourceMetrics, name string, attrs attribute.Set) int64 {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			var points []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				points = data.DataPoints
+			case metricdata.Gauge[int64]:
+				points = data.DataPoints
+			}
+			for _, dp := range points {
+				if dp.Attributes.Equals(&attrs) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	spec connect.Spec
+	peer connect.Peer
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return r.spec
+}
+
+func (r *mockRequest) Peer() connect.Peer {
+	return r.peer
+}
+
+type mockResponse struct {
+	connect.AnyResponse
+}
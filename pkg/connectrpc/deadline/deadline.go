@@ -48,6 +48,17 @@ func NewInterceptor(cfg Config) connect.Interceptor {
 	}
 }
 
+// NewClientInterceptor creates a Connect RPC client interceptor that applies
+// the same deadline rules to outbound unary calls: DefaultTimeout when the
+// caller's context has no deadline, and MaxTimeout as a cap. The resulting
+// deadline is propagated to the server in the protocol's timeout header, so
+// a handler's remaining time carries over to the services it calls.
+//
+// Panics under the same conditions as NewInterceptor.
+func NewClientInterceptor(cfg Config) connect.Interceptor {
+	return &clientInterceptor{interceptor: *NewInterceptor(cfg).(*interceptor)}
+}
+
 type interceptor struct {
 	defaultTimeout time.Duration
 	maxTimeout     time.Duration
@@ -74,6 +85,23 @@ func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) co
 	return next
 }
 
+type clientInterceptor struct {
+	interceptor
+}
+
+func (i *clientInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if !req.Spec().IsClient {
+			return next(ctx, req)
+		}
+
+		ctx, cancel := i.applyDeadline(ctx)
+		defer cancel()
+
+		return next(ctx, req)
+	}
+}
+
 // applyDeadline returns a context with an appropriate deadline and a cancel function.
 func (i *interceptor) applyDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
 	deadline, hasDeadline := ctx.Deadline()
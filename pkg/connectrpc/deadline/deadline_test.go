@@ -295,6 +295,59 @@ func TestInterceptor_WrapUnary_MaxTimeoutCapsExisting(t *testing.T) {
 	}
 }
 
+func TestClientInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		isClient     bool
+		wantDeadline bool
+	}{
+		{name: "client call gets default timeout", isClient: true, wantDeadline: true},
+		{name: "handler passes through", isClient: false, wantDeadline: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			interceptor := NewClientInterceptor(Config{
+				DefaultTimeout: 100 * time.Millisecond,
+			})
+
+			var capturedDeadline time.Time
+			var hadDeadline bool
+			wrapped := interceptor.WrapUnary(func(ctx context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				capturedDeadline, hadDeadline = ctx.Deadline()
+				return &mockResponse{}, nil
+			})
+
+			req := &mockRequest{procedure: "/test.Service/Method", isClient: tt.isClient}
+			if _, err := wrapped(context.Background(), req); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+
+			if hadDeadline != tt.wantDeadline {
+				t.Fatalf("hadDeadline = %v, want %v", hadDeadline, tt.wantDeadline)
+			}
+			if remaining := time.Until(capturedDeadline); tt.wantDeadline && (remaining < 80*time.Millisecond || remaining > 110*time.Millisecond) {
+				t.Errorf("deadline remaining %v, expected ~100ms", remaining)
+			}
+		})
+	}
+}
+
+func TestNewClientInterceptor_Validation(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for non-positive DefaultTimeout")
+		}
+	}()
+	NewClientInterceptor(Config{})
+}
+
 type mockRequest struct {
 	connect.AnyRequest
 	procedure string
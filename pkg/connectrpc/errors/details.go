@@ -0,0 +1,230 @@
+package errors
+
+import (
+	"errors"
+	"maps"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Detailer allows errors to attach google.rpc error details (errdetails) or
+// other protobuf messages to the Connect error sent to clients.
+// Details are only sent for errors that also implement ConnectCoder,
+// such as *Error.
+type Detailer interface {
+	ErrorDetails() []proto.Message
+}
+
+// PublicMessager allows errors that wrap internal errors to send only their
+// own message to clients, e.g. "not found" instead of the wrapped SQL error.
+// Only used for errors that also implement ConnectCoder.
+type PublicMessager interface {
+	PublicMessage() string
+}
+
+// Error is a domain error with a Connect code and error details.
+// Build it with NewError and the With* methods, which modify and return the
+// receiver so that calls can be chained:
+//
+//	return errors.NewError(connect.CodeInvalidArgument, "invalid user").
+//		WithFieldViolation("email", "must be a valid email address").
+//		WithFieldViolation("age", "must be at least 18")
+//
+// The message and details are sent to clients as-is, so they must not
+// contain internal information; attach such information with WithCause.
+type Error struct {
+	code    connect.Code
+	message string
+	cause   error
+
+	info          *errdetails.ErrorInfo
+	retry         *errdetails.RetryInfo
+	fields        []*errdetails.BadRequest_FieldViolation
+	preconditions []*errdetails.PreconditionFailure_Violation
+	localized     []*errdetails.LocalizedMessage
+	extra         []proto.Message
+}
+
+// NewError creates an Error with the given code and client-facing message.
+func NewError(code connect.Code, message string) *Error {
+	return &Error{code: code, message: message}
+}
+
+// Error returns the message, followed by the cause if one is set.
+func (e *Error) Error() string {
+	if e.cause == nil {
+		return e.message
+	}
+	if e.message == "" {
+		return e.cause.Error()
+	}
+	return e.message + ": " + e.cause.Error()
+}
+
+// Unwrap returns the cause.
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// ConnectCode returns the error's Connect code.
+func (e *Error) ConnectCode() connect.Code {
+	return e.code
+}
+
+// PublicMessage returns the client-facing message without the cause.
+func (e *Error) PublicMessage() string {
+	return e.message
+}
+
+// WithCause records the underlying error for errors.Is and errors.As.
+// It is not sent to clients.
+func (e *Error) WithCause(err error) *Error {
+	e.cause = err
+	return e
+}
+
+// WithReason attaches an ErrorInfo with a machine-readable reason
+// (UPPER_SNAKE_CASE, e.g. "QUOTA_EXCEEDED") and the domain that defines it
+// (e.g. "orders.example.com"). metadata may be nil.
+func (e *Error) WithReason(reason, domain string, metadata map[string]string) *Error {
+	e.info = &errdetails.ErrorInfo{Reason: reason, Domain: domain, Metadata: maps.Clone(metadata)}
+	return e
+}
+
+// WithRetryDelay attaches a RetryInfo telling clients how long to wait
+// before retrying.
+func (e *Error) WithRetryDelay(delay time.Duration) *Error {
+	e.retry = &errdetails.RetryInfo{RetryDelay: durationpb.New(delay)}
+	return e
+}
+
+// WithFieldViolation adds a BadRequest field violation. field is a path to
+// the offending request field, e.g. "user.emails[0]".
+func (e *Error) WithFieldViolation(field, description string) *Error {
+	e.fields = append(e.fields, &errdetails.BadRequest_FieldViolation{Field: field, Description: description})
+	return e
+}
+
+// WithPreconditionViolation adds a PreconditionFailure violation.
+// typ is a service-specific type such as "TOS", subject names the object
+// that failed the check, e.g. "user:42".
+func (e *Error) WithPreconditionViolation(typ, subject, description string) *Error {
+	e.preconditions = append(e.preconditions, &errdetails.PreconditionFailure_Violation{
+		Type:        typ,
+		Subject:     subject,
+		Description: description,
+	})
+	return e
+}
+
+// WithLocalizedMessage adds a message for end users in the given BCP-47
+// locale, e.g. "en-US" or "de".
+func (e *Error) WithLocalizedMessage(locale, message string) *Error {
+	e.localized = append(e.localized, &errdetails.LocalizedMessage{Locale: locale, Message: message})
+	return e
+}
+
+// WithDetail attaches an arbitrary protobuf message as an error detail.
+func (e *Error) WithDetail(detail proto.Message) *Error {
+	e.extra = append(e.extra, detail)
+	return e
+}
+
+// ErrorDetails returns the attached details, with field and precondition
+// violations grouped into a single BadRequest and PreconditionFailure.
+func (e *Error) ErrorDetails() []proto.Message {
+	var details []proto.Message
+	if e.info != nil {
+		details = append(details, e.info)
+	}
+	if e.retry != nil {
+		details = append(details, e.retry)
+	}
+	if len(e.fields) > 0 {
+		details = append(details, &errdetails.BadRequest{FieldViolations: e.fields})
+	}
+	if len(e.preconditions) > 0 {
+		details = append(details, &errdetails.PreconditionFailure{Violations: e.preconditions})
+	}
+	for _, msg := range e.localized {
+		details = append(details, msg)
+	}
+	return append(details, e.extra...)
+}
+
+// Details returns the details of type T carried by the *connect.Error in
+// err's chain, e.g. Details[*errdetails.BadRequest](err) on the client side.
+// Details that cannot be decoded are skipped.
+func Details[T proto.Message](err error) []T {
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		return nil
+	}
+
+	var details []T
+	for _, detail := range connectErr.Details() {
+		value, err := detail.Value()
+		if err != nil {
+			continue
+		}
+		if v, ok := value.(T); ok {
+			details = append(details, v)
+		}
+	}
+	return details
+}
+
+// publicError replaces the message of err with that of the first
+// PublicMessager in its chain, such as an *Error with a cause, while keeping
+// err available to errors.Is and errors.As.
+func publicError(err error) error {
+	var messager PublicMessager
+	if !errors.As(err, &messager) {
+		return err
+	}
+	message := messager.PublicMessage()
+	if message == err.Error() {
+		return err
+	}
+	return &messageError{message: message, err: err}
+}
+
+// messageError replaces the message of err.
+type messageError struct {
+	message string
+	err     error
+}
+
+func (e *messageError) Error() string {
+	return e.message
+}
+
+func (e *messageError) Unwrap() error {
+	return e.err
+}
+
+// withDetails adds the details of the first Detailer in err's chain to connectErr.
+// Details that cannot be marshaled are skipped.
+func withDetails(connectErr *connect.Error, err error) *connect.Error {
+	var detailer Detailer
+	if !errors.As(err, &detailer) {
+		return connectErr
+	}
+	for _, msg := range detailer.ErrorDetails() {
+		if detail, err := connect.NewErrorDetail(msg); err == nil {
+			connectErr.AddDetail(detail)
+		}
+	}
+	return connectErr
+}
+
+// compile-time check
+var (
+	_ ConnectCoder   = (*Error)(nil)
+	_ Detailer       = (*Error)(nil)
+	_ PublicMessager = (*Error)(nil)
+)
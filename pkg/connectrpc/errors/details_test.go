@@ -0,0 +1,241 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// detailedError implements ConnectCoder and Detailer for testing.
+type detailedError struct {
+	codedError
+	details []proto.Message
+}
+
+func (e *detailedError) ErrorDetails() []proto.Message {
+	return e.details
+}
+
+// publicMessageError is a ConnectCoder that sends only its public message.
+type publicMessageError struct {
+	codedError
+	public string
+}
+
+func (e *publicMessageError) PublicMessage() string {
+	return e.public
+}
+
+// uncodedDetailedError implements only Detailer.
+type uncodedDetailedError struct{}
+
+func (e *uncodedDetailedError) Error() string {
+	return "secret"
+}
+
+func (e *uncodedDetailedError) ErrorDetails() []proto.Message {
+	return []proto.Message{&errdetails.ErrorInfo{Reason: "LEAKED"}}
+}
+
+func TestError(t *testing.T) {
+	t.Parallel()
+
+	cause := errors.New("duplicate key")
+
+	tests := []struct {
+		name        string
+		err         *Error
+		wantError   string
+		wantMessage string
+	}{
+		{
+			name:        "message only",
+			err:         NewError(connect.CodeNotFound, "user not found"),
+			wantError:   "user not found",
+			wantMessage: "user not found",
+		},
+		{
+			name:        "with cause",
+			err:         NewError(connect.CodeAlreadyExists, "email taken").WithCause(cause),
+			wantError:   "email taken: duplicate key",
+			wantMessage: "email taken",
+		},
+		{
+			name:      "cause without message",
+			err:       NewError(connect.CodeAlreadyExists, "").WithCause(cause),
+			wantError: "duplicate key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.err.Error(); got != tt.wantError {
+				t.Errorf("Error() = %q, want %q", got, tt.wantError)
+			}
+			if got := tt.err.PublicMessage(); got != tt.wantMessage {
+				t.Errorf("PublicMessage() = %q, want %q", got, tt.wantMessage)
+			}
+		})
+	}
+
+	err := NewError(connect.CodeAlreadyExists, "email taken").WithCause(cause)
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false, want true")
+	}
+	if err.ConnectCode() != connect.CodeAlreadyExists {
+		t.Errorf("ConnectCode() = %v, want %v", err.ConnectCode(), connect.CodeAlreadyExists)
+	}
+}
+
+func TestError_ErrorDetails(t *testing.T) {
+	t.Parallel()
+
+	metadata := map[string]string{"limit": "10"}
+	err := NewError(connect.CodeFailedPrecondition, "cannot place order").
+		WithReason("QUOTA_EXCEEDED", "orders.example.com", metadata).
+		WithRetryDelay(30*time.Second).
+		WithFieldViolation("items[0].sku", "unknown SKU").
+		WithFieldViolation("address.zip", "required").
+		WithPreconditionViolation("TOS", "user:42", "terms not accepted").
+		WithLocalizedMessage("de", "Bestellung nicht möglich").
+		WithDetail(wrapperspb.String("extra"))
+	metadata["limit"] = "changed"
+
+	details := err.ErrorDetails()
+	if len(details) != 6 {
+		t.Fatalf("ErrorDetails() returned %d details, want 6", len(details))
+	}
+
+	want := []proto.Message{
+		&errdetails.ErrorInfo{Reason: "QUOTA_EXCEEDED", Domain: "orders.example.com", Metadata: map[string]string{"limit": "10"}},
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(30 * time.Second)},
+		&errdetails.BadRequest{FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: "items[0].sku", Description: "unknown SKU"},
+			{Field: "address.zip", Description: "required"},
+		}},
+		&errdetails.PreconditionFailure{Violations: []*errdetails.PreconditionFailure_Violation{
+			{Type: "TOS", Subject: "user:42", Description: "terms not accepted"},
+		}},
+		&errdetails.LocalizedMessage{Locale: "de", Message: "Bestellung nicht möglich"},
+		wrapperspb.String("extra"),
+	}
+	for i := range want {
+		if !proto.Equal(details[i], want[i]) {
+			t.Errorf("detail %d = %v, want %v", i, details[i], want[i])
+		}
+	}
+}
+
+func TestMapError_Details(t *testing.T) {
+	t.Parallel()
+
+	cause := errors.New("pq: duplicate key value violates unique constraint \"users_email_key\"")
+
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    connect.Code
+		wantMessage string
+		wantFields  int
+		wantInfo    int
+	}{
+		{
+			name: "domain error",
+			err: NewError(connect.CodeInvalidArgument, "invalid user").
+				WithFieldViolation("email", "invalid").
+				WithReason("INVALID_USER", "users.example.com", nil),
+			wantCode:    connect.CodeInvalidArgument,
+			wantMessage: "invalid user",
+			wantFields:  1,
+			wantInfo:    1,
+		},
+		{
+			name:        "wrapped domain error hides cause",
+			err:         fmt.Errorf("create user: %w", NewError(connect.CodeAlreadyExists, "email taken").WithCause(cause)),
+			wantCode:    connect.CodeAlreadyExists,
+			wantMessage: "email taken",
+		},
+		{
+			name: "public message hides wrapped error",
+			err: &publicMessageError{
+				codedError: codedError{msg: "not found: no rows in result set", code: connect.CodeNotFound},
+				public:     "not found",
+			},
+			wantCode:    connect.CodeNotFound,
+			wantMessage: "not found",
+		},
+		{
+			name: "custom detailer",
+			err: &detailedError{
+				codedError: codedError{msg: "bad input", code: connect.CodeInvalidArgument},
+				details:    []proto.Message{&errdetails.BadRequest{}},
+			},
+			wantCode:    connect.CodeInvalidArgument,
+			wantMessage: "bad input",
+			wantFields:  1,
+		},
+		{
+			name:        "detailer without code is sanitized",
+			err:         &uncodedDetailedError{},
+			wantCode:    connect.CodeInternal,
+			wantMessage: "internal error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := mapError(tt.err)
+
+			if result.Code() != tt.wantCode {
+				t.Errorf("code = %v, want %v", result.Code(), tt.wantCode)
+			}
+			if result.Message() != tt.wantMessage {
+				t.Errorf("message = %q, want %q", result.Message(), tt.wantMessage)
+			}
+			if got := len(Details[*errdetails.BadRequest](result)); got != tt.wantFields {
+				t.Errorf("BadRequest details = %d, want %d", got, tt.wantFields)
+			}
+			if got := len(Details[*errdetails.ErrorInfo](result)); got != tt.wantInfo {
+				t.Errorf("ErrorInfo details = %d, want %d", got, tt.wantInfo)
+			}
+		})
+	}
+
+	// The cause stays available to server-side inspection.
+	if result := mapError(NewError(connect.CodeAlreadyExists, "email taken").WithCause(cause)); !errors.Is(result, cause) {
+		t.Error("errors.Is(result, cause) = false, want true")
+	}
+}
+
+func TestMapClientError_Details(t *testing.T) {
+	t.Parallel()
+
+	result := mapClientError(NewError(connect.CodeUnavailable, "backend down").WithRetryDelay(time.Second))
+
+	if result.Code() != connect.CodeUnavailable {
+		t.Errorf("code = %v, want %v", result.Code(), connect.CodeUnavailable)
+	}
+	retry := Details[*errdetails.RetryInfo](result)
+	if len(retry) != 1 || retry[0].GetRetryDelay().AsDuration() != time.Second {
+		t.Errorf("RetryInfo = %v, want 1s", retry)
+	}
+}
+
+func TestDetails_NoConnectError(t *testing.T) {
+	t.Parallel()
+
+	if got := Details[*errdetails.BadRequest](errors.New("plain")); got != nil {
+		t.Errorf("Details() = %v, want nil", got)
+	}
+}
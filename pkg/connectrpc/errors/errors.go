@@ -6,8 +6,13 @@ import (
 	"errors"
 
 	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/report"
 )
 
+// errInternal is the sanitized error returned for unmapped errors.
+var errInternal = errors.New("internal error")
+
 // ConnectCoder allows errors to specify their Connect RPC error code.
 // Implement this interface on domain errors to map them to appropriate
 // Connect codes while preserving the original error message.
@@ -25,19 +30,40 @@ type ConnectCoder interface {
 //  4. *connect.Error → preserved as-is
 //  5. Any other error → CodeInternal with message "internal error"
 //
-// For mapped errors (1-4), the original message is preserved.
-// For unmapped errors (5), the message is sanitized to hide internal details.
-func NewInterceptor() connect.Interceptor {
-	return &interceptor{}
+// For mapped errors (1-4), the original message is preserved, except that
+// ConnectCoder errors implementing PublicMessager, such as an *Error, only
+// send their public message.
+// For unmapped errors (5), the message is sanitized to hide internal details
+// and carries the request and trace IDs (see NewInternalError). The original
+// error is passed to the reporter set with WithReporter.
+// ConnectCoder errors (3) also send the details of a Detailer in their chain.
+func NewInterceptor(opts ...Option) connect.Interceptor {
+	i := &interceptor{}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
 }
 
-type interceptor struct{}
+// Option configures the server interceptor.
+type Option func(*interceptor)
+
+// WithReporter reports every unmapped error before it is sanitized.
+func WithReporter(reporter report.Reporter) Option {
+	return func(i *interceptor) {
+		i.reporter = reporter
+	}
+}
+
+type interceptor struct {
+	reporter report.Reporter
+}
 
 func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
 		resp, err := next(ctx, req)
 		if err != nil {
-			return resp, mapError(err)
+			return resp, i.mapError(ctx, req.Spec().Procedure, err)
 		}
 		return resp, nil
 	}
@@ -51,12 +77,86 @@ func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) co
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
 		err := next(ctx, conn)
 		if err != nil {
-			return mapError(err)
+			return i.mapError(ctx, conn.Spec().Procedure, err)
 		}
 		return nil
 	}
 }
 
+// mapError maps err. Sanitized errors are reported and returned with the
+// request's reference IDs. Errors that already are a *connect.Error,
+// such as the internal error of a recovered panic, were handled upstream.
+func (i *interceptor) mapError(ctx context.Context, procedure string, err error) *connect.Error {
+	connectErr := mapError(err)
+	var handled *connect.Error
+	if !errors.Is(connectErr, errInternal) || errors.As(err, &handled) {
+		return connectErr
+	}
+
+	if i.reporter != nil {
+		i.reporter.Report(ctx, report.New(ctx, procedure, err))
+	}
+	return NewInternalError(ctx)
+}
+
+// NewClientInterceptor creates a Connect RPC client interceptor that returns
+// every unary call error as a *connect.Error, so callers can rely on
+// connect.CodeOf and errors.As regardless of which interceptor failed.
+//
+// Error mapping priority:
+//  1. *connect.Error anywhere in the chain → that error, unwrapped
+//  2. context.Canceled → CodeCanceled
+//  3. context.DeadlineExceeded → CodeDeadlineExceeded
+//  4. ConnectCoder interface → code from ConnectCode(), with Detailer details
+//  5. Any other error → CodeUnknown
+//
+// Messages are not sanitized, since client errors stay within the process.
+func NewClientInterceptor() connect.Interceptor {
+	return &clientInterceptor{}
+}
+
+type clientInterceptor struct{}
+
+func (i *clientInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		resp, err := next(ctx, req)
+		if err != nil && req.Spec().IsClient {
+			return resp, mapClientError(err)
+		}
+		return resp, err
+	}
+}
+
+func (i *clientInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *clientInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+func mapClientError(err error) *connect.Error {
+	// Drop local wrapping around Connect errors, e.g. from the server or transport
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return connect.NewError(connect.CodeCanceled, err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return connect.NewError(connect.CodeDeadlineExceeded, err)
+	}
+
+	var coder ConnectCoder
+	if errors.As(err, &coder) {
+		return withDetails(connect.NewError(coder.ConnectCode(), err), err)
+	}
+
+	return connect.NewError(connect.CodeUnknown, err)
+}
+
 func mapError(err error) *connect.Error {
 	// Check context errors first
 	if errors.Is(err, context.Canceled) {
@@ -69,7 +169,7 @@ func mapError(err error) *connect.Error {
 	// Check if error implements ConnectCoder
 	var coder ConnectCoder
 	if errors.As(err, &coder) {
-		return connect.NewError(coder.ConnectCode(), err)
+		return withDetails(connect.NewError(coder.ConnectCode(), publicError(err)), err)
 	}
 
 	// Check if already a connect.Error
@@ -79,5 +179,5 @@ func mapError(err error) *connect.Error {
 	}
 
 	// Unmapped error: return CodeInternal with sanitized message
-	return connect.NewError(connect.CodeInternal, errors.New("internal error"))
+	return connect.NewError(connect.CodeInternal, errInternal)
 }
@@ -7,6 +7,9 @@ import (
 	"testing"
 
 	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/report"
+	"github.com/deepworx/go-utils/pkg/ctxutil"
 )
 
 // codedError implements ConnectCoder for testing.
@@ -175,6 +178,57 @@ func TestInterceptor_WrapUnary_Error(t *testing.T) {
 	}
 }
 
+func TestInterceptor_Reporter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		handlerErr error
+		wantReport bool
+	}{
+		{name: "unmapped error", handlerErr: errors.New("db down"), wantReport: true},
+		{name: "ConnectCoder", handlerErr: &codedError{msg: "not found", code: connect.CodeNotFound}},
+		{name: "connect.Error", handlerErr: connect.NewError(connect.CodeUnavailable, errors.New("busy"))},
+		{name: "context.Canceled", handlerErr: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var reports []report.Report
+			interceptor := NewInterceptor(WithReporter(report.ReporterFunc(func(_ context.Context, r report.Report) {
+				reports = append(reports, r)
+			})))
+			ctx := ctxutil.WithRequestID(context.Background(), "req-1")
+
+			_, _ = interceptor.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				return nil, tt.handlerErr
+			})(ctx, &mockRequest{procedure: "/test.Service/Method"})
+			_ = interceptor.WrapStreamingHandler(func(_ context.Context, _ connect.StreamingHandlerConn) error {
+				return tt.handlerErr
+			})(ctx, &mockStreamingConn{procedure: "/test.Service/Stream"})
+
+			if !tt.wantReport {
+				if len(reports) != 0 {
+					t.Errorf("reported %d errors, want none", len(reports))
+				}
+				return
+			}
+			if len(reports) != 2 {
+				t.Fatalf("reported %d errors, want 2", len(reports))
+			}
+			for i, procedure := range []string{"/test.Service/Method", "/test.Service/Stream"} {
+				r := reports[i]
+				if r.Err != tt.handlerErr || r.Procedure != procedure || r.RequestID != "req-1" {
+					t.Errorf("report = {Err: %v, Procedure: %q, RequestID: %q}, want {%v, %q, req-1}",
+						r.Err, r.Procedure, r.RequestID, tt.handlerErr, procedure)
+				}
+			}
+		})
+	}
+}
+
 func TestInterceptor_WrapUnary_NoError(t *testing.T) {
 	t.Parallel()
 
@@ -287,13 +341,131 @@ func TestInterceptor_WrapStreamingClient_PassThrough(t *testing.T) {
 	}
 }
 
+func TestMapClientError(t *testing.T) {
+	t.Parallel()
+
+	serverErr := connect.NewError(connect.CodeNotFound, errors.New("user not found"))
+
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    connect.Code
+		wantMessage string
+		wantSame    error
+	}{
+		{
+			name:        "connect.Error",
+			err:         serverErr,
+			wantCode:    connect.CodeNotFound,
+			wantMessage: "user not found",
+			wantSame:    serverErr,
+		},
+		{
+			name:        "wrapped connect.Error",
+			err:         fmt.Errorf("call users: %w", serverErr),
+			wantCode:    connect.CodeNotFound,
+			wantMessage: "user not found",
+			wantSame:    serverErr,
+		},
+		{
+			name:        "context.Canceled",
+			err:         fmt.Errorf("send: %w", context.Canceled),
+			wantCode:    connect.CodeCanceled,
+			wantMessage: "send: context canceled",
+		},
+		{
+			name:        "context.DeadlineExceeded",
+			err:         context.DeadlineExceeded,
+			wantCode:    connect.CodeDeadlineExceeded,
+			wantMessage: "context deadline exceeded",
+		},
+		{
+			name:        "ConnectCoder",
+			err:         &codedError{msg: "circuit open", code: connect.CodeUnavailable},
+			wantCode:    connect.CodeUnavailable,
+			wantMessage: "circuit open",
+		},
+		{
+			name:        "unmapped error keeps message",
+			err:         errors.New("dial tcp: connection refused"),
+			wantCode:    connect.CodeUnknown,
+			wantMessage: "dial tcp: connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result := mapClientError(tt.err)
+
+			if result.Code() != tt.wantCode {
+				t.Errorf("code = %v, want %v", result.Code(), tt.wantCode)
+			}
+			if result.Message() != tt.wantMessage {
+				t.Errorf("message = %q, want %q", result.Message(), tt.wantMessage)
+			}
+			if tt.wantSame != nil && result != tt.wantSame {
+				t.Errorf("result = %p, want unwrapped %p", result, tt.wantSame)
+			}
+			if !errors.Is(result, tt.err) && !errors.Is(tt.err, result) {
+				t.Errorf("result %v does not wrap %v", result, tt.err)
+			}
+		})
+	}
+}
+
+func TestClientInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		isClient bool
+		wantCode connect.Code
+	}{
+		{name: "client call is mapped", isClient: true, wantCode: connect.CodeUnknown},
+		{name: "handler passes through", isClient: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			callErr := errors.New("connection reset")
+			wrapped := NewClientInterceptor().WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				return nil, callErr
+			})
+
+			_, err := wrapped(context.Background(), &mockRequest{procedure: "/test.Service/Method", isClient: tt.isClient})
+
+			var connectErr *connect.Error
+			if !tt.isClient {
+				if err != callErr {
+					t.Errorf("error = %v, want unchanged %v", err, callErr)
+				}
+				return
+			}
+			if !errors.As(err, &connectErr) {
+				t.Fatalf("error = %T, want *connect.Error", err)
+			}
+			if connectErr.Code() != tt.wantCode {
+				t.Errorf("code = %v, want %v", connectErr.Code(), tt.wantCode)
+			}
+			if !errors.Is(err, callErr) {
+				t.Errorf("error = %v, want wrapping %v", err, callErr)
+			}
+		})
+	}
+}
+
 type mockRequest struct {
 	connect.AnyRequest
 	procedure string
+	isClient  bool
 }
 
 func (r *mockRequest) Spec() connect.Spec {
-	return connect.Spec{Procedure: r.procedure}
+	return connect.Spec{Procedure: r.procedure, IsClient: r.isClient}
 }
 
 type mockResponse struct {
@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// ReasonInternal is the ErrorInfo reason of sanitized internal errors.
+// Its metadata holds the "request_id" and "trace_id" of the failed request.
+const ReasonInternal = "INTERNAL"
+
+// NewInternalError returns the sanitized CodeInternal error sent to clients
+// in place of unexpected errors and panics.
+//
+// The request ID from ctxutil.RequestID and the trace ID of the span in ctx
+// are appended to the message, e.g.
+// "internal error (request_id: 4f3c…, trace_id: 0af7…)", and attached as an
+// ErrorInfo detail, so that clients can quote them to support. IDs missing
+// from ctx are left out.
+func NewInternalError(ctx context.Context) *connect.Error {
+	ids := referenceIDs(ctx)
+	if len(ids) == 0 {
+		return connect.NewError(connect.CodeInternal, errInternal)
+	}
+
+	parts := make([]string, 0, len(ids))
+	metadata := make(map[string]string, len(ids))
+	for _, id := range ids {
+		parts = append(parts, id.key+": "+id.value)
+		metadata[id.key] = id.value
+	}
+
+	connectErr := connect.NewError(connect.CodeInternal, fmt.Errorf("%w (%s)", errInternal, strings.Join(parts, ", ")))
+	if detail, err := connect.NewErrorDetail(&errdetails.ErrorInfo{Reason: ReasonInternal, Metadata: metadata}); err == nil {
+		connectErr.AddDetail(detail)
+	}
+	return connectErr
+}
+
+// ReferenceAttrs returns the request ID and trace ID of ctx as slog
+// attributes, for log records that should match a NewInternalError.
+func ReferenceAttrs(ctx context.Context) []any {
+	ids := referenceIDs(ctx)
+	attrs := make([]any, 0, len(ids))
+	for _, id := range ids {
+		attrs = append(attrs, id.key, id.value)
+	}
+	return attrs
+}
+
+// referenceID is a named ID identifying a request.
+type referenceID struct {
+	key   string
+	value string
+}
+
+// referenceIDs returns the request ID and trace ID of ctx, if present.
+func referenceIDs(ctx context.Context) []referenceID {
+	var ids []referenceID
+	if id, ok := ctxutil.RequestID(ctx); ok && id != "" {
+		ids = append(ids, referenceID{key: "request_id", value: id})
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		ids = append(ids, referenceID{key: "trace_id", value: sc.TraceID().String()})
+	}
+	return ids
+}
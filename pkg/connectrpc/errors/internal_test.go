@@ -0,0 +1,81 @@
+package errors
+
+import (
+	"context"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestNewInternalError(t *testing.T) {
+	t.Parallel()
+
+	traceID := trace.TraceID{0x0a, 0xf7, 0x65, 0x19, 0x16, 0xcd, 0x43, 0xdd, 0x84, 0x48, 0xeb, 0x21, 0x1c, 0x80, 0x31, 0x9c}
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  trace.SpanID{1},
+	}))
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		wantMessage  string
+		wantMetadata map[string]string
+	}{
+		{
+			name:        "no ids",
+			ctx:         context.Background(),
+			wantMessage: "internal error",
+		},
+		{
+			name:         "request id",
+			ctx:          ctxutil.WithRequestID(context.Background(), "req-1"),
+			wantMessage:  "internal error (request_id: req-1)",
+			wantMetadata: map[string]string{"request_id": "req-1"},
+		},
+		{
+			name:        "request id and trace id",
+			ctx:         ctxutil.WithRequestID(traced, "req-1"),
+			wantMessage: "internal error (request_id: req-1, trace_id: 0af7651916cd43dd8448eb211c80319c)",
+			wantMetadata: map[string]string{
+				"request_id": "req-1",
+				"trace_id":   "0af7651916cd43dd8448eb211c80319c",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := NewInternalError(tt.ctx)
+
+			if err.Code() != connect.CodeInternal {
+				t.Errorf("code = %v, want %v", err.Code(), connect.CodeInternal)
+			}
+			if err.Message() != tt.wantMessage {
+				t.Errorf("message = %q, want %q", err.Message(), tt.wantMessage)
+			}
+
+			infos := Details[*errdetails.ErrorInfo](err)
+			if tt.wantMetadata == nil {
+				if len(infos) != 0 {
+					t.Errorf("ErrorInfo = %v, want none", infos)
+				}
+				return
+			}
+			if len(infos) != 1 || infos[0].GetReason() != ReasonInternal {
+				t.Fatalf("ErrorInfo = %v, want one with reason %s", infos, ReasonInternal)
+			}
+			for key, want := range tt.wantMetadata {
+				if got := infos[0].GetMetadata()[key]; got != want {
+					t.Errorf("metadata %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+// Package idempotency provides an Idempotency-Key interceptor for unary Connect RPC handlers.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// DefaultHeader is the request header carrying the idempotency key.
+const DefaultHeader = "Idempotency-Key"
+
+// ReplayedHeader is set to "true" on responses replayed from the store.
+const ReplayedHeader = "Idempotent-Replayed"
+
+// Errors returned to clients.
+var (
+	// ErrKeyReused is returned with CodeAlreadyExists when a key is reused
+	// with a different request payload.
+	ErrKeyReused = errors.New("idempotency key reused with a different request")
+
+	// ErrInProgress is returned with CodeAborted while the first call with
+	// the same key is still running. Clients may retry after a backoff.
+	ErrInProgress = errors.New("request with this idempotency key is in progress")
+
+	// ErrInvalidKey is returned with CodeInvalidArgument when the key is too long.
+	ErrInvalidKey = errors.New("idempotency key is too long")
+
+	// ErrAnonymousKey is returned with CodeUnauthenticated when a caller
+	// without a user ID sends a key, since its key could not be kept apart
+	// from other anonymous callers' keys.
+	ErrAnonymousKey = errors.New("idempotency key requires an authenticated caller")
+
+	// ErrUnknownResponseType is returned when a stored response cannot be
+	// replayed because its message type has not been registered or seen.
+	ErrUnknownResponseType = errors.New("unknown response type")
+)
+
+// Sentinel errors for invalid configuration.
+var (
+	// ErrInvalidTTL is returned when TTL is not positive.
+	ErrInvalidTTL = errors.New("ttl must be positive")
+
+	// ErrInvalidLockTimeout is returned when LockTimeout is not positive.
+	ErrInvalidLockTimeout = errors.New("lock_timeout must be positive")
+
+	// ErrInvalidMaxKeyLength is returned when MaxKeyLength is not positive.
+	ErrInvalidMaxKeyLength = errors.New("max_key_length must be positive")
+)
+
+// Config holds configuration for the idempotency interceptor.
+type Config struct {
+	// Header is the request header carrying the key.
+	// Default: DefaultHeader
+	Header string `koanf:"header"`
+
+	// TTL is how long a completed response is replayed for its key.
+	// Default: 24h
+	TTL time.Duration `koanf:"ttl"`
+
+	// LockTimeout is how long a key stays reserved while its first call runs.
+	// It bounds how long a key is blocked if the instance crashes mid-call;
+	// set it above the longest handler deadline.
+	// Default: 1m
+	LockTimeout time.Duration `koanf:"lock_timeout"`
+
+	// MaxKeyLength is the maximum key length in bytes.
+	// Default: 255
+	MaxKeyLength int `koanf:"max_key_length"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+func DefaultConfig() Config {
+	return Config{
+		Header:       DefaultHeader,
+		TTL:          24 * time.Hour,
+		LockTimeout:  time.Minute,
+		MaxKeyLength: 255,
+	}
+}
+
+// Validate checks that all fields are within range.
+// Returns nil if configuration is valid.
+func (c Config) Validate() error {
+	if c.TTL <= 0 {
+		return ErrInvalidTTL
+	}
+	if c.LockTimeout <= 0 {
+		return ErrInvalidLockTimeout
+	}
+	if c.MaxKeyLength <= 0 {
+		return ErrInvalidMaxKeyLength
+	}
+	return nil
+}
+
+// Option configures the idempotency interceptor.
+type Option func(*interceptor)
+
+// WithResponse registers the response message type T, e.g.
+// WithResponse[userv1.CreateUserResponse](), so stored responses of that
+// type can be replayed before this process has served one itself, such as
+// right after a restart. *T must be a proto.Message.
+func WithResponse[T any, PT interface {
+	*T
+	proto.Message
+}]() Option {
+	name := string(PT(new(T)).ProtoReflect().Descriptor().FullName())
+
+	return func(i *interceptor) {
+		i.responses[name] = func(data []byte) (connect.AnyResponse, error) {
+			m := PT(new(T))
+			if err := proto.Unmarshal(data, m); err != nil {
+				return nil, err
+			}
+			return connect.NewResponse(m), nil
+		}
+	}
+}
+
+// NewInterceptor creates a Connect RPC interceptor that makes unary calls
+// carrying an Idempotency-Key header safe to retry.
+//
+// The first call with a key reserves it in store and runs the handler. A
+// successful response is stored for TTL and replayed, with the
+// Idempotent-Replayed header set, for every later call with the same key
+// and payload. A failed call releases the key so it can be retried. Reusing
+// a key with a different payload fails with CodeAlreadyExists, and calls
+// arriving while the first one runs fail with CodeAborted. Keys are scoped
+// to the procedure and the caller's tenant and user ID (from ctxutil.Claims);
+// keys of callers without a user ID are rejected with CodeUnauthenticated.
+//
+// Responses are replayed by message type; types are learned from responses
+// served by this process, and WithResponse registers them up front.
+// Calls without the header, non-protobuf messages, and streams pass through.
+// Place it after validation, so invalid requests never reserve a key.
+// Returns error if cfg is invalid.
+func NewInterceptor(store Store, cfg Config, opts ...Option) (connect.Interceptor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create idempotency interceptor: %w", err)
+	}
+
+	i := &interceptor{
+		store:        store,
+		header:       cfg.Header,
+		ttl:          cfg.TTL,
+		lockTimeout:  cfg.LockTimeout,
+		maxKeyLength: cfg.MaxKeyLength,
+		responses:    make(map[string]responseFactory),
+		now:          time.Now,
+	}
+	if i.header == "" {
+		i.header = DefaultHeader
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i, nil
+}
+
+// responseFactory builds a typed connect response from a serialized message.
+type responseFactory func(data []byte) (connect.AnyResponse, error)
+
+type interceptor struct {
+	store        Store
+	header       string
+	ttl          time.Duration
+	lockTimeout  time.Duration
+	maxKeyLength int
+	now          func() time.Time
+
+	mu        sync.RWMutex
+	responses map[string]responseFactory
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		key := req.Header().Get(i.header)
+		msg, ok := req.Any().(proto.Message)
+		if key == "" || !ok {
+			return next(ctx, req)
+		}
+		if len(key) > i.maxKeyLength {
+			return nil, connect.NewError(connect.CodeInvalidArgument, ErrInvalidKey)
+		}
+		if userID, _ := ctxutil.UserID(ctx); userID == "" {
+			return nil, connect.NewError(connect.CodeUnauthenticated, ErrAnonymousKey)
+		}
+
+		fingerprint, err := fingerprint(msg)
+		if err != nil {
+			return nil, err
+		}
+		return i.call(ctx, next, req, scopedKey(ctx, req.Spec().Procedure, key), fingerprint)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// call replays the stored response for key, or runs next and stores its response.
+func (i *interceptor) call(ctx context.Context, next connect.UnaryFunc, req connect.AnyRequest, key string, fingerprint []byte) (connect.AnyResponse, error) {
+	rec, token, err := i.store.Reserve(ctx, key, fingerprint, i.now().Add(i.lockTimeout))
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		switch {
+		case !bytes.Equal(rec.Fingerprint, fingerprint):
+			return nil, connect.NewError(connect.CodeAlreadyExists, ErrKeyReused)
+		case !rec.Completed:
+			return nil, connect.NewError(connect.CodeAborted, ErrInProgress)
+		default:
+			return i.replay(rec)
+		}
+	}
+
+	// The client may have given up by the time the handler returns, which
+	// is exactly when it will retry, so the key is settled regardless.
+	storeCtx := context.WithoutCancel(ctx)
+	completed := false
+	defer func() {
+		if !completed {
+			if err := i.store.Release(storeCtx, key, token); err != nil {
+				slog.WarnContext(ctx, "idempotency key release failed", "error", err)
+			}
+		}
+	}()
+
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	completed = i.complete(storeCtx, key, token, fingerprint, resp)
+	return resp, nil
+}
+
+// complete stores resp for the key reserved with token and reports whether it was stored.
+func (i *interceptor) complete(ctx context.Context, key, token string, fingerprint []byte, resp connect.AnyResponse) bool {
+	msg, ok := resp.Any().(proto.Message)
+	if !ok {
+		return false
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		slog.WarnContext(ctx, "idempotency response encoding failed", "error", err)
+		return false
+	}
+	name := string(msg.ProtoReflect().Descriptor().FullName())
+	i.learn(name, resp)
+
+	err = i.store.Complete(ctx, key, token, Record{
+		Fingerprint:  fingerprint,
+		ResponseType: name,
+		Response:     data,
+		Header:       resp.Header().Clone(),
+		ExpiresAt:    i.now().Add(i.ttl),
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "idempotency response store failed", "error", err)
+		return false
+	}
+	return true
+}
+
+// replay rebuilds the stored response of rec.
+func (i *interceptor) replay(rec Record) (connect.AnyResponse, error) {
+	i.mu.RLock()
+	factory, ok := i.responses[rec.ResponseType]
+	i.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("replay %s: %w", rec.ResponseType, ErrUnknownResponseType)
+	}
+
+	resp, err := factory(rec.Response)
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", rec.ResponseType, err)
+	}
+	for name, values := range rec.Header {
+		resp.Header()[name] = values
+	}
+	resp.Header().Set(ReplayedHeader, "true")
+	return resp, nil
+}
+
+// learn records how to rebuild responses of type name from resp, a
+// *connect.Response[T]. Generic types cannot be instantiated at runtime,
+// so a zero value of resp's type is created and its Msg field set.
+func (i *interceptor) learn(name string, resp connect.AnyResponse) {
+	i.mu.RLock()
+	_, known := i.responses[name]
+	i.mu.RUnlock()
+	if known {
+		return
+	}
+
+	respType := reflect.TypeOf(resp)
+	msgType := reflect.TypeOf(resp.Any())
+	if respType.Kind() != reflect.Pointer || msgType.Kind() != reflect.Pointer {
+		return
+	}
+	if field, ok := respType.Elem().FieldByName("Msg"); !ok || field.Type != msgType {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.responses[name] = func(data []byte) (connect.AnyResponse, error) {
+		msg := reflect.New(msgType.Elem())
+		if err := proto.Unmarshal(data, msg.Interface().(proto.Message)); err != nil {
+			return nil, err
+		}
+		r := reflect.New(respType.Elem())
+		r.Elem().FieldByName("Msg").Set(msg)
+		return r.Interface().(connect.AnyResponse), nil
+	}
+}
+
+// fingerprint hashes the deterministic encoding of msg.
+func fingerprint(msg proto.Message) ([]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("fingerprint request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// scopedKey binds key to the procedure and caller, so keys cannot collide
+// across procedures or replay the response of another user or tenant.
+func scopedKey(ctx context.Context, procedure, key string) string {
+	tenantID, _ := ctxutil.TenantID(ctx)
+	userID, _ := ctxutil.UserID(ctx)
+	h := sha256.New()
+	for _, part := range []string{procedure, tenantID, userID, key} {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,348 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr error
+	}{
+		{name: "default config", modify: func(*Config) {}},
+		{name: "empty header", modify: func(c *Config) { c.Header = "" }},
+		{name: "zero ttl", modify: func(c *Config) { c.TTL = 0 }, wantErr: ErrInvalidTTL},
+		{name: "zero lock timeout", modify: func(c *Config) { c.LockTimeout = 0 }, wantErr: ErrInvalidLockTimeout},
+		{name: "zero max key length", modify: func(c *Config) { c.MaxKeyLength = 0 }, wantErr: ErrInvalidMaxKeyLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			if err := cfg.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInterceptor_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewInterceptor(NewInMemoryStore(), Config{})
+	if !errors.Is(err, ErrInvalidTTL) {
+		t.Errorf("NewInterceptor() error = %v, want %v", err, ErrInvalidTTL)
+	}
+}
+
+func TestInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	alice := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "alice"})
+	bob := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "bob"})
+	aliceAtAcme := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "alice", TenantID: "acme"})
+	anonymous := context.Background()
+
+	type call struct {
+		ctx      context.Context
+		key      string
+		payload  string
+		fail     bool
+		wantCode connect.Code
+		wantErr  error
+		replayed bool
+	}
+
+	tests := []struct {
+		name      string
+		calls     []call
+		wantCalls int
+	}{
+		{
+			name:      "without key every call runs",
+			calls:     []call{{payload: "a"}, {payload: "a"}},
+			wantCalls: 2,
+		},
+		{
+			name:      "duplicate is replayed",
+			calls:     []call{{key: "k1", payload: "a"}, {key: "k1", payload: "a", replayed: true}},
+			wantCalls: 1,
+		},
+		{
+			name: "different payload conflicts",
+			calls: []call{
+				{key: "k1", payload: "a"},
+				{key: "k1", payload: "b", wantCode: connect.CodeAlreadyExists, wantErr: ErrKeyReused},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:      "different keys run",
+			calls:     []call{{key: "k1", payload: "a"}, {key: "k2", payload: "a"}},
+			wantCalls: 2,
+		},
+		{
+			name:      "failed call releases key",
+			calls:     []call{{key: "k1", payload: "a", fail: true, wantCode: connect.CodeUnavailable}, {key: "k1", payload: "a"}},
+			wantCalls: 2,
+		},
+		{
+			name:      "keys are scoped to the user",
+			calls:     []call{{ctx: alice, key: "k1", payload: "a"}, {ctx: bob, key: "k1", payload: "a"}, {ctx: alice, key: "k1", payload: "a", replayed: true}},
+			wantCalls: 2,
+		},
+		{
+			name:      "keys are scoped to the tenant",
+			calls:     []call{{ctx: alice, key: "k1", payload: "a"}, {ctx: aliceAtAcme, key: "k1", payload: "a"}},
+			wantCalls: 2,
+		},
+		{
+			name: "anonymous key is rejected",
+			calls: []call{
+				{ctx: anonymous, key: "k1", payload: "a", wantCode: connect.CodeUnauthenticated, wantErr: ErrAnonymousKey},
+				{ctx: anonymous, payload: "a"},
+			},
+			wantCalls: 1,
+		},
+		{
+			name:      "key too long",
+			calls:     []call{{key: strings.Repeat("k", 256), payload: "a", wantCode: connect.CodeInvalidArgument, wantErr: ErrInvalidKey}},
+			wantCalls: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i, err := NewInterceptor(NewInMemoryStore(), DefaultConfig())
+			if err != nil {
+				t.Fatalf("NewInterceptor() error = %v", err)
+			}
+
+			calls := 0
+			for n, c := range tt.calls {
+				wrapped := i.WrapUnary(func(_ context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+					calls++
+					if c.fail {
+						return nil, connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+					}
+					resp := connect.NewResponse(wrapperspb.String("echo " + req.Any().(*wrapperspb.StringValue).GetValue()))
+					resp.Header().Set("X-Call", "original")
+					return resp, nil
+				})
+
+				ctx := c.ctx
+				if ctx == nil {
+					ctx = alice
+				}
+				req := connect.NewRequest(wrapperspb.String(c.payload))
+				if c.key != "" {
+					req.Header().Set(DefaultHeader, c.key)
+				}
+
+				resp, err := wrapped(ctx, req)
+				if c.wantCode != 0 {
+					if connect.CodeOf(err) != c.wantCode {
+						t.Fatalf("call %d: code = %v, want %v (err %v)", n, connect.CodeOf(err), c.wantCode, err)
+					}
+					if c.wantErr != nil && !errors.Is(err, c.wantErr) {
+						t.Errorf("call %d: error = %v, want %v", n, err, c.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("call %d: error = %v", n, err)
+				}
+
+				typed, ok := resp.(*connect.Response[wrapperspb.StringValue])
+				if !ok {
+					t.Fatalf("call %d: response type = %T", n, resp)
+				}
+				if got := typed.Msg.GetValue(); got != "echo "+c.payload {
+					t.Errorf("call %d: response = %q, want %q", n, got, "echo "+c.payload)
+				}
+				if got := resp.Header().Get("X-Call"); got != "original" {
+					t.Errorf("call %d: X-Call header = %q, want %q", n, got, "original")
+				}
+				if got := resp.Header().Get(ReplayedHeader) == "true"; got != c.replayed {
+					t.Errorf("call %d: replayed = %v, want %v", n, got, c.replayed)
+				}
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestInterceptor_InProgress(t *testing.T) {
+	t.Parallel()
+
+	ctx := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "alice"})
+	i, err := NewInterceptor(NewInMemoryStore(), DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewInterceptor() error = %v", err)
+	}
+
+	var nested error
+	var wrapped connect.UnaryFunc
+	wrapped = i.WrapUnary(func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if nested == nil {
+			// A duplicate arrives while the first call is still running.
+			_, nested = wrapped(ctx, req)
+		}
+		return connect.NewResponse(wrapperspb.String("ok")), nil
+	})
+
+	req := connect.NewRequest(wrapperspb.String("a"))
+	req.Header().Set(DefaultHeader, "k1")
+	if _, err := wrapped(ctx, req); err != nil {
+		t.Fatalf("first call error = %v", err)
+	}
+	if connect.CodeOf(nested) != connect.CodeAborted || !errors.Is(nested, ErrInProgress) {
+		t.Errorf("concurrent call error = %v, want aborted wrapping %v", nested, ErrInProgress)
+	}
+}
+
+func TestInterceptor_ReplayAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	ctx := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "alice"})
+	store := NewInMemoryStore()
+	handler := func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return connect.NewResponse(wrapperspb.String("created")), nil
+	}
+	newRequest := func() *connect.Request[wrapperspb.StringValue] {
+		req := connect.NewRequest(wrapperspb.String("a"))
+		req.Header().Set(DefaultHeader, "k1")
+		return req
+	}
+
+	first, err := NewInterceptor(store, DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewInterceptor() error = %v", err)
+	}
+	if _, err := first.WrapUnary(handler)(ctx, newRequest()); err != nil {
+		t.Fatalf("first call error = %v", err)
+	}
+
+	unregistered, err := NewInterceptor(store, DefaultConfig())
+	if err != nil {
+		t.Fatalf("NewInterceptor() error = %v", err)
+	}
+	if _, err := unregistered.WrapUnary(handler)(ctx, newRequest()); !errors.Is(err, ErrUnknownResponseType) {
+		t.Errorf("replay without registered type error = %v, want %v", err, ErrUnknownResponseType)
+	}
+
+	registered, err := NewInterceptor(store, DefaultConfig(), WithResponse[wrapperspb.StringValue]())
+	if err != nil {
+		t.Fatalf("NewInterceptor() error = %v", err)
+	}
+	resp, err := registered.WrapUnary(handler)(ctx, newRequest())
+	if err != nil {
+		t.Fatalf("replay error = %v", err)
+	}
+	typed, ok := resp.(*connect.Response[wrapperspb.StringValue])
+	if !ok || typed.Msg.GetValue() != "created" {
+		t.Errorf("replayed response = %v, want %q", resp.Any(), "created")
+	}
+}
+
+func TestInMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewInMemoryStore()
+	store.now = func() time.Time { return now }
+
+	token, err := reserve(ctx, store, "k1", now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, held, _ := store.Reserve(ctx, "k1", []byte("other"), now.Add(time.Minute))
+	if held != "" || rec.Completed || string(rec.Fingerprint) != "fp" {
+		t.Fatalf("Reserve() of held key = %+v, %q; want running record", rec, held)
+	}
+
+	if err := store.Complete(ctx, "k1", token, Record{Fingerprint: []byte("fp"), ExpiresAt: now.Add(time.Hour)}); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := store.Release(ctx, "k1", token); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if rec, _, _ := store.Reserve(ctx, "k1", []byte("fp"), now.Add(time.Minute)); !rec.Completed {
+		t.Error("Release() should keep completed records")
+	}
+
+	// An abandoned reservation expires after the lock timeout, and its token
+	// no longer completes or releases the key.
+	stale, err := reserve(ctx, store, "k2", now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := reserve(ctx, store, "k2", now.Add(time.Minute)); err != nil {
+		t.Errorf("Reserve() of expired key: %v", err)
+	}
+	if err := store.Complete(ctx, "k2", stale, Record{ExpiresAt: now.Add(time.Hour)}); !errors.Is(err, ErrReservationLost) {
+		t.Errorf("Complete() with stale token error = %v, want ErrReservationLost", err)
+	}
+	if err := store.Release(ctx, "k2", stale); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, held, _ := store.Reserve(ctx, "k2", []byte("fp"), now.Add(time.Minute)); held != "" {
+		t.Error("Release() with stale token should keep the new reservation")
+	}
+
+	now = now.Add(2 * time.Hour)
+	deleted, err := store.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatalf("DeleteExpired() error = %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("DeleteExpired() = %d, want 2", deleted)
+	}
+}
+
+// reserve reserves key in store and returns the reservation token.
+func reserve(ctx context.Context, store Store, key string, expiresAt time.Time) (string, error) {
+	_, token, err := store.Reserve(ctx, key, []byte("fp"), expiresAt)
+	if err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("Reserve(%q) did not reserve", key)
+	}
+	return token, nil
+}
+
+func TestNewPgStore_SchemaQualifiedTable(t *testing.T) {
+	t.Parallel()
+
+	s := NewPgStore(nil, "api.idempotency_keys")
+	if want := `"api"."idempotency_keys"`; s.table != want {
+		t.Errorf("table = %s, want %s", s.table, want)
+	}
+	if want := `"idempotency_keys_expires_at_idx"`; s.index != want {
+		t.Errorf("index = %s, want %s", s.index, want)
+	}
+}
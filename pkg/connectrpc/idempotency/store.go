@@ -0,0 +1,92 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ErrReservationLost is returned by Store.Complete when the reservation
+// token no longer holds the key, e.g. because the lock timeout passed and
+// another call reserved it.
+var ErrReservationLost = errors.New("idempotency key reservation lost")
+
+// Record is the stored state of an idempotency key.
+type Record struct {
+	// Fingerprint is the SHA-256 hash of the first request's payload.
+	Fingerprint []byte
+
+	// Completed is false while the first call is still running.
+	Completed bool
+
+	// ResponseType is the full protobuf name of the response message.
+	ResponseType string
+
+	// Response is the serialized response message.
+	Response []byte
+
+	// Header holds the response headers set by the handler.
+	Header http.Header
+
+	// ExpiresAt is when the key may be reused. For running calls it is the
+	// lock timeout, after which a crashed call's key is released.
+	ExpiresAt time.Time
+}
+
+// Store persists idempotency keys and their responses.
+// Implementations must be safe for concurrent use and shared by all
+// instances that serve the same clients.
+type Store interface {
+	// Reserve claims key for a call with fingerprint until expiresAt and
+	// returns a reservation token identifying the call.
+	// If the key is held by an unexpired record, Reserve returns that record
+	// and an empty token.
+	Reserve(ctx context.Context, key string, fingerprint []byte, expiresAt time.Time) (Record, string, error)
+
+	// Complete stores the response of key if token still holds its reservation.
+	// Returns ErrReservationLost otherwise.
+	Complete(ctx context.Context, key, token string, rec Record) error
+
+	// Release removes the reservation of a call that failed, so the key can be
+	// retried. It does nothing unless token still holds the reservation.
+	Release(ctx context.Context, key, token string) error
+
+	// DeleteExpired removes expired records and returns how many were removed.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// StartCleanup deletes expired records from store every interval until ctx is done.
+// Failures are logged and retried on the next tick.
+func StartCleanup(ctx context.Context, store Store, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := store.DeleteExpired(ctx)
+				if err != nil {
+					slog.WarnContext(ctx, "idempotency cleanup failed", "error", err)
+					continue
+				}
+				if n > 0 {
+					slog.DebugContext(ctx, "idempotency keys expired", "count", n)
+				}
+			}
+		}
+	}()
+}
+
+// newReservationToken returns a random reservation token.
+func newReservationToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,110 @@
+package idempotency
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// InMemoryStore implements Store with a map.
+// Intended for tests and single-instance services; keys are lost on restart.
+type InMemoryStore struct {
+	mu      sync.Mutex
+	records map[string]inMemoryRecord
+	now     func() time.Time
+}
+
+// inMemoryRecord is a record with the token of the call that reserved it.
+type inMemoryRecord struct {
+	Record
+	token string
+}
+
+// NewInMemoryStore creates an empty store.
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		records: make(map[string]inMemoryRecord),
+		now:     time.Now,
+	}
+}
+
+// Reserve claims key unless an unexpired record holds it.
+func (s *InMemoryStore) Reserve(_ context.Context, key string, fingerprint []byte, expiresAt time.Time) (Record, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rec, ok := s.records[key]; ok && s.now().Before(rec.ExpiresAt) {
+		return clone(rec.Record), "", nil
+	}
+	token := newReservationToken()
+	s.records[key] = inMemoryRecord{
+		Record: Record{
+			Fingerprint: append([]byte(nil), fingerprint...),
+			ExpiresAt:   expiresAt,
+		},
+		token: token,
+	}
+	return Record{}, token, nil
+}
+
+// Complete stores the response of key if token still holds its reservation.
+func (s *InMemoryStore) Complete(_ context.Context, key, token string, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.holds(key, token) {
+		return ErrReservationLost
+	}
+	rec.Completed = true
+	s.records[key] = inMemoryRecord{Record: clone(rec), token: token}
+	return nil
+}
+
+// Release removes the reservation of key if token still holds it.
+// Completed records are kept.
+func (s *InMemoryStore) Release(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.holds(key, token) {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// holds reports whether token holds the running reservation of key.
+// Callers must hold s.mu.
+func (s *InMemoryStore) holds(key, token string) bool {
+	rec, ok := s.records[key]
+	return ok && !rec.Completed && rec.token == token
+}
+
+// DeleteExpired removes expired records.
+func (s *InMemoryStore) DeleteExpired(_ context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	before := len(s.records)
+	maps.DeleteFunc(s.records, func(_ string, rec inMemoryRecord) bool {
+		return !now.Before(rec.ExpiresAt)
+	})
+	return int64(before - len(s.records)), nil
+}
+
+// clone copies the slices and header of rec, so callers cannot modify stored records.
+func clone(rec Record) Record {
+	rec.Fingerprint = append([]byte(nil), rec.Fingerprint...)
+	rec.Response = append([]byte(nil), rec.Response...)
+	if rec.Header != nil {
+		rec.Header = rec.Header.Clone()
+	} else {
+		rec.Header = http.Header{}
+	}
+	return rec
+}
+
+// compile-time check
+var _ Store = (*InMemoryStore)(nil)
@@ -0,0 +1,182 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/deepworx/go-utils/pkg/postgres"
+)
+
+// DefaultTable is the table used by PgStore when none is configured.
+const DefaultTable = "idempotency_keys"
+
+// PgStore implements Store using a PostgreSQL table.
+type PgStore struct {
+	pool  *pgxpool.Pool
+	table string
+	index string
+}
+
+// NewPgStore creates a store backed by the given pool, typically from postgres.NewPool.
+// An empty table uses DefaultTable; it may be schema-qualified ("schema.table").
+// Call CreateTable to create the table.
+func NewPgStore(pool *pgxpool.Pool, table string) *PgStore {
+	if table == "" {
+		table = DefaultTable
+	}
+	// Index names cannot be schema-qualified; the index lives in the table's schema.
+	name := table[strings.LastIndex(table, ".")+1:]
+	return &PgStore{
+		pool:  pool,
+		table: postgres.QuoteTable(table),
+		index: pgx.Identifier{name + "_expires_at_idx"}.Sanitize(),
+	}
+}
+
+// CreateTable creates the table and its expiry index if they do not exist.
+func (s *PgStore) CreateTable(ctx context.Context) error {
+	err := postgres.WithTx(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+s.table+` (
+			key           TEXT PRIMARY KEY,
+			token         TEXT NOT NULL,
+			fingerprint   BYTEA NOT NULL,
+			completed     BOOLEAN NOT NULL DEFAULT false,
+			response_type TEXT,
+			response      BYTEA,
+			header        JSONB,
+			created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+			expires_at    TIMESTAMPTZ NOT NULL
+		)`); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `CREATE INDEX IF NOT EXISTS `+s.index+` ON `+s.table+` (expires_at)`)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("create idempotency table: %w", err)
+	}
+	return nil
+}
+
+// Reserve claims key unless an unexpired record holds it. Expired records are replaced.
+func (s *PgStore) Reserve(ctx context.Context, key string, fingerprint []byte, expiresAt time.Time) (Record, string, error) {
+	token := newReservationToken()
+
+	// The existing record can expire or be released between the two
+	// statements; one more attempt then claims the key.
+	for range 2 {
+		var reserved bool
+		err := s.pool.QueryRow(ctx, `INSERT INTO `+s.table+` (key, token, fingerprint, expires_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (key) DO UPDATE SET
+				token         = EXCLUDED.token,
+				fingerprint   = EXCLUDED.fingerprint,
+				completed     = false,
+				response_type = NULL,
+				response      = NULL,
+				header        = NULL,
+				created_at    = now(),
+				expires_at    = EXCLUDED.expires_at
+			WHERE `+s.table+`.expires_at <= now()
+			RETURNING true`,
+			key, token, fingerprint, expiresAt).Scan(&reserved)
+		if err == nil {
+			return Record{}, token, nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return Record{}, "", fmt.Errorf("reserve idempotency key: %w", err)
+		}
+
+		rec, found, err := s.get(ctx, key)
+		if err != nil {
+			return Record{}, "", err
+		}
+		if found {
+			return rec, "", nil
+		}
+	}
+	return Record{}, "", fmt.Errorf("reserve idempotency key: concurrent release")
+}
+
+// get loads the unexpired record of key.
+func (s *PgStore) get(ctx context.Context, key string) (Record, bool, error) {
+	var (
+		rec          Record
+		responseType *string
+		header       []byte
+	)
+	err := s.pool.QueryRow(ctx, `SELECT fingerprint, completed, response_type, response, header, expires_at
+		FROM `+s.table+` WHERE key = $1 AND expires_at > now()`, key).
+		Scan(&rec.Fingerprint, &rec.Completed, &responseType, &rec.Response, &header, &rec.ExpiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Record{}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, fmt.Errorf("load idempotency key: %w", err)
+	}
+
+	if responseType != nil {
+		rec.ResponseType = *responseType
+	}
+	rec.Header = http.Header{}
+	if header != nil {
+		if err := json.Unmarshal(header, &rec.Header); err != nil {
+			return Record{}, false, fmt.Errorf("decode idempotency response header: %w", err)
+		}
+	}
+	return rec, true, nil
+}
+
+// Complete stores the response of key if token still holds its reservation.
+func (s *PgStore) Complete(ctx context.Context, key, token string, rec Record) error {
+	header, err := json.Marshal(rec.Header)
+	if err != nil {
+		return fmt.Errorf("encode idempotency response header: %w", err)
+	}
+
+	tag, err := s.pool.Exec(ctx, `UPDATE `+s.table+` SET
+			completed     = true,
+			response_type = $3,
+			response      = $4,
+			header        = $5,
+			expires_at    = $6
+		WHERE key = $1 AND token = $2 AND NOT completed`,
+		key, token, rec.ResponseType, rec.Response, header, rec.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("complete idempotency key: %w", ErrReservationLost)
+	}
+	return nil
+}
+
+// Release removes the reservation of key if token still holds it.
+// Completed records are kept.
+func (s *PgStore) Release(ctx context.Context, key, token string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM `+s.table+` WHERE key = $1 AND token = $2 AND NOT completed`, key, token)
+	if err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired removes expired records.
+func (s *PgStore) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM `+s.table+` WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired idempotency keys: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// compile-time check
+var _ Store = (*PgStore)(nil)
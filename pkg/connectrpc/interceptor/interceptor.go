@@ -1,4 +1,4 @@
-// Package interceptor provides default interceptor chain builders for Connect RPC services.
+// Package interceptor provides default interceptor chain builders for Connect RPC services and clients.
 package interceptor
 
 import (
@@ -8,18 +8,42 @@ import (
 	"connectrpc.com/otelconnect"
 	"connectrpc.com/validate"
 
+	"github.com/deepworx/go-utils/pkg/connectrpc/authz"
+	"github.com/deepworx/go-utils/pkg/connectrpc/circuitbreaker"
 	"github.com/deepworx/go-utils/pkg/connectrpc/deadline"
 	"github.com/deepworx/go-utils/pkg/connectrpc/errors"
+	"github.com/deepworx/go-utils/pkg/connectrpc/idempotency"
 	"github.com/deepworx/go-utils/pkg/connectrpc/jwtauth"
+	"github.com/deepworx/go-utils/pkg/connectrpc/loadshed"
 	"github.com/deepworx/go-utils/pkg/connectrpc/logging"
+	"github.com/deepworx/go-utils/pkg/connectrpc/metrics"
+	"github.com/deepworx/go-utils/pkg/connectrpc/ratelimit"
 	"github.com/deepworx/go-utils/pkg/connectrpc/recovery"
+	"github.com/deepworx/go-utils/pkg/connectrpc/report"
 	"github.com/deepworx/go-utils/pkg/connectrpc/requestid"
+	"github.com/deepworx/go-utils/pkg/connectrpc/retry"
+	"github.com/deepworx/go-utils/pkg/connectrpc/tokensource"
 )
 
 // Options configures the interceptor chain.
 type Options struct {
 	deadlineCfg  *deadline.Config
 	requestIDCfg *requestid.Config
+	rateLimitCfg *ratelimit.Config
+	loadShedCfg  *loadshed.Config
+	idemStore    idempotency.Store
+	idemCfg      idempotency.Config
+	idemOpts     []idempotency.Option
+	metricsCfg   *metrics.Config
+	authzCfg     *authz.Config
+	authModesCfg *jwtauth.InterceptorConfig
+	extractors   []jwtauth.TokenExtractor
+	dpopCfg      *jwtauth.DPoPConfig
+	dpopStore    jwtauth.ReplayStore
+	retryCfg     *retry.Config
+	breakers     *circuitbreaker.Interceptor
+	tokenSource  *tokensource.TokenSource
+	reporter     report.Reporter
 }
 
 // Option configures the interceptor builder.
@@ -39,20 +63,143 @@ func WithRequestID(cfg requestid.Config) Option {
 	}
 }
 
+// WithRateLimit enables the rate limit interceptor with the given configuration.
+// It is placed after jwtauth so that per-user and per-tenant limits apply.
+func WithRateLimit(cfg ratelimit.Config) Option {
+	return func(o *Options) {
+		o.rateLimitCfg = &cfg
+	}
+}
+
+// WithLoadShedding enables the load shedding interceptor with the given configuration.
+// It is placed after ratelimit, so abusive callers are rejected before they
+// take a concurrency slot, and after jwtauth so role priorities apply.
+func WithLoadShedding(cfg loadshed.Config) Option {
+	return func(o *Options) {
+		o.loadShedCfg = &cfg
+	}
+}
+
+// WithIdempotency enables the idempotency interceptor with the given store and configuration.
+// It is placed after validate, so invalid requests never reserve a key.
+func WithIdempotency(store idempotency.Store, cfg idempotency.Config, opts ...idempotency.Option) Option {
+	return func(o *Options) {
+		o.idemStore = store
+		o.idemCfg = cfg
+		o.idemOpts = opts
+	}
+}
+
+// WithMetrics enables the RPC metrics interceptor with the given configuration.
+// It is placed before jwtauth, so requests rejected by jwtauth or authz are
+// counted too; tenant labels are resolved from the claims once the rest of
+// the chain has returned.
+func WithMetrics(cfg metrics.Config) Option {
+	return func(o *Options) {
+		o.metricsCfg = &cfg
+	}
+}
+
+// WithAuthz enables the authorization interceptor with the given policy table.
+// It is inserted right after jwtauth and is only valid with BuildDefaultWithAuth.
+func WithAuthz(cfg authz.Config) Option {
+	return func(o *Options) {
+		o.authzCfg = &cfg
+	}
+}
+
+// WithAuthModes sets per-procedure jwtauth modes (required, optional, skip).
+// Only valid with BuildDefaultWithAuth.
+func WithAuthModes(cfg jwtauth.InterceptorConfig) Option {
+	return func(o *Options) {
+		o.authModesCfg = &cfg
+	}
+}
+
+// WithTokenExtractors sets where jwtauth looks for the bearer token
+// (Authorization header, cookie, custom header, query parameter).
+// Only valid with BuildDefaultWithAuth.
+func WithTokenExtractors(extractors ...jwtauth.TokenExtractor) Option {
+	return func(o *Options) {
+		o.extractors = extractors
+	}
+}
+
+// WithDPoP enables DPoP proof validation in jwtauth (see jwtauth.WithDPoP).
+// A nil store uses an in-memory replay store.
+// Only valid with BuildDefaultWithAuth and a *jwtauth.Authenticator or *jwtauth.Introspector.
+func WithDPoP(cfg jwtauth.DPoPConfig, store jwtauth.ReplayStore) Option {
+	return func(o *Options) {
+		o.dpopCfg = &cfg
+		o.dpopStore = store
+	}
+}
+
+// WithRetry enables the retry interceptor with the given configuration.
+// It is placed after otel so that every attempt is recorded in the client span.
+// Only applies to BuildClientDefault.
+func WithRetry(cfg retry.Config) Option {
+	return func(o *Options) {
+		o.retryCfg = &cfg
+	}
+}
+
+// WithCircuitBreaker adds the given circuit breaker interceptor. It takes an
+// instance rather than a configuration so that its breakers can be registered
+// as health checkers. It is placed before retry, so a call counts once however
+// many attempts it took, and open breakers skip the retry loop.
+// Only applies to BuildClientDefault.
+func WithCircuitBreaker(breakers *circuitbreaker.Interceptor) Option {
+	return func(o *Options) {
+		o.breakers = breakers
+	}
+}
+
+// WithTokenSource authorizes outgoing calls with access tokens from source.
+// It is placed after retry so that every attempt carries a current token.
+// Only applies to BuildClientDefault.
+func WithTokenSource(source *tokensource.TokenSource) Option {
+	return func(o *Options) {
+		o.tokenSource = source
+	}
+}
+
+// WithReporter reports sanitized errors and recovered panics to reporter.
+// On the server builders it also adds a second recovery interceptor after
+// errors, so that panics in handlers are reported with the request ID,
+// claims and span of the request.
+func WithReporter(reporter report.Reporter) Option {
+	return func(o *Options) {
+		o.reporter = reporter
+	}
+}
+
 // BuildDefault creates a standard interceptor chain without authentication.
-// Returns interceptors in order: recovery, deadline, requestid, otel, logging, validate, errors.
+// Returns interceptors in order: recovery, deadline, requestid, otel, logging, [metrics], [ratelimit], [loadshed], validate, [idempotency], errors, [recovery].
+// Returns error if WithAuthz, WithAuthModes, WithTokenExtractors or WithDPoP
+// is set, since they require authentication.
 func BuildDefault(opts ...Option) ([]connect.Interceptor, error) {
 	o := &Options{}
 	for _, opt := range opts {
 		opt(o)
 	}
+	if o.authzCfg != nil {
+		return nil, fmt.Errorf("build interceptors: authz requires an authenticator")
+	}
+	if o.authModesCfg != nil || len(o.extractors) > 0 || o.dpopCfg != nil {
+		return nil, fmt.Errorf("build interceptors: auth modes, token extractors and dpop require an authenticator")
+	}
+	if o.retryCfg != nil || o.breakers != nil || o.tokenSource != nil {
+		return nil, fmt.Errorf("build interceptors: retry, circuit breaker and token source only apply to clients")
+	}
 	return buildChain(o, nil)
 }
 
-// BuildDefaultWithAuth creates a standard interceptor chain with JWT authentication.
-// Returns interceptors in order: recovery, deadline, requestid, otel, logging, jwtauth, validate, errors.
+// BuildDefaultWithAuth creates a standard interceptor chain with token authentication.
+// auth is typically a *jwtauth.Authenticator (JWT) or *jwtauth.Introspector (opaque tokens).
+// Returns interceptors in order: recovery, deadline, requestid, otel, logging, [metrics], jwtauth, [authz], [ratelimit], [loadshed], validate, [idempotency], errors, [recovery].
 // Returns error if auth is nil.
-func BuildDefaultWithAuth(auth *jwtauth.Authenticator, opts ...Option) ([]connect.Interceptor, error) {
+func BuildDefaultWithAuth(auth jwtauth.TokenAuthenticator, opts ...Option) ([]connect.Interceptor, error) {
 	if auth == nil {
 		return nil, fmt.Errorf("build interceptors: authenticator is required")
 	}
@@ -60,14 +207,91 @@ func BuildDefaultWithAuth(auth *jwtauth.Authenticator, opts ...Option) ([]connec
 	for _, opt := range opts {
 		opt(o)
 	}
+	if o.retryCfg != nil || o.breakers != nil || o.tokenSource != nil {
+		return nil, fmt.Errorf("build interceptors: retry, circuit breaker and token source only apply to clients")
+	}
 	return buildChain(o, auth)
 }
 
-func buildChain(o *Options, auth *jwtauth.Authenticator) ([]connect.Interceptor, error) {
-	interceptors := make([]connect.Interceptor, 0, 8)
+// BuildClientDefault creates a standard interceptor chain for Connect clients,
+// for use with connect.WithInterceptors when constructing a client.
+// Returns interceptors in order: recovery, errors, deadline, requestid, otel, [circuitbreaker], [retry], [tokensource], logging.
+//
+// The deadline interceptor applies the deadline configuration to outbound calls,
+// requestid forwards ctxutil.RequestID in the configured header, circuitbreaker
+// fails fast while the downstream is failing, retry repeats failed idempotent
+// calls, tokensource sets the Authorization header, and errors returns every
+// failure, including those of the other interceptors, as a *connect.Error.
+// The first interceptor in connect.WithInterceptors is the outermost, so
+// errors comes right after recovery.
+// Only WithDeadline, WithRequestID, WithCircuitBreaker, WithRetry,
+// WithTokenSource and WithReporter apply; other options return an error.
+func BuildClientDefault(opts ...Option) ([]connect.Interceptor, error) {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.authzCfg != nil || o.authModesCfg != nil || len(o.extractors) > 0 || o.dpopCfg != nil ||
+		o.rateLimitCfg != nil || o.loadShedCfg != nil || o.idemStore != nil || o.metricsCfg != nil {
+		return nil, fmt.Errorf("build client interceptors: only deadline, request ID, circuit breaker, retry, token source and reporter options apply to clients")
+	}
+
+	deadlineCfg := deadline.DefaultConfig()
+	if o.deadlineCfg != nil {
+		deadlineCfg = *o.deadlineCfg
+	}
+	requestIDCfg := requestid.DefaultConfig()
+	if o.requestIDCfg != nil {
+		requestIDCfg = *o.requestIDCfg
+	}
+
+	// OTel injects trace context into the outbound headers
+	otelInterceptor, err := otelconnect.NewInterceptor()
+	if err != nil {
+		return nil, fmt.Errorf("create otel interceptor: %w", err)
+	}
+
+	interceptors := []connect.Interceptor{
+		// 1. Recovery - catches panics from downstream interceptors
+		recovery.NewInterceptor(recoveryOpts(o)...),
+		// 2. Errors - returns failures of all downstream interceptors as *connect.Error
+		errors.NewClientInterceptor(),
+		// 3. Deadline - bounds the call, including all retries, before any work starts
+		deadline.NewClientInterceptor(deadlineCfg),
+		// 4. RequestID - forwards or generates the ID before logging/tracing uses it
+		requestid.NewClientInterceptor(requestIDCfg),
+		// 5. OTel - client span around the remaining chain
+		otelInterceptor,
+	}
+
+	// 6. CircuitBreaker (optional) - fails fast before any attempt is made
+	if o.breakers != nil {
+		interceptors = append(interceptors, o.breakers)
+	}
+
+	// 7. Retry (optional) - repeats idempotent calls within the client span
+	if o.retryCfg != nil {
+		retryInterceptor, err := retry.NewInterceptor(*o.retryCfg)
+		if err != nil {
+			return nil, fmt.Errorf("create retry interceptor: %w", err)
+		}
+		interceptors = append(interceptors, retryInterceptor)
+	}
+
+	// 8. TokenSource (optional) - authorizes every attempt with a current token
+	if o.tokenSource != nil {
+		interceptors = append(interceptors, tokensource.NewInterceptor(o.tokenSource))
+	}
+
+	// 9. Logging - logs every attempt with request ID context
+	return append(interceptors, logging.NewInterceptor()), nil
+}
+
+func buildChain(o *Options, auth jwtauth.TokenAuthenticator) ([]connect.Interceptor, error) {
+	interceptors := make([]connect.Interceptor, 0, 14)
 
 	// 1. Recovery - always first, catches panics from all downstream
-	interceptors = append(interceptors, recovery.NewInterceptor())
+	interceptors = append(interceptors, recovery.NewInterceptor(recoveryOpts(o)...))
 
 	// 2. Deadline - enforces timeouts early
 	deadlineCfg := deadline.DefaultConfig()
@@ -93,16 +317,87 @@ func buildChain(o *Options, auth *jwtauth.Authenticator) ([]connect.Interceptor,
 	// 5. Logging - logs with request ID context
 	interceptors = append(interceptors, logging.NewInterceptor())
 
-	// 6. Auth (optional) - validates JWT after observability setup
+	// 6. Metrics (optional) - counts every request, including auth rejections
+	if o.metricsCfg != nil {
+		metricsInterceptor, err := metrics.NewInterceptor(*o.metricsCfg)
+		if err != nil {
+			return nil, fmt.Errorf("create metrics interceptor: %w", err)
+		}
+		interceptors = append(interceptors, metricsInterceptor)
+	}
+
+	// 7. Auth (optional) - validates the token after observability setup
 	if auth != nil {
-		interceptors = append(interceptors, jwtauth.NewInterceptor(auth))
+		var authOpts []jwtauth.InterceptorOption
+		if o.authModesCfg != nil {
+			authOpts = append(authOpts, jwtauth.WithInterceptorConfig(*o.authModesCfg))
+		}
+		if len(o.extractors) > 0 {
+			authOpts = append(authOpts, jwtauth.WithTokenExtractors(o.extractors...))
+		}
+		if o.dpopCfg != nil {
+			authOpts = append(authOpts, jwtauth.WithDPoP(*o.dpopCfg, o.dpopStore))
+		}
+		authInterceptor, err := jwtauth.NewValidatedInterceptor(auth, authOpts...)
+		if err != nil {
+			return nil, err
+		}
+		interceptors = append(interceptors, authInterceptor)
+	}
+
+	// 8. Authz (optional) - enforces the policy table on authenticated claims
+	if o.authzCfg != nil {
+		authzInterceptor, err := authz.NewInterceptor(*o.authzCfg)
+		if err != nil {
+			return nil, fmt.Errorf("create authz interceptor: %w", err)
+		}
+		interceptors = append(interceptors, authzInterceptor)
 	}
 
-	// 7. Validate - validates request payloads after auth
+	// 9. RateLimit (optional) - rejects excess traffic once the caller is known
+	if o.rateLimitCfg != nil {
+		interceptors = append(interceptors, ratelimit.NewInterceptor(*o.rateLimitCfg))
+	}
+
+	// 10. LoadShed (optional) - bounds in-flight requests for the rest of the chain
+	if o.loadShedCfg != nil {
+		loadShedInterceptor, err := loadshed.NewInterceptor(*o.loadShedCfg)
+		if err != nil {
+			return nil, fmt.Errorf("create load shedding interceptor: %w", err)
+		}
+		interceptors = append(interceptors, loadShedInterceptor)
+	}
+
+	// 11. Validate - validates request payloads after auth
 	interceptors = append(interceptors, validate.NewInterceptor())
 
-	// 8. Errors - always last, maps all errors to Connect codes
-	interceptors = append(interceptors, errors.NewInterceptor())
+	// 12. Idempotency (optional) - replays stored responses of valid requests
+	if o.idemStore != nil {
+		idempotencyInterceptor, err := idempotency.NewInterceptor(o.idemStore, o.idemCfg, o.idemOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("create idempotency interceptor: %w", err)
+		}
+		interceptors = append(interceptors, idempotencyInterceptor)
+	}
+
+	// 13. Errors - maps all errors to Connect codes
+	var errorsOpts []errors.Option
+	if o.reporter != nil {
+		errorsOpts = append(errorsOpts, errors.WithReporter(o.reporter))
+	}
+	interceptors = append(interceptors, errors.NewInterceptor(errorsOpts...))
+
+	// 14. Recovery (optional) - reports handler panics with the request context
+	if o.reporter != nil {
+		interceptors = append(interceptors, recovery.NewInterceptor(recovery.WithReporter(o.reporter)))
+	}
 
 	return interceptors, nil
 }
+
+func recoveryOpts(o *Options) []recovery.Option {
+	if o.reporter == nil {
+		return nil
+	}
+	return []recovery.Option{recovery.WithReporter(o.reporter)}
+}
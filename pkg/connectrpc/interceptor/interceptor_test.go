@@ -1,11 +1,31 @@
 package interceptor
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
 	"testing"
 
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/authz"
+	"github.com/deepworx/go-utils/pkg/connectrpc/circuitbreaker"
 	"github.com/deepworx/go-utils/pkg/connectrpc/deadline"
+	"github.com/deepworx/go-utils/pkg/connectrpc/idempotency"
 	"github.com/deepworx/go-utils/pkg/connectrpc/jwtauth"
+	"github.com/deepworx/go-utils/pkg/connectrpc/loadshed"
+	"github.com/deepworx/go-utils/pkg/connectrpc/metrics"
+	"github.com/deepworx/go-utils/pkg/connectrpc/ratelimit"
+	"github.com/deepworx/go-utils/pkg/connectrpc/report"
 	"github.com/deepworx/go-utils/pkg/connectrpc/requestid"
+	"github.com/deepworx/go-utils/pkg/connectrpc/retry"
+	"github.com/deepworx/go-utils/pkg/connectrpc/tokensource"
+	"github.com/deepworx/go-utils/pkg/ctxutil"
 )
 
 func TestBuildDefault(t *testing.T) {
@@ -40,6 +60,31 @@ func TestBuildDefault(t *testing.T) {
 			},
 			wantCount: 7,
 		},
+		{
+			name:      "with rate limit",
+			opts:      []Option{WithRateLimit(ratelimit.DefaultConfig())},
+			wantCount: 8,
+		},
+		{
+			name:      "with reporter",
+			opts:      []Option{WithReporter(report.NewSpanReporter())},
+			wantCount: 8,
+		},
+		{
+			name:      "with metrics",
+			opts:      []Option{WithMetrics(metrics.DefaultConfig())},
+			wantCount: 8,
+		},
+		{
+			name:      "with load shedding",
+			opts:      []Option{WithLoadShedding(loadshed.DefaultConfig())},
+			wantCount: 8,
+		},
+		{
+			name:      "with idempotency",
+			opts:      []Option{WithIdempotency(idempotency.NewInMemoryStore(), idempotency.DefaultConfig())},
+			wantCount: 8,
+		},
 		{
 			name: "with all options",
 			opts: []Option{
@@ -117,3 +162,345 @@ func TestBuildDefaultWithAuth_WithOptions(t *testing.T) {
 		t.Errorf("BuildDefaultWithAuth() returned %d interceptors, want 8", len(interceptors))
 	}
 }
+
+func TestBuildDefaultWithAuth_MetricsBeforeAuth(t *testing.T) {
+	t.Parallel()
+
+	interceptors, err := BuildDefaultWithAuth(&jwtauth.Authenticator{}, WithMetrics(metrics.DefaultConfig()))
+	if err != nil {
+		t.Fatalf("BuildDefaultWithAuth() error = %v", err)
+	}
+
+	// metrics must wrap jwtauth so that auth rejections are counted
+	got := []string{fmt.Sprintf("%T", interceptors[5]), fmt.Sprintf("%T", interceptors[6])}
+	want := []string{"*metrics.interceptor", "*jwtauth.interceptor"}
+	if !slices.Equal(got, want) {
+		t.Errorf("interceptors[5:7] = %v, want %v", got, want)
+	}
+}
+
+func TestBuildDefault_AuthOptionsWithoutAuth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opt  Option
+	}{
+		{name: "authz", opt: WithAuthz(authz.DefaultConfig())},
+		{name: "auth modes", opt: WithAuthModes(jwtauth.DefaultInterceptorConfig())},
+		{name: "token extractors", opt: WithTokenExtractors(jwtauth.FromCookie("session"))},
+		{name: "dpop", opt: WithDPoP(jwtauth.DefaultDPoPConfig(), nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := BuildDefault(tt.opt); err == nil {
+				t.Fatal("BuildDefault() should return error without an authenticator")
+			}
+		})
+	}
+}
+
+func TestBuildDefaultWithAuth_WithAuthz(t *testing.T) {
+	t.Parallel()
+
+	auth := &jwtauth.Authenticator{}
+
+	interceptors, err := BuildDefaultWithAuth(auth, WithAuthz(authz.Config{
+		Policies: []authz.Policy{{Procedure: "/test.Service/Method", Roles: []string{"admin"}}},
+	}))
+	if err != nil {
+		t.Fatalf("BuildDefaultWithAuth() error = %v", err)
+	}
+	if len(interceptors) != 9 {
+		t.Errorf("BuildDefaultWithAuth() returned %d interceptors, want 9", len(interceptors))
+	}
+}
+
+func TestBuildDefaultWithAuth_InvalidAuthz(t *testing.T) {
+	t.Parallel()
+
+	auth := &jwtauth.Authenticator{}
+
+	_, err := BuildDefaultWithAuth(auth, WithAuthz(authz.Config{
+		Policies: []authz.Policy{{Roles: []string{"admin"}}},
+	}))
+	if err == nil {
+		t.Fatal("BuildDefaultWithAuth() with invalid authz config should return error")
+	}
+}
+
+func TestBuildDefaultWithAuth_WithAuthModes(t *testing.T) {
+	t.Parallel()
+
+	auth := &jwtauth.Authenticator{}
+
+	interceptors, err := BuildDefaultWithAuth(auth, WithAuthModes(jwtauth.InterceptorConfig{
+		Procedures: []jwtauth.ProcedureMode{{Procedure: "/test.Service/", Mode: jwtauth.ModeOptional}},
+	}))
+	if err != nil {
+		t.Fatalf("BuildDefaultWithAuth() error = %v", err)
+	}
+	if len(interceptors) != 8 {
+		t.Errorf("BuildDefaultWithAuth() returned %d interceptors, want 8", len(interceptors))
+	}
+
+	_, err = BuildDefaultWithAuth(auth, WithAuthModes(jwtauth.InterceptorConfig{DefaultMode: "public"}))
+	if !errors.Is(err, jwtauth.ErrInvalidMode) {
+		t.Errorf("BuildDefaultWithAuth() error = %v, want %v", err, jwtauth.ErrInvalidMode)
+	}
+}
+
+func TestBuildDefaultWithAuth_Introspector(t *testing.T) {
+	t.Parallel()
+
+	auth := &jwtauth.Introspector{}
+
+	interceptors, err := BuildDefaultWithAuth(auth, WithTokenExtractors(
+		jwtauth.FromAuthorizationHeader(),
+		jwtauth.FromCookie("session"),
+	))
+	if err != nil {
+		t.Fatalf("BuildDefaultWithAuth() error = %v", err)
+	}
+	if len(interceptors) != 8 {
+		t.Errorf("BuildDefaultWithAuth() returned %d interceptors, want 8", len(interceptors))
+	}
+}
+
+func TestBuildDefaultWithAuth_WithDPoP(t *testing.T) {
+	t.Parallel()
+
+	auth := &jwtauth.Authenticator{}
+
+	interceptors, err := BuildDefaultWithAuth(auth, WithDPoP(jwtauth.DefaultDPoPConfig(), nil))
+	if err != nil {
+		t.Fatalf("BuildDefaultWithAuth() error = %v", err)
+	}
+	if len(interceptors) != 8 {
+		t.Errorf("BuildDefaultWithAuth() returned %d interceptors, want 8", len(interceptors))
+	}
+
+	_, err = BuildDefaultWithAuth(auth, WithDPoP(jwtauth.DPoPConfig{BaseURL: "api.example.com"}, nil))
+	if !errors.Is(err, jwtauth.ErrInvalidBaseURL) {
+		t.Errorf("BuildDefaultWithAuth() error = %v, want %v", err, jwtauth.ErrInvalidBaseURL)
+	}
+
+	_, err = BuildDefaultWithAuth(tokenAuthenticatorFunc(nil), WithDPoP(jwtauth.DefaultDPoPConfig(), nil))
+	if !errors.Is(err, jwtauth.ErrDPoPNotSupported) {
+		t.Errorf("BuildDefaultWithAuth() error = %v, want %v", err, jwtauth.ErrDPoPNotSupported)
+	}
+}
+
+// tokenAuthenticatorFunc is a jwtauth.TokenAuthenticator that supports no DPoP.
+type tokenAuthenticatorFunc func(ctx context.Context, token string) (ctxutil.Claims, error)
+
+func (f tokenAuthenticatorFunc) Authenticate(ctx context.Context, token string) (ctxutil.Claims, error) {
+	return f(ctx, token)
+}
+
+func TestBuildClientDefault(t *testing.T) {
+	t.Parallel()
+
+	breakers, err := circuitbreaker.NewInterceptor(circuitbreaker.DefaultConfig())
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewInterceptor() error = %v", err)
+	}
+	source, err := tokensource.NewTokenSource(tokensource.Config{TokenURL: "https://idp.example.com/token", ClientID: "svc"})
+	if err != nil {
+		t.Fatalf("tokensource.NewTokenSource() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		wantCount int
+		wantErr   bool
+	}{
+		{name: "default config", wantCount: 6},
+		{
+			name: "with deadline and request ID",
+			opts: []Option{
+				WithDeadline(deadline.Config{DefaultTimeout: 5_000_000_000}),
+				WithRequestID(requestid.Config{HeaderName: "X-Correlation-ID"}),
+			},
+			wantCount: 6,
+		},
+		{name: "with retry", opts: []Option{WithRetry(retry.DefaultConfig())}, wantCount: 7},
+		{name: "with invalid retry", opts: []Option{WithRetry(retry.Config{})}, wantErr: true},
+		{name: "with circuit breaker", opts: []Option{WithCircuitBreaker(breakers)}, wantCount: 7},
+		{
+			name:      "with circuit breaker and retry",
+			opts:      []Option{WithCircuitBreaker(breakers), WithRetry(retry.DefaultConfig())},
+			wantCount: 8,
+		},
+		{name: "with token source", opts: []Option{WithTokenSource(source)}, wantCount: 7},
+		{name: "with reporter", opts: []Option{WithReporter(report.NewSpanReporter())}, wantCount: 6},
+		{name: "with rate limit", opts: []Option{WithRateLimit(ratelimit.DefaultConfig())}, wantErr: true},
+		{name: "with metrics", opts: []Option{WithMetrics(metrics.DefaultConfig())}, wantErr: true},
+		{name: "with load shedding", opts: []Option{WithLoadShedding(loadshed.DefaultConfig())}, wantErr: true},
+		{
+			name:    "with idempotency",
+			opts:    []Option{WithIdempotency(idempotency.NewInMemoryStore(), idempotency.DefaultConfig())},
+			wantErr: true,
+		},
+		{name: "with authz", opts: []Option{WithAuthz(authz.DefaultConfig())}, wantErr: true},
+		{name: "with dpop", opts: []Option{WithDPoP(jwtauth.DefaultDPoPConfig(), nil)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			interceptors, err := BuildClientDefault(tt.opts...)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("BuildClientDefault() should return error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BuildClientDefault() error = %v", err)
+			}
+			if len(interceptors) != tt.wantCount {
+				t.Errorf("BuildClientDefault() returned %d interceptors, want %d", len(interceptors), tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestBuildClientDefault_ReturnsConnectErrors(t *testing.T) {
+	t.Parallel()
+
+	interceptors, err := BuildClientDefault()
+	if err != nil {
+		t.Fatalf("BuildClientDefault() error = %v", err)
+	}
+	// A failing interceptor in the place of circuitbreaker, retry or tokensource.
+	errFailed := errors.New("failed")
+	failing := connect.UnaryInterceptorFunc(func(connect.UnaryFunc) connect.UnaryFunc {
+		return func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+			return nil, errFailed
+		}
+	})
+	interceptors = slices.Insert(interceptors, len(interceptors)-1, connect.Interceptor(failing))
+
+	const procedure = "/test.v1.TestService/Echo"
+	client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](http.DefaultClient, "http://localhost"+procedure,
+		connect.WithInterceptors(interceptors...))
+	_, err = client.CallUnary(context.Background(), connect.NewRequest(wrapperspb.String("hi")))
+
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		t.Fatalf("CallUnary() error = %T %v, want *connect.Error", err, err)
+	}
+	if !errors.Is(err, errFailed) {
+		t.Errorf("CallUnary() error = %v, want %v", err, errFailed)
+	}
+}
+
+func TestBuildDefault_ClientOnlyOptions(t *testing.T) {
+	t.Parallel()
+
+	breakers, err := circuitbreaker.NewInterceptor(circuitbreaker.DefaultConfig())
+	if err != nil {
+		t.Fatalf("circuitbreaker.NewInterceptor() error = %v", err)
+	}
+	if _, err := BuildDefault(WithCircuitBreaker(breakers)); err == nil {
+		t.Error("BuildDefault() should return error with circuit breaker")
+	}
+
+	if _, err := BuildDefault(WithRetry(retry.DefaultConfig())); err == nil {
+		t.Error("BuildDefault() should return error with retry")
+	}
+	if _, err := BuildDefaultWithAuth(&jwtauth.Authenticator{}, WithRetry(retry.DefaultConfig())); err == nil {
+		t.Error("BuildDefaultWithAuth() should return error with retry")
+	}
+
+	source, err := tokensource.NewTokenSource(tokensource.Config{TokenURL: "https://idp.example.com/token", ClientID: "svc"})
+	if err != nil {
+		t.Fatalf("tokensource.NewTokenSource() error = %v", err)
+	}
+	if _, err := BuildDefault(WithTokenSource(source)); err == nil {
+		t.Error("BuildDefault() should return error with token source")
+	}
+}
+
+func TestBuildDefault_InvalidLoadShedding(t *testing.T) {
+	t.Parallel()
+
+	_, err := BuildDefault(WithLoadShedding(loadshed.Config{Limit: -1}))
+	if !errors.Is(err, loadshed.ErrInvalidLimit) {
+		t.Errorf("BuildDefault() error = %v, want %v", err, loadshed.ErrInvalidLimit)
+	}
+}
+
+func TestBuildDefault_InvalidIdempotency(t *testing.T) {
+	t.Parallel()
+
+	_, err := BuildDefault(WithIdempotency(idempotency.NewInMemoryStore(), idempotency.Config{}))
+	if !errors.Is(err, idempotency.ErrInvalidTTL) {
+		t.Errorf("BuildDefault() error = %v, want %v", err, idempotency.ErrInvalidTTL)
+	}
+}
+
+func TestBuildDefault_ReportsOncePerFailure(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		handler   func() error
+		wantPanic bool
+	}{
+		{name: "handler panic", handler: func() error { panic("nil map") }, wantPanic: true},
+		{name: "unmapped error", handler: func() error { return errors.New("db down") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			var reports []report.Report
+			interceptors, err := BuildDefault(WithReporter(report.ReporterFunc(func(_ context.Context, r report.Report) {
+				mu.Lock()
+				defer mu.Unlock()
+				reports = append(reports, r)
+			})))
+			if err != nil {
+				t.Fatalf("BuildDefault() error = %v", err)
+			}
+
+			const procedure = "/test.v1.TestService/Echo"
+			mux := http.NewServeMux()
+			mux.Handle(procedure, connect.NewUnaryHandler(procedure,
+				func(context.Context, *connect.Request[wrapperspb.StringValue]) (*connect.Response[wrapperspb.StringValue], error) {
+					return nil, tt.handler()
+				},
+				connect.WithInterceptors(interceptors...),
+			))
+			srv := httptest.NewServer(mux)
+			t.Cleanup(srv.Close)
+
+			client := connect.NewClient[wrapperspb.StringValue, wrapperspb.StringValue](srv.Client(), srv.URL+procedure)
+			_, err = client.CallUnary(context.Background(), connect.NewRequest(wrapperspb.String("hi")))
+			if connect.CodeOf(err) != connect.CodeInternal {
+				t.Fatalf("CallUnary() error = %v, want internal", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(reports) != 1 {
+				t.Fatalf("reported %d times, want 1: %+v", len(reports), reports)
+			}
+			if got := reports[0].Panic != nil; got != tt.wantPanic {
+				t.Errorf("report is panic = %v, want %v", got, tt.wantPanic)
+			}
+			if reports[0].RequestID == "" {
+				t.Error("report has no request ID")
+			}
+		})
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"connectrpc.com/connect"
@@ -13,6 +16,8 @@ import (
 	"github.com/lestrrat-go/jwx/v3/jwk"
 	"github.com/lestrrat-go/jwx/v3/jws"
 	"github.com/lestrrat-go/jwx/v3/jwt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
 
 	"github.com/deepworx/go-utils/pkg/ctxutil"
 	"github.com/deepworx/go-utils/pkg/tracing"
@@ -34,30 +39,96 @@ type ClaimsMapping struct {
 	Permissions string `koanf:"permissions"`
 }
 
+// IssuerConfig holds validation settings for a single trusted token issuer.
+type IssuerConfig struct {
+	// Issuer is the expected "iss" claim value.
+	// Required.
+	Issuer string `koanf:"issuer"`
+
+	// JWKSURL is the URL to fetch this issuer's JSON Web Key Set.
+	// Required unless Discovery is enabled.
+	JWKSURL string `koanf:"jwks_url"`
+
+	// Discovery fetches "{issuer}/.well-known/openid-configuration" and derives
+	// the JWKS URL and accepted signing algorithms from it.
+	// An explicit JWKSURL takes precedence over the discovered jwks_uri.
+	Discovery bool `koanf:"discovery"`
+
+	// Audiences lists accepted "aud" claim values. A token must contain at least one.
+	// Required.
+	Audiences []string `koanf:"audiences"`
+
+	// ClaimsMapping defines how this issuer's JWT claims map to application claims.
+	// Defaults to Config.ClaimsMapping.
+	ClaimsMapping *ClaimsMapping `koanf:"claims_mapping"`
+
+	// Leeway allows clock skew tolerance for exp/nbf/iat validation.
+	// Defaults to Config.Leeway.
+	Leeway time.Duration `koanf:"leeway"`
+
+	// StaticJWKS is a fallback key set used while this issuer's JWKS cannot be
+	// fetched. See Config.StaticJWKS.
+	StaticJWKS string `koanf:"static_jwks"`
+}
+
 // Config holds configuration for the JWT authentication interceptor.
+//
+// A single issuer can be configured with JWKSURL, Issuer, and Audience.
+// Additional issuers are listed in Issuers; both forms can be combined.
 type Config struct {
 	// JWKSURL is the URL to fetch JSON Web Key Set
 	// (e.g., "https://idp.example.com/.well-known/jwks.json").
-	// Required.
+	// Required unless Issuers is set or Discovery is enabled.
 	JWKSURL string `koanf:"jwks_url"`
 
 	// Issuer is the expected "iss" claim value.
-	// Required.
+	// Required unless Issuers is set.
 	Issuer string `koanf:"issuer"`
 
 	// Audience is the expected "aud" claim value.
-	// Required.
+	// Required unless Issuers is set.
 	Audience string `koanf:"audience"`
 
+	// Discovery enables OIDC discovery for Issuer. See IssuerConfig.Discovery.
+	Discovery bool `koanf:"discovery"`
+
+	// StaticJWKS is a JWKS used for Issuer while its JWKS URL (or discovery)
+	// cannot be fetched, so services can start during IdP outages. The value is
+	// either the JWKS JSON, e.g. resolved by koanfutil.FileResolver, or a
+	// "file://" path. Fetched keys replace it as soon as a fetch succeeds.
+	// Default: "" (startup fails if the initial JWKS fetch fails)
+	StaticJWKS string `koanf:"static_jwks"`
+
+	// DiscoveryRefreshInterval is how often discovery metadata is re-fetched.
+	// A failed refresh keeps the previous metadata.
+	DiscoveryRefreshInterval time.Duration `koanf:"discovery_refresh_interval"`
+
+	// Issuers lists additional trusted issuers, each with its own JWKS,
+	// audiences, claims mapping, and leeway. The issuer is selected by the
+	// token's unverified "iss" claim before signature verification.
+	Issuers []IssuerConfig `koanf:"issuers"`
+
 	// ClaimsMapping defines how JWT claims map to application claims.
 	ClaimsMapping *ClaimsMapping `koanf:"claims_mapping"`
 
-	// HTTPTimeout is the timeout for JWKS fetch requests.
+	// HTTPTimeout is the timeout for JWKS and discovery fetch requests.
 	HTTPTimeout time.Duration `koanf:"http_timeout"`
 
 	// Leeway allows clock skew tolerance for exp/nbf/iat validation.
 	Leeway time.Duration `koanf:"leeway"`
 
+	// TokenCacheSize is the maximum number of verified tokens cached by token hash,
+	// skipping signature verification for repeated tokens. Cached tokens are
+	// dropped when a JWKS rotates or the discovered algorithms change; revocation
+	// checks still run on every request.
+	// Default: 0 (disabled)
+	TokenCacheSize int `koanf:"token_cache_size"`
+
+	// TokenCacheTTL bounds how long a verified token is cached.
+	// Entries never outlive the token's "exp".
+	// Default: 1 minute
+	TokenCacheTTL time.Duration `koanf:"token_cache_ttl"`
+
 	// InferAlgorithmFromKey enables algorithm inference from key type when
 	// the JWKS keys don't have an explicit "alg" field set.
 	// Default: false (requires explicit algorithm in JWKS)
@@ -65,11 +136,13 @@ type Config struct {
 }
 
 // DefaultConfig returns a Config with sensible default values.
-// JWKSURL, Issuer, and Audience are required and must be set by the caller.
+// JWKSURL, Issuer, and Audience (or Issuers) are required and must be set by the caller.
 func DefaultConfig() Config {
 	return Config{
-		HTTPTimeout: 10 * time.Second,
-		Leeway:      time.Minute,
+		HTTPTimeout:              10 * time.Second,
+		Leeway:                   time.Minute,
+		DiscoveryRefreshInterval: time.Hour,
+		TokenCacheTTL:            time.Minute,
 		ClaimsMapping: &ClaimsMapping{
 			UserID: "sub",
 		},
@@ -79,61 +152,129 @@ func DefaultConfig() Config {
 // Validate checks that all required fields are set.
 // Returns nil if configuration is valid.
 func (c Config) Validate() error {
-	if c.JWKSURL == "" {
-		return ErrJWKSURLRequired
-	}
-	if c.Issuer == "" {
-		return ErrIssuerRequired
+	if len(c.Issuers) == 0 || c.JWKSURL != "" || c.Issuer != "" || c.Audience != "" {
+		if c.JWKSURL == "" && !c.Discovery {
+			return ErrJWKSURLRequired
+		}
+		if c.Issuer == "" {
+			return ErrIssuerRequired
+		}
+		if c.Audience == "" {
+			return ErrAudienceRequired
+		}
 	}
-	if c.Audience == "" {
-		return ErrAudienceRequired
+
+	seen := map[string]struct{}{c.Issuer: {}}
+	for _, iss := range c.Issuers {
+		if iss.Issuer == "" {
+			return ErrIssuerRequired
+		}
+		if iss.JWKSURL == "" && !iss.Discovery {
+			return fmt.Errorf("issuer %s: %w", iss.Issuer, ErrJWKSURLRequired)
+		}
+		if len(iss.Audiences) == 0 {
+			return fmt.Errorf("issuer %s: %w", iss.Issuer, ErrAudienceRequired)
+		}
+		if _, ok := seen[iss.Issuer]; ok {
+			return fmt.Errorf("issuer %s: %w", iss.Issuer, ErrDuplicateIssuer)
+		}
+		seen[iss.Issuer] = struct{}{}
 	}
 	return nil
 }
 
+// issuers returns the normalized issuer list with defaults applied.
+func (c Config) issuers() []IssuerConfig {
+	leeway := c.Leeway
+	if leeway == 0 {
+		leeway = time.Minute
+	}
+
+	list := make([]IssuerConfig, 0, len(c.Issuers)+1)
+	if c.Issuer != "" {
+		list = append(list, IssuerConfig{
+			Issuer:     c.Issuer,
+			JWKSURL:    c.JWKSURL,
+			Discovery:  c.Discovery,
+			Audiences:  []string{c.Audience},
+			StaticJWKS: c.StaticJWKS,
+		})
+	}
+	list = append(list, c.Issuers...)
+
+	for n := range list {
+		if list[n].ClaimsMapping == nil {
+			list[n].ClaimsMapping = c.ClaimsMapping
+		}
+		if list[n].Leeway == 0 {
+			list[n].Leeway = leeway
+		}
+	}
+	return list
+}
+
 // Authenticator validates JWT tokens and extracts claims.
 type Authenticator struct {
 	cache                 *jwk.Cache
-	jwksURL               string
-	issuer                string
-	audience              string
-	mapping               ClaimsMapping
-	leeway                time.Duration
+	issuers               map[string]*issuer
 	inferAlgorithmFromKey bool
+	custom                claimsDecoder
+	revocation            RevocationChecker
+	tokens                *tokenCache
+	jwks                  *jwksObserver
+}
+
+// issuer holds the resolved validation settings for one trusted issuer.
+type issuer struct {
+	issuer    string
+	jwksURL   string
+	discovery bool
+	audiences []string
+	mapping   ClaimsMapping
+	leeway    time.Duration
+	keys      atomic.Pointer[keySource]
+
+	// static is the fallback key set used while the JWKS cannot be fetched.
+	static jwk.Set
 }
 
 // NewAuthenticator creates a new JWT authenticator with the given configuration.
-// The ctx controls the lifecycle of the background JWKS refresh goroutine.
-// Returns error if required config fields are empty or if initial JWKS fetch fails.
-func NewAuthenticator(ctx context.Context, cfg Config) (*Authenticator, error) {
+// The ctx controls the lifecycle of the background JWKS and discovery refresh goroutines.
+// Returns error if required config fields are empty, if an option is invalid,
+// if a static JWKS is invalid,
+// or, for issuers without a static JWKS, if discovery fails or reports a
+// different issuer or the initial JWKS fetch fails.
+//
+// JWKS fetches are recorded through the global OpenTelemetry MeterProvider.
+func NewAuthenticator(ctx context.Context, cfg Config, opts ...AuthenticatorOption) (*Authenticator, error) {
+	return newAuthenticator(ctx, cfg, otel.GetMeterProvider(), opts...)
+}
+
+func newAuthenticator(ctx context.Context, cfg Config, mp metric.MeterProvider, opts ...AuthenticatorOption) (*Authenticator, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("create authenticator: %w", err)
 	}
+	options, err := newAuthenticatorOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("create authenticator: %w", err)
+	}
 
 	httpTimeout := cfg.HTTPTimeout
 	if httpTimeout == 0 {
 		httpTimeout = 10 * time.Second
 	}
 
-	leeway := cfg.Leeway
-	if leeway == 0 {
-		leeway = time.Minute
-	}
-
-	mapping := ClaimsMapping{UserID: "sub"}
-	if cfg.ClaimsMapping != nil {
-		mapping = *cfg.ClaimsMapping
-		if mapping.UserID == "" {
-			mapping.UserID = "sub"
-		}
-	}
-
 	httpClient := &http.Client{
 		Timeout: httpTimeout,
 	}
 
+	observer, err := newJWKSObserver(httpClient, mp)
+	if err != nil {
+		return nil, fmt.Errorf("create authenticator: %w", err)
+	}
+
 	cache, err := jwk.NewCache(ctx, httprc.NewClient(
-		httprc.WithHTTPClient(httpClient),
+		httprc.WithHTTPClient(observer),
 	))
 	if err != nil {
 		return nil, fmt.Errorf("create jwk cache: %w", err)
@@ -145,33 +286,145 @@ func NewAuthenticator(ctx context.Context, cfg Config) (*Authenticator, error) {
 	initCtx, initCancel := context.WithTimeout(ctx, httpTimeout)
 	defer initCancel()
 
-	if err := cache.Register(initCtx, cfg.JWKSURL); err != nil {
-		return nil, fmt.Errorf("register jwks url %s: %w", cfg.JWKSURL, err)
-	}
+	issuers := make(map[string]*issuer, len(cfg.Issuers)+1)
+	registered := make(map[string]struct{})
+	discovery := false
+	for _, ic := range cfg.issuers() {
+		mapping := ClaimsMapping{UserID: "sub"}
+		if ic.ClaimsMapping != nil {
+			mapping = *ic.ClaimsMapping
+			if mapping.UserID == "" {
+				mapping.UserID = "sub"
+			}
+		}
+		iss := &issuer{
+			issuer:    ic.Issuer,
+			jwksURL:   ic.JWKSURL,
+			discovery: ic.Discovery,
+			audiences: ic.Audiences,
+			mapping:   mapping,
+			leeway:    ic.Leeway,
+		}
+		if ic.StaticJWKS != "" {
+			if iss.static, err = loadStaticJWKS(ic.StaticJWKS); err != nil {
+				return nil, fmt.Errorf("create authenticator: issuer %s: %w", ic.Issuer, err)
+			}
+		}
+		issuers[ic.Issuer] = iss
+		discovery = discovery || ic.Discovery
 
-	if _, err := cache.Lookup(initCtx, cfg.JWKSURL); err != nil {
-		return nil, fmt.Errorf("initial jwks fetch from %s: %w", cfg.JWKSURL, ErrJWKSFetch)
+		src, err := resolveKeySource(initCtx, httpClient, iss)
+		if err != nil {
+			if iss.static == nil {
+				return nil, fmt.Errorf("discover issuer %s: %w", ic.Issuer, err)
+			}
+			// Discovery is retried every DiscoveryRefreshInterval; until then
+			// the configured JWKS URL, if any, is used.
+			slog.WarnContext(ctx, "oidc discovery failed, using static jwks",
+				"issuer", ic.Issuer,
+				"error", err,
+			)
+			src = &keySource{jwksURL: ic.JWKSURL}
+		}
+		iss.keys.Store(src)
+
+		if src.jwksURL == "" {
+			continue
+		}
+		if _, ok := registered[src.jwksURL]; ok {
+			continue
+		}
+		registered[src.jwksURL] = struct{}{}
+
+		if err := cache.Register(initCtx, src.jwksURL, jwk.WithWaitReady(false)); err != nil {
+			return nil, fmt.Errorf("register jwks url %s: %w", src.jwksURL, err)
+		}
+
+		if !cache.Ready(initCtx, src.jwksURL) {
+			if iss.static == nil {
+				return nil, fmt.Errorf("initial jwks fetch from %s: %w", src.jwksURL, ErrJWKSFetch)
+			}
+			// The cache keeps retrying in the background.
+			slog.WarnContext(ctx, "initial jwks fetch failed, using static jwks",
+				"issuer", ic.Issuer,
+				"jwks_url", src.jwksURL,
+			)
+		}
 	}
 
-	return &Authenticator{
+	a := &Authenticator{
 		cache:                 cache,
-		jwksURL:               cfg.JWKSURL,
-		issuer:                cfg.Issuer,
-		audience:              cfg.Audience,
-		mapping:               mapping,
-		leeway:                leeway,
+		issuers:               issuers,
 		inferAlgorithmFromKey: cfg.InferAlgorithmFromKey,
-	}, nil
+		custom:                options.custom,
+		revocation:            options.revocation,
+		jwks:                  observer,
+	}
+
+	if cfg.TokenCacheSize > 0 {
+		ttl := cfg.TokenCacheTTL
+		if ttl == 0 {
+			ttl = time.Minute
+		}
+		if a.tokens, err = newTokenCache(cfg.TokenCacheSize, ttl, mp); err != nil {
+			return nil, fmt.Errorf("create authenticator: %w", err)
+		}
+	}
+
+	if discovery {
+		interval := cfg.DiscoveryRefreshInterval
+		if interval == 0 {
+			interval = time.Hour
+		}
+		go a.refreshDiscovery(ctx, httpClient, interval)
+	}
+
+	return a, nil
 }
 
 // Authenticate validates the JWT token and returns extracted claims.
 // Token should be the raw JWT string (without "Bearer " prefix).
 func (a *Authenticator) Authenticate(ctx context.Context, token string) (ctxutil.Claims, error) {
+	res, err := a.authenticateToken(ctx, token)
+	return res.claims, err
+}
+
+// authenticateToken validates the JWT token and returns extracted claims, custom claims and its identity.
+func (a *Authenticator) authenticateToken(ctx context.Context, token string) (authResult, error) {
+	if a.tokens != nil {
+		v, ok := a.cachedToken(ctx, token)
+		a.tokens.record(ctx, ok)
+		if ok {
+			if err := checkRevocation(ctx, a.revocation, v.identity); err != nil {
+				return authResult{}, err
+			}
+			custom, err := a.cachedCustomClaims(token)
+			if err != nil {
+				return authResult{}, err
+			}
+			return authResult{claims: v.claims, identity: v.identity, custom: custom}, nil
+		}
+	}
+
+	iss, err := a.selectIssuer(token)
+	if err != nil {
+		return authResult{}, err
+	}
+
+	src := iss.keys.Load()
+	if err := checkAlgorithm(token, src); err != nil {
+		return authResult{}, err
+	}
+
 	keyset, err := tracing.WithSpanResult(ctx, "jwtauth.lookup_jwks", func(ctx context.Context) (jwk.Set, error) {
-		return a.cache.Lookup(ctx, a.jwksURL)
+		return a.lookupKeys(ctx, iss, src.jwksURL)
 	})
 	if err != nil {
-		return ctxutil.Claims{}, fmt.Errorf("lookup jwks: %w", ErrJWKSFetch)
+		return authResult{}, fmt.Errorf("lookup jwks: %w", ErrJWKSFetch)
+	}
+
+	if a.tokens != nil {
+		a.tokens.observe(src.jwksURL, keyset)
 	}
 
 	tok, err := tracing.WithSpanResult(ctx, "jwtauth.parse_token", func(ctx context.Context) (jwt.Token, error) {
@@ -185,16 +438,121 @@ func (a *Authenticator) Authenticate(ctx context.Context, token string) (ctxutil
 			[]byte(token),
 			keySetOpt,
 			jwt.WithValidate(true),
-			jwt.WithIssuer(a.issuer),
-			jwt.WithAudience(a.audience),
-			jwt.WithAcceptableSkew(a.leeway),
+			jwt.WithIssuer(iss.issuer),
+			jwt.WithValidator(audienceValidator(iss.audiences)),
+			jwt.WithAcceptableSkew(iss.leeway),
 		)
 	})
 	if err != nil {
-		return ctxutil.Claims{}, a.mapJWTError(err)
+		if kid, ok := unknownKeyID(token, keyset); ok {
+			a.jwks.kidMiss(ctx, src.jwksURL, kid)
+		}
+		return authResult{}, a.mapJWTError(err)
+	}
+
+	lookup := func(path string) (any, bool) {
+		return getNestedClaim(tok, path)
+	}
+	identity := tokenIdentity(lookup)
+	if err := checkRevocation(ctx, a.revocation, identity); err != nil {
+		return authResult{}, err
+	}
+	claims := iss.mapping.extract(lookup)
+	custom, err := decodeCustom(a.custom, lookup)
+	if err != nil {
+		return authResult{}, err
+	}
+
+	if a.tokens != nil {
+		exp, _ := tok.Expiration()
+		a.tokens.add(token, verifiedToken{
+			claims:     claims,
+			identity:   identity,
+			jwksURL:    src.jwksURL,
+			algorithms: src.algorithms,
+		}, exp, time.Now())
 	}
+	return authResult{claims: claims, identity: identity, custom: custom}, nil
+}
 
-	return a.extractClaims(tok), nil
+// cachedCustomClaims decodes the custom claims of a token found in the token
+// cache. They are not cached, so the already verified token is parsed again.
+func (a *Authenticator) cachedCustomClaims(token string) (any, error) {
+	if a.custom == nil {
+		return nil, nil
+	}
+	tok, err := jwt.ParseInsecure([]byte(token))
+	if err != nil {
+		return nil, fmt.Errorf("parse token: %w", err)
+	}
+	return decodeCustom(a.custom, func(path string) (any, bool) {
+		return getNestedClaim(tok, path)
+	})
+}
+
+// cachedToken returns the cached verification of token if the key set it was
+// verified against is still the issuer's current, unrotated key set and the
+// issuer's discovered algorithm allow-list has not changed since.
+func (a *Authenticator) cachedToken(ctx context.Context, token string) (verifiedToken, bool) {
+	v, ok := a.tokens.get(token, time.Now())
+	if !ok {
+		return verifiedToken{}, false
+	}
+
+	iss, ok := a.issuers[v.identity.Issuer]
+	if !ok {
+		return verifiedToken{}, false
+	}
+	if src := iss.keys.Load(); src.jwksURL != v.jwksURL || !slices.Equal(src.algorithms, v.algorithms) {
+		return verifiedToken{}, false
+	}
+	keyset, err := a.lookupKeys(ctx, iss, v.jwksURL)
+	if err != nil || a.tokens.observe(v.jwksURL, keyset) {
+		return verifiedToken{}, false
+	}
+	return v, true
+}
+
+// lookupKeys returns the cached JWKS at url, falling back to the issuer's
+// static JWKS while the JWKS has not been fetched successfully.
+func (a *Authenticator) lookupKeys(ctx context.Context, iss *issuer, url string) (jwk.Set, error) {
+	if url == "" && iss.static != nil {
+		return iss.static, nil
+	}
+	keyset, err := a.cache.Lookup(ctx, url)
+	if err != nil && iss.static != nil {
+		return iss.static, nil
+	}
+	return keyset, err
+}
+
+// selectIssuer picks the trusted issuer from the token's unverified "iss" claim.
+// The signature is verified afterwards with that issuer's keys.
+func (a *Authenticator) selectIssuer(token string) (*issuer, error) {
+	unverified, err := jwt.ParseInsecure([]byte(token))
+	if err != nil {
+		return nil, fmt.Errorf("parse token: %w", err)
+	}
+
+	name, _ := unverified.Issuer()
+	iss, ok := a.issuers[name]
+	if !ok {
+		return nil, fmt.Errorf("validate token: %w", ErrInvalidIssuer)
+	}
+	return iss, nil
+}
+
+// audienceValidator accepts tokens whose "aud" claim contains any of audiences.
+func audienceValidator(audiences []string) jwt.Validator {
+	return jwt.ValidatorFunc(func(_ context.Context, tok jwt.Token) error {
+		aud, _ := tok.Audience()
+		for _, want := range audiences {
+			if slices.Contains(aud, want) {
+				return nil
+			}
+		}
+		return ErrInvalidAudience
+	})
 }
 
 func (a *Authenticator) mapJWTError(err error) error {
@@ -207,7 +565,7 @@ func (a *Authenticator) mapJWTError(err error) error {
 	if errors.Is(err, jwt.InvalidIssuerError()) {
 		return fmt.Errorf("validate token: %w", ErrInvalidIssuer)
 	}
-	if errors.Is(err, jwt.InvalidAudienceError()) {
+	if errors.Is(err, jwt.InvalidAudienceError()) || errors.Is(err, ErrInvalidAudience) {
 		return fmt.Errorf("validate token: %w", ErrInvalidAudience)
 	}
 
@@ -219,35 +577,48 @@ func (a *Authenticator) mapJWTError(err error) error {
 	return fmt.Errorf("parse token: %w", err)
 }
 
-func (a *Authenticator) extractClaims(tok jwt.Token) ctxutil.Claims {
+// decodeCustom decodes custom claims with custom, if configured.
+func decodeCustom(custom claimsDecoder, lookup func(path string) (any, bool)) (any, error) {
+	if custom == nil {
+		return nil, nil
+	}
+	v, err := custom(lookup)
+	if err != nil {
+		return nil, fmt.Errorf("decode custom claims: %w", err)
+	}
+	return v, nil
+}
+
+// extract builds ctxutil.Claims by resolving each mapped claim path with lookup.
+func (m ClaimsMapping) extract(lookup func(path string) (any, bool)) ctxutil.Claims {
 	var claims ctxutil.Claims
 
-	if a.mapping.UserID != "" {
-		if v, ok := getNestedClaim(tok, a.mapping.UserID); ok {
+	if m.UserID != "" {
+		if v, ok := lookup(m.UserID); ok {
 			if s, ok := v.(string); ok {
 				claims.UserID = s
 			}
 		}
 	}
 
-	if a.mapping.TenantID != "" {
-		if v, ok := getNestedClaim(tok, a.mapping.TenantID); ok {
+	if m.TenantID != "" {
+		if v, ok := lookup(m.TenantID); ok {
 			if s, ok := v.(string); ok {
 				claims.TenantID = s
 			}
 		}
 	}
 
-	if a.mapping.Roles != "" {
-		if v, ok := getNestedClaim(tok, a.mapping.Roles); ok {
+	if m.Roles != "" {
+		if v, ok := lookup(m.Roles); ok {
 			if roles, err := toStringSlice(v); err == nil {
 				claims.Roles = roles
 			}
 		}
 	}
 
-	if a.mapping.Permissions != "" {
-		if v, ok := getNestedClaim(tok, a.mapping.Permissions); ok {
+	if m.Permissions != "" {
+		if v, ok := lookup(m.Permissions); ok {
 			if perms, err := toStringSlice(v); err == nil {
 				claims.Permissions = perms
 			}
@@ -270,7 +641,20 @@ func getNestedClaim(tok jwt.Token, path string) (any, bool) {
 		return nil, false
 	}
 
-	for _, part := range parts[1:] {
+	return walkPath(current, parts[1:])
+}
+
+// getNestedValue retrieves a value from a decoded JSON object using dot notation.
+func getNestedValue(obj map[string]any, path string) (any, bool) {
+	if path == "" {
+		return nil, false
+	}
+	return walkPath(obj, strings.Split(path, "."))
+}
+
+// walkPath descends into nested JSON objects following parts.
+func walkPath(current any, parts []string) (any, bool) {
+	for _, part := range parts {
 		m, ok := current.(map[string]any)
 		if !ok {
 			return nil, false
@@ -304,15 +688,106 @@ func toStringSlice(v any) ([]string, error) {
 	}
 }
 
-// NewInterceptor creates a Connect RPC interceptor that validates JWT tokens.
-// It extracts the token from the Authorization header, validates it, and injects
-// claims into the request context using ctxutil.WithClaims.
-func NewInterceptor(auth *Authenticator) connect.Interceptor {
-	return &interceptor{auth: auth}
+// InterceptorOption configures the JWT interceptor.
+type InterceptorOption func(*interceptor)
+
+// WithInterceptorConfig sets per-procedure authentication modes.
+// NewValidatedInterceptor returns an error if cfg is invalid; NewInterceptor panics.
+func WithInterceptorConfig(cfg InterceptorConfig) InterceptorOption {
+	return func(i *interceptor) {
+		i.modesCfg = &cfg
+	}
+}
+
+// TokenAuthenticator validates a bearer token and returns the caller's claims.
+// *Authenticator validates JWTs locally; *Introspector validates opaque tokens
+// against an OAuth2 introspection endpoint.
+type TokenAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (ctxutil.Claims, error)
+}
+
+// identityAuthenticator is implemented by authenticators that expose the
+// token identity, including the DPoP confirmation thumbprint, and custom claims.
+type identityAuthenticator interface {
+	authenticateToken(ctx context.Context, token string) (authResult, error)
+}
+
+// authResult is the outcome of a successful token validation.
+type authResult struct {
+	claims   ctxutil.Claims
+	identity TokenIdentity
+
+	// custom holds the claims decoded by WithCustomClaims, if configured.
+	custom any
+}
+
+// context returns ctx with the claims and custom claims of r set.
+func (r authResult) context(ctx context.Context) context.Context {
+	ctx = ctxutil.WithClaims(ctx, r.claims)
+	if r.custom != nil {
+		ctx = ctxutil.WithCustomClaims(ctx, r.custom)
+	}
+	return ctx
+}
+
+// NewInterceptor creates a Connect RPC interceptor that validates bearer tokens.
+// It extracts the token from the Authorization header (see WithTokenExtractors),
+// validates it with auth, and injects claims into the request context using ctxutil.WithClaims
+// (and custom claims using ctxutil.WithCustomClaims).
+// By default every procedure requires authentication; use WithInterceptorConfig
+// to make procedures optional or skip them entirely.
+// Panics if an option is invalid; use NewValidatedInterceptor for options
+// loaded from config files.
+func NewInterceptor(auth TokenAuthenticator, opts ...InterceptorOption) connect.Interceptor {
+	i, err := NewValidatedInterceptor(auth, opts...)
+	if err != nil {
+		panic("jwtauth: " + err.Error())
+	}
+	return i
+}
+
+// NewValidatedInterceptor is like NewInterceptor, but returns an error if the
+// interceptor or DPoP config is invalid, or if auth does not support DPoP.
+func NewValidatedInterceptor(auth TokenAuthenticator, opts ...InterceptorOption) (connect.Interceptor, error) {
+	i := &interceptor{
+		auth:       auth,
+		extractors: []TokenExtractor{FromAuthorizationHeader()},
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	modesCfg := DefaultInterceptorConfig()
+	if i.modesCfg != nil {
+		modesCfg = *i.modesCfg
+	}
+	if err := modesCfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create jwt interceptor: %w", err)
+	}
+	i.modes = newModeResolver(modesCfg)
+
+	if i.dpopCfg != nil {
+		if err := i.dpopCfg.Validate(); err != nil {
+			return nil, fmt.Errorf("create jwt interceptor: %w", err)
+		}
+		if _, ok := auth.(identityAuthenticator); !ok {
+			return nil, fmt.Errorf("create jwt interceptor: %w: %T", ErrDPoPNotSupported, auth)
+		}
+		i.dpop = newDPoPValidator(*i.dpopCfg, i.dpopStore)
+	}
+	return i, nil
 }
 
 type interceptor struct {
-	auth *Authenticator
+	auth       TokenAuthenticator
+	modes      modeResolver
+	extractors []TokenExtractor
+	dpop       *dpopValidator
+
+	// set by options, turned into modes and dpop by NewValidatedInterceptor
+	modesCfg  *InterceptorConfig
+	dpopCfg   *DPoPConfig
+	dpopStore ReplayStore
 }
 
 func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
@@ -321,7 +796,7 @@ func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 			return next(ctx, req)
 		}
 
-		ctx, err := i.authenticate(ctx, req.Header())
+		ctx, err := i.authenticateProcedure(ctx, req.Spec().Procedure, req.HTTPMethod(), req.Header(), req.Peer())
 		if err != nil {
 			return nil, err
 		}
@@ -336,7 +811,8 @@ func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) conn
 
 func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
-		ctx, err := i.authenticate(ctx, conn.RequestHeader())
+		// Streaming RPCs are always POST requests.
+		ctx, err := i.authenticateProcedure(ctx, conn.Spec().Procedure, http.MethodPost, conn.RequestHeader(), conn.Peer())
 		if err != nil {
 			return err
 		}
@@ -344,17 +820,44 @@ func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) co
 	}
 }
 
-func (i *interceptor) authenticate(ctx context.Context, headers http.Header) (context.Context, error) {
-	authHeader := headers.Get("Authorization")
-	if authHeader == "" {
+// authenticateProcedure applies the configured mode for procedure.
+// method is the HTTP method, checked against DPoP proofs.
+func (i *interceptor) authenticateProcedure(ctx context.Context, procedure, method string, headers http.Header, peer connect.Peer) (context.Context, error) {
+	mode := i.modes.modeFor(procedure)
+	if mode == ModeSkip {
+		return ctx, nil
+	}
+
+	if i.dpop != nil {
+		if token, ok := dpopToken(headers); ok {
+			return i.authenticateDPoP(ctx, token, true, method, procedure, headers)
+		}
+	}
+
+	token, err := i.extractToken(headers, peer)
+	if err != nil {
+		return nil, connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	if token == "" {
+		if mode == ModeOptional {
+			return ctx, nil
+		}
 		return nil, connect.NewError(connect.CodeUnauthenticated, ErrMissingToken)
 	}
+	if i.dpop != nil {
+		return i.authenticateDPoP(ctx, token, false, method, procedure, headers)
+	}
+	return i.authenticate(ctx, token)
+}
 
-	const bearerPrefix = "Bearer "
-	if !strings.HasPrefix(authHeader, bearerPrefix) {
-		return nil, connect.NewError(connect.CodeUnauthenticated, ErrInvalidTokenFormat)
+func (i *interceptor) authenticate(ctx context.Context, token string) (context.Context, error) {
+	if auth, ok := i.auth.(identityAuthenticator); ok {
+		res, err := auth.authenticateToken(ctx, token)
+		if err != nil {
+			return nil, i.mapToConnectError(err)
+		}
+		return res.context(ctx), nil
 	}
-	token := strings.TrimPrefix(authHeader, bearerPrefix)
 
 	claims, err := i.auth.Authenticate(ctx, token)
 	if err != nil {
@@ -370,9 +873,22 @@ func (i *interceptor) mapToConnectError(err error) *connect.Error {
 		errors.Is(err, ErrTokenNotYetValid),
 		errors.Is(err, ErrInvalidIssuer),
 		errors.Is(err, ErrInvalidAudience),
+		errors.Is(err, ErrUnsupportedAlgorithm),
+		errors.Is(err, ErrTokenInactive),
+		errors.Is(err, ErrMissingClaim),
+		errors.Is(err, ErrInvalidClaim),
+		errors.Is(err, ErrTokenRevoked),
+		errors.Is(err, ErrDPoPProofRequired),
+		errors.Is(err, ErrDPoPBindingRequired),
+		errors.Is(err, ErrInvalidDPoPProof),
+		errors.Is(err, ErrDPoPKeyMismatch),
+		errors.Is(err, ErrDPoPReplay),
 		errors.Is(err, ErrSignatureVerification):
 		return connect.NewError(connect.CodeUnauthenticated, err)
-	case errors.Is(err, ErrJWKSFetch):
+	case errors.Is(err, ErrJWKSFetch),
+		errors.Is(err, ErrIntrospection),
+		errors.Is(err, ErrRevocationCheck),
+		errors.Is(err, ErrReplayCheck):
 		return connect.NewError(connect.CodeUnavailable, err)
 	default:
 		return connect.NewError(connect.CodeUnauthenticated, err)
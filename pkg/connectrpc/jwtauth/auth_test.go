@@ -47,6 +47,55 @@ func TestConfig_Validate(t *testing.T) {
 			cfg:     Config{JWKSURL: "https://example.com/jwks", Issuer: "iss"},
 			wantErr: ErrAudienceRequired,
 		},
+		{
+			name: "valid issuers only",
+			cfg: Config{Issuers: []IssuerConfig{
+				{Issuer: "a", JWKSURL: "https://a.example.com/jwks", Audiences: []string{"aud"}},
+				{Issuer: "b", JWKSURL: "https://b.example.com/jwks", Audiences: []string{"aud"}},
+			}},
+			wantErr: nil,
+		},
+		{
+			name: "valid single issuer plus issuers",
+			cfg: Config{
+				JWKSURL: "https://example.com/jwks", Issuer: "iss", Audience: "aud",
+				Issuers: []IssuerConfig{{Issuer: "b", JWKSURL: "https://b.example.com/jwks", Audiences: []string{"aud"}}},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "issuer entry missing issuer",
+			cfg:     Config{Issuers: []IssuerConfig{{JWKSURL: "https://a.example.com/jwks", Audiences: []string{"aud"}}}},
+			wantErr: ErrIssuerRequired,
+		},
+		{
+			name:    "issuer entry missing JWKSURL",
+			cfg:     Config{Issuers: []IssuerConfig{{Issuer: "a", Audiences: []string{"aud"}}}},
+			wantErr: ErrJWKSURLRequired,
+		},
+		{
+			name:    "issuer entry missing audiences",
+			cfg:     Config{Issuers: []IssuerConfig{{Issuer: "a", JWKSURL: "https://a.example.com/jwks"}}},
+			wantErr: ErrAudienceRequired,
+		},
+		{
+			name: "duplicate issuer",
+			cfg: Config{
+				JWKSURL: "https://example.com/jwks", Issuer: "a", Audience: "aud",
+				Issuers: []IssuerConfig{{Issuer: "a", JWKSURL: "https://a.example.com/jwks", Audiences: []string{"aud"}}},
+			},
+			wantErr: ErrDuplicateIssuer,
+		},
+		{
+			name:    "discovery without JWKSURL",
+			cfg:     Config{Issuer: "iss", Audience: "aud", Discovery: true},
+			wantErr: nil,
+		},
+		{
+			name:    "issuer entry with discovery without JWKSURL",
+			cfg:     Config{Issuers: []IssuerConfig{{Issuer: "a", Discovery: true, Audiences: []string{"aud"}}}},
+			wantErr: nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -408,7 +457,7 @@ func TestInterceptor_Authenticate(t *testing.T) {
 		t.Fatalf("NewAuthenticator() error = %v", err)
 	}
 
-	i := NewInterceptor(auth).(*interceptor)
+	i := newTestInterceptor(t, auth)
 
 	tests := []struct {
 		name       string
@@ -423,10 +472,10 @@ func TestInterceptor_Authenticate(t *testing.T) {
 			wantErr:    ErrMissingToken,
 		},
 		{
-			name:       "invalid format - no Bearer prefix",
+			name:       "other scheme - no Bearer prefix",
 			authHeader: "Basic abc123",
 			wantCode:   connect.CodeUnauthenticated,
-			wantErr:    ErrInvalidTokenFormat,
+			wantErr:    ErrMissingToken,
 		},
 		{
 			name:       "invalid token",
@@ -452,7 +501,7 @@ func TestInterceptor_Authenticate(t *testing.T) {
 				headers.Set("Authorization", tt.authHeader)
 			}
 
-			newCtx, err := i.authenticate(ctx, headers)
+			newCtx, err := i.authenticateProcedure(ctx, "/test.Service/Method", http.MethodPost, headers, connect.Peer{})
 
 			if tt.wantErr != nil || tt.wantCode != 0 {
 				if err == nil {
@@ -587,6 +636,11 @@ func TestMapToConnectError(t *testing.T) {
 			err:      ErrSignatureVerification,
 			wantCode: connect.CodeUnauthenticated,
 		},
+		{
+			name:     "missing custom claim",
+			err:      ErrMissingClaim,
+			wantCode: connect.CodeUnauthenticated,
+		},
 		{
 			name:     "JWKS fetch failed",
 			err:      ErrJWKSFetch,
@@ -610,6 +664,130 @@ func TestMapToConnectError(t *testing.T) {
 	}
 }
 
+func TestAuthenticator_MultipleIssuers(t *testing.T) {
+	t.Parallel()
+
+	customerKey, customerPub := generateTestKeys(t)
+	customerSrv := setupTestJWKSServer(t, customerPub)
+	t.Cleanup(customerSrv.Close)
+
+	serviceKey, servicePub := generateTestKeys(t)
+	serviceSrv := setupTestJWKSServer(t, servicePub)
+	t.Cleanup(serviceSrv.Close)
+
+	ctx := context.Background()
+	auth, err := NewAuthenticator(ctx, Config{
+		JWKSURL:  customerSrv.URL,
+		Issuer:   "customer-idp",
+		Audience: "my-api",
+		ClaimsMapping: &ClaimsMapping{
+			UserID:   "sub",
+			TenantID: "tenant_id",
+		},
+		Issuers: []IssuerConfig{
+			{
+				Issuer:    "service-accounts",
+				JWKSURL:   serviceSrv.URL,
+				Audiences: []string{"my-api", "internal"},
+				ClaimsMapping: &ClaimsMapping{
+					UserID: "client_id",
+					Roles:  "roles",
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantClaims ctxutil.Claims
+		wantErr    error
+	}{
+		{
+			name: "customer issuer uses default mapping",
+			token: signTestToken(t, customerKey, map[string]any{
+				"iss":       "customer-idp",
+				"aud":       []string{"my-api"},
+				"sub":       "user-1",
+				"tenant_id": "tenant-1",
+				"exp":       time.Now().Add(time.Hour).Unix(),
+			}),
+			wantClaims: ctxutil.Claims{UserID: "user-1", TenantID: "tenant-1"},
+		},
+		{
+			name: "service issuer uses own mapping",
+			token: signTestToken(t, serviceKey, map[string]any{
+				"iss":       "service-accounts",
+				"aud":       []string{"internal"},
+				"sub":       "ignored",
+				"client_id": "billing-svc",
+				"roles":     []any{"service"},
+				"exp":       time.Now().Add(time.Hour).Unix(),
+			}),
+			wantClaims: ctxutil.Claims{UserID: "billing-svc", Roles: []string{"service"}},
+		},
+		{
+			name: "service issuer rejects foreign audience",
+			token: signTestToken(t, serviceKey, map[string]any{
+				"iss": "service-accounts",
+				"aud": []string{"other-api"},
+				"sub": "svc",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}),
+			wantErr: ErrInvalidAudience,
+		},
+		{
+			name: "token signed with another issuer's key",
+			token: signTestToken(t, customerKey, map[string]any{
+				"iss": "service-accounts",
+				"aud": []string{"my-api"},
+				"sub": "svc",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}),
+			wantErr: ErrSignatureVerification,
+		},
+		{
+			name: "unknown issuer",
+			token: signTestToken(t, customerKey, map[string]any{
+				"iss": "unknown",
+				"aud": []string{"my-api"},
+				"sub": "user-1",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}),
+			wantErr: ErrInvalidIssuer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			claims, err := auth.Authenticate(ctx, tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if claims.UserID != tt.wantClaims.UserID {
+				t.Errorf("UserID = %q, want %q", claims.UserID, tt.wantClaims.UserID)
+			}
+			if claims.TenantID != tt.wantClaims.TenantID {
+				t.Errorf("TenantID = %q, want %q", claims.TenantID, tt.wantClaims.TenantID)
+			}
+			if !stringSliceEqual(claims.Roles, tt.wantClaims.Roles) {
+				t.Errorf("Roles = %v, want %v", claims.Roles, tt.wantClaims.Roles)
+			}
+		})
+	}
+}
+
 // Test helpers
 
 func generateTestKeys(t *testing.T) (*rsa.PrivateKey, jwk.Key) {
@@ -718,3 +896,14 @@ func stringSliceEqual(a, b []string) bool {
 	}
 	return true
 }
+
+// newTestInterceptor creates an interceptor and fails the test on error.
+func newTestInterceptor(t *testing.T, auth TokenAuthenticator, opts ...InterceptorOption) *interceptor {
+	t.Helper()
+
+	i, err := NewValidatedInterceptor(auth, opts...)
+	if err != nil {
+		t.Fatalf("NewValidatedInterceptor() error = %v", err)
+	}
+	return i.(*interceptor)
+}
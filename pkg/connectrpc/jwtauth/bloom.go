@@ -0,0 +1,61 @@
+package jwtauth
+
+import (
+	"hash/maphash"
+	"math"
+	"sync/atomic"
+)
+
+// bloomFilter is a fixed-size Bloom filter over strings.
+// Adds and lookups are safe for concurrent use.
+type bloomFilter struct {
+	bits []uint64
+	m    uint64
+	k    uint64
+	seed maphash.Seed
+}
+
+// newBloomFilter sizes a filter for n entries at false positive rate p.
+func newBloomFilter(n int, p float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	m = max(m, 64)
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	k = max(k, 1)
+
+	return &bloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+		seed: maphash.MakeSeed(),
+	}
+}
+
+// add inserts s.
+func (b *bloomFilter) add(s string) {
+	h1, h2 := b.hash(s)
+	for i := range b.k {
+		bit := (h1 + i*h2) % b.m
+		atomic.OrUint64(&b.bits[bit/64], 1<<(bit%64))
+	}
+}
+
+// mayContain reports whether s may have been added. False means definitely not.
+func (b *bloomFilter) mayContain(s string) bool {
+	h1, h2 := b.hash(s)
+	for i := range b.k {
+		bit := (h1 + i*h2) % b.m
+		if atomic.LoadUint64(&b.bits[bit/64])&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hash derives the two base hashes for double hashing (Kirsch-Mitzenmacher).
+func (b *bloomFilter) hash(s string) (uint64, uint64) {
+	h := maphash.String(b.seed, s)
+	return h & math.MaxUint32, h>>32 | 1
+}
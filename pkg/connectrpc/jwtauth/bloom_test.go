@@ -0,0 +1,33 @@
+package jwtauth
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	t.Parallel()
+
+	const n = 10000
+	filter := newBloomFilter(n, 0.01)
+	for i := range n {
+		filter.add("jti:" + strconv.Itoa(i))
+	}
+
+	for i := range n {
+		if !filter.mayContain("jti:" + strconv.Itoa(i)) {
+			t.Fatalf("mayContain(jti:%d) = false for an added entry", i)
+		}
+	}
+
+	falsePositives := 0
+	for i := range n {
+		if filter.mayContain("sid:" + strconv.Itoa(i)) {
+			falsePositives++
+		}
+	}
+	// Allow generous slack over the 1% target to keep the test stable.
+	if rate := float64(falsePositives) / n; rate > 0.03 {
+		t.Errorf("false positive rate = %.4f, want <= 0.03", rate)
+	}
+}
@@ -0,0 +1,122 @@
+package jwtauth
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// claimTag is the struct tag naming the claim path of a custom claims field.
+const claimTag = "claim"
+
+// AuthenticatorOption configures an Authenticator or Introspector.
+type AuthenticatorOption func(*authenticatorOptions)
+
+type authenticatorOptions struct {
+	custom     claimsDecoder
+	revocation RevocationChecker
+
+	// err is the first invalid option, reported by the constructor.
+	err error
+}
+
+func newAuthenticatorOptions(opts []AuthenticatorOption) (authenticatorOptions, error) {
+	var o authenticatorOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o, o.err
+}
+
+// claimsDecoder builds application-specific claims by resolving claim paths with lookup.
+type claimsDecoder func(lookup func(path string) (any, bool)) (any, error)
+
+// WithCustomClaims decodes application-specific claims into a T on every
+// successful authentication and stores it in the request context with
+// ctxutil.WithCustomClaims, apart from ctxutil.Claims.
+// Read it in handlers with ctxutil.CustomClaims[T].
+//
+// T must be a struct. Fields are populated from the claim path in their
+// "claim" tag, using dot notation for nested claims like ClaimsMapping.
+// Values are converted with encoding/json, so any JSON-decodable field type works.
+// Add ",required" to reject tokens without the claim; fields without a tag are ignored:
+//
+//	type OrgClaims struct {
+//		OrgID string   `claim:"org.id,required"`
+//		Plan  string   `claim:"org.plan"`
+//		Flags []string `claim:"feature_flags"`
+//	}
+//
+// NewAuthenticator and NewIntrospector return ErrInvalidCustomClaims if T is
+// not a struct or a "claim" tag has an empty path.
+func WithCustomClaims[T any]() AuthenticatorOption {
+	decode, err := newClaimsDecoder[T]()
+	return func(o *authenticatorOptions) {
+		if err != nil {
+			o.err = cmp.Or(o.err, err)
+			return
+		}
+		o.custom = decode
+	}
+}
+
+// customField is a struct field populated from a claim path.
+type customField struct {
+	index    int
+	path     string
+	required bool
+}
+
+func newClaimsDecoder[T any]() (claimsDecoder, error) {
+	typ := reflect.TypeFor[T]()
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s is not a struct", ErrInvalidCustomClaims, typ)
+	}
+
+	var fields []customField
+	for n := range typ.NumField() {
+		f := typ.Field(n)
+		tag, ok := f.Tag.Lookup(claimTag)
+		if !ok || !f.IsExported() {
+			continue
+		}
+		path, opts, _ := strings.Cut(tag, ",")
+		if path == "" {
+			return nil, fmt.Errorf("%w: field %s.%s has an empty claim path", ErrInvalidCustomClaims, typ, f.Name)
+		}
+		fields = append(fields, customField{
+			index:    n,
+			path:     path,
+			required: opts == "required",
+		})
+	}
+
+	return func(lookup func(path string) (any, bool)) (any, error) {
+		var custom T
+		v := reflect.ValueOf(&custom).Elem()
+		for _, f := range fields {
+			raw, ok := lookup(f.path)
+			if !ok {
+				if f.required {
+					return nil, fmt.Errorf("%w: %s", ErrMissingClaim, f.path)
+				}
+				continue
+			}
+			if err := assignClaim(v.Field(f.index), raw); err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidClaim, f.path, err)
+			}
+		}
+		return custom, nil
+	}, nil
+}
+
+// assignClaim converts a decoded JSON claim value into field.
+func assignClaim(field reflect.Value, raw any) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, field.Addr().Interface())
+}
@@ -0,0 +1,214 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+type testOrgClaims struct {
+	OrgID    string   `claim:"org.id,required"`
+	Plan     string   `claim:"org.plan"`
+	Flags    []string `claim:"feature_flags"`
+	Seats    int      `claim:"org.seats"`
+	Internal string
+}
+
+func TestClaimsDecoder(t *testing.T) {
+	t.Parallel()
+
+	decode, err := newClaimsDecoder[testOrgClaims]()
+	if err != nil {
+		t.Fatalf("newClaimsDecoder() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		want    testOrgClaims
+		wantErr error
+	}{
+		{
+			name: "all claims",
+			claims: map[string]any{
+				"org":           map[string]any{"id": "org-1", "plan": "pro", "seats": float64(25)},
+				"feature_flags": []any{"beta", "export"},
+			},
+			want: testOrgClaims{OrgID: "org-1", Plan: "pro", Flags: []string{"beta", "export"}, Seats: 25},
+		},
+		{
+			name:   "optional claims absent",
+			claims: map[string]any{"org": map[string]any{"id": "org-1"}},
+			want:   testOrgClaims{OrgID: "org-1"},
+		},
+		{
+			name:    "required claim absent",
+			claims:  map[string]any{"org": map[string]any{"plan": "pro"}},
+			wantErr: ErrMissingClaim,
+		},
+		{
+			name:    "wrong type",
+			claims:  map[string]any{"org": map[string]any{"id": "org-1", "seats": "many"}},
+			wantErr: ErrInvalidClaim,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := decode(func(path string) (any, bool) {
+				return getNestedValue(tt.claims, path)
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("decode() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decode() error = %v", err)
+			}
+
+			custom, ok := got.(testOrgClaims)
+			if !ok {
+				t.Fatalf("decode() type = %T, want testOrgClaims", got)
+			}
+			if custom.OrgID != tt.want.OrgID || custom.Plan != tt.want.Plan || custom.Seats != tt.want.Seats ||
+				!slices.Equal(custom.Flags, tt.want.Flags) {
+				t.Errorf("decode() = %+v, want %+v", custom, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithCustomClaims_InvalidType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opt  AuthenticatorOption
+	}{
+		{
+			name: "not a struct",
+			opt:  WithCustomClaims[map[string]any](),
+		},
+		{
+			name: "empty claim path",
+			opt: WithCustomClaims[struct {
+				OrgID string `claim:",required"`
+			}](),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultIntrospectionConfig()
+			cfg.URL = "https://idp.example.com/introspect"
+			cfg.ClientID = "client"
+			cfg.ClientSecret = "s3cr3t"
+			if _, err := NewIntrospector(cfg, tt.opt); !errors.Is(err, ErrInvalidCustomClaims) {
+				t.Errorf("NewIntrospector() error = %v, want %v", err, ErrInvalidCustomClaims)
+			}
+
+			jwtCfg := DefaultConfig()
+			jwtCfg.JWKSURL = "https://idp.example.com/jwks"
+			jwtCfg.Issuer = "test-issuer"
+			jwtCfg.Audience = "test-audience"
+			if _, err := NewAuthenticator(context.Background(), jwtCfg, tt.opt); !errors.Is(err, ErrInvalidCustomClaims) {
+				t.Errorf("NewAuthenticator() error = %v, want %v", err, ErrInvalidCustomClaims)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_CustomClaims(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	srv := setupTestJWKSServer(t, pubKey)
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = srv.URL
+	cfg.Issuer = "test-issuer"
+	cfg.Audience = "test-audience"
+	cfg.TokenCacheSize = 10
+
+	auth, err := NewAuthenticator(context.Background(), cfg, WithCustomClaims[testOrgClaims]())
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	i := newTestInterceptor(t, auth)
+
+	base := map[string]any{
+		"iss": "test-issuer",
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	claims := map[string]any{"org": map[string]any{"id": "org-1", "plan": "pro"}}
+	maps.Copy(claims, base)
+	token := signTestToken(t, privKey, claims)
+
+	// The second call is served from the token cache, which does not hold custom claims.
+	for range 2 {
+		ctx, err := i.authenticate(context.Background(), token)
+		if err != nil {
+			t.Fatalf("authenticate() error = %v", err)
+		}
+		if id, _ := ctxutil.UserID(ctx); id != "user-123" {
+			t.Errorf("UserID = %q, want %q", id, "user-123")
+		}
+		custom, ok := ctxutil.CustomClaims[testOrgClaims](ctx)
+		if !ok {
+			t.Fatal("custom claims not found in context")
+		}
+		if custom.OrgID != "org-1" || custom.Plan != "pro" {
+			t.Errorf("custom claims = %+v, want OrgID org-1 and Plan pro", custom)
+		}
+	}
+
+	_, err = auth.Authenticate(context.Background(), signTestToken(t, privKey, base))
+	if !errors.Is(err, ErrMissingClaim) {
+		t.Errorf("Authenticate() without org.id error = %v, want %v", err, ErrMissingClaim)
+	}
+}
+
+func TestIntrospector_CustomClaims(t *testing.T) {
+	t.Parallel()
+
+	srv := newTestIntrospectionServer(t)
+	srv.set("opaque", map[string]any{
+		"active": true,
+		"sub":    "user-123",
+		"org":    map[string]any{"id": "org-1", "seats": 3},
+	})
+
+	cfg := DefaultIntrospectionConfig()
+	cfg.URL = srv.URL
+	cfg.ClientID = "client"
+	cfg.ClientSecret = "s3cr3t"
+
+	i, err := NewIntrospector(cfg, WithCustomClaims[testOrgClaims]())
+	if err != nil {
+		t.Fatalf("NewIntrospector() error = %v", err)
+	}
+
+	ctx, err := newTestInterceptor(t, i).authenticate(context.Background(), "opaque")
+	if err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+	custom, ok := ctxutil.CustomClaims[testOrgClaims](ctx)
+	if !ok || custom.OrgID != "org-1" || custom.Seats != 3 {
+		t.Errorf("custom claims = %+v, want OrgID org-1 and Seats 3", custom)
+	}
+}
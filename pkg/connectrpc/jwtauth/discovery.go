@@ -0,0 +1,173 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jws"
+)
+
+// discoveryPath is appended to the issuer URL to locate the OIDC provider metadata.
+const discoveryPath = "/.well-known/openid-configuration"
+
+// maxDiscoveryDocumentSize bounds the provider metadata response body.
+const maxDiscoveryDocumentSize = 1 << 20
+
+// providerMetadata is the subset of OpenID Provider Metadata used for token validation.
+type providerMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// keySource is where an issuer's signing keys come from.
+// It is replaced atomically when discovery metadata is refreshed.
+type keySource struct {
+	jwksURL string
+
+	// algorithms lists accepted "alg" header values. Empty accepts any algorithm.
+	algorithms []string
+}
+
+// discoveryURL returns the provider metadata URL for issuer.
+func discoveryURL(issuer string) string {
+	return strings.TrimSuffix(issuer, "/") + discoveryPath
+}
+
+// discover fetches the OIDC provider metadata for issuer and checks that the
+// advertised issuer matches exactly, as required by OpenID Connect Discovery 1.0.
+func discover(ctx context.Context, client *http.Client, issuer string) (providerMetadata, error) {
+	u := discoveryURL(issuer)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return providerMetadata{}, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return providerMetadata{}, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return providerMetadata{}, fmt.Errorf("%w: %s returned status %d", ErrDiscovery, u, resp.StatusCode)
+	}
+
+	var md providerMetadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDiscoveryDocumentSize)).Decode(&md); err != nil {
+		return providerMetadata{}, fmt.Errorf("%w: decode %s: %v", ErrDiscovery, u, err)
+	}
+
+	if md.Issuer != issuer {
+		return providerMetadata{}, fmt.Errorf("%w: configured %q, discovered %q", ErrIssuerMismatch, issuer, md.Issuer)
+	}
+	if md.JWKSURI == "" {
+		return providerMetadata{}, fmt.Errorf("%w: %s has no jwks_uri", ErrDiscovery, u)
+	}
+
+	return md, nil
+}
+
+// resolveKeySource builds the key source for iss, running discovery if enabled.
+// An explicitly configured JWKS URL takes precedence over the discovered jwks_uri.
+func resolveKeySource(ctx context.Context, client *http.Client, iss *issuer) (*keySource, error) {
+	if !iss.discovery {
+		return &keySource{jwksURL: iss.jwksURL}, nil
+	}
+
+	md, err := discover(ctx, client, iss.issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &keySource{
+		jwksURL:    md.JWKSURI,
+		algorithms: md.IDTokenSigningAlgValuesSupported,
+	}
+	if iss.jwksURL != "" {
+		src.jwksURL = iss.jwksURL
+	}
+	return src, nil
+}
+
+// refreshDiscovery periodically re-fetches provider metadata until ctx is done.
+func (a *Authenticator) refreshDiscovery(ctx context.Context, client *http.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, iss := range a.issuers {
+				if iss.discovery {
+					a.refreshIssuer(ctx, client, iss)
+				}
+			}
+		}
+	}
+}
+
+// refreshIssuer updates the key source of iss from fresh discovery metadata.
+// On failure the previous metadata stays in effect.
+func (a *Authenticator) refreshIssuer(ctx context.Context, client *http.Client, iss *issuer) {
+	fetchCtx, cancel := context.WithTimeout(ctx, client.Timeout)
+	defer cancel()
+
+	src, err := resolveKeySource(fetchCtx, client, iss)
+	if err != nil {
+		slog.WarnContext(ctx, "oidc discovery refresh failed",
+			"issuer", iss.issuer,
+			"error", err,
+		)
+		return
+	}
+
+	prev := iss.keys.Load()
+	if src.jwksURL != prev.jwksURL {
+		if !a.cache.IsRegistered(fetchCtx, src.jwksURL) {
+			if err := a.cache.Register(fetchCtx, src.jwksURL); err != nil {
+				slog.WarnContext(ctx, "oidc discovery refresh failed",
+					"issuer", iss.issuer,
+					"error", fmt.Errorf("register jwks url %s: %w", src.jwksURL, err),
+				)
+				return
+			}
+		}
+		slog.InfoContext(ctx, "oidc jwks_uri changed",
+			"issuer", iss.issuer,
+			"jwks_url", src.jwksURL,
+		)
+	}
+
+	iss.keys.Store(src)
+}
+
+// checkAlgorithm rejects tokens signed with an algorithm the issuer does not advertise.
+func checkAlgorithm(token string, src *keySource) error {
+	if len(src.algorithms) == 0 {
+		return nil
+	}
+
+	msg, err := jws.Parse([]byte(token))
+	if err != nil {
+		return fmt.Errorf("parse token: %w", err)
+	}
+	for _, sig := range msg.Signatures() {
+		alg, ok := sig.ProtectedHeaders().Algorithm()
+		if !ok || !slices.Contains(src.algorithms, alg.String()) {
+			return fmt.Errorf("validate token: %w", ErrUnsupportedAlgorithm)
+		}
+	}
+	return nil
+}
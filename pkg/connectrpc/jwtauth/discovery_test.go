@@ -0,0 +1,260 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwk"
+)
+
+// testIdP is a stand-in OpenID provider serving discovery metadata and JWKS.
+type testIdP struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	metadata map[string]any
+	keysets  map[string]jwk.Set
+	status   int
+}
+
+func newTestIdP(t *testing.T, pubKey jwk.Key) *testIdP {
+	t.Helper()
+
+	idp := &testIdP{keysets: make(map[string]jwk.Set), status: http.StatusOK}
+	idp.Server = httptest.NewServer(http.HandlerFunc(idp.serveHTTP))
+	t.Cleanup(idp.Close)
+
+	idp.setKeys(t, "/jwks", pubKey)
+	idp.metadata = map[string]any{
+		"issuer":                                idp.URL,
+		"jwks_uri":                              idp.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	}
+	return idp
+}
+
+func (p *testIdP) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == discoveryPath {
+		if p.status != http.StatusOK {
+			w.WriteHeader(p.status)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(p.metadata)
+		return
+	}
+	if set, ok := p.keysets[r.URL.Path]; ok {
+		_ = json.NewEncoder(w).Encode(set)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (p *testIdP) setKeys(t *testing.T, path string, pubKey jwk.Key) {
+	t.Helper()
+
+	set := jwk.NewSet()
+	if err := set.AddKey(pubKey); err != nil {
+		t.Fatalf("failed to add key to set: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keysets[path] = set
+}
+
+func (p *testIdP) setMetadata(key string, value any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metadata[key] = value
+}
+
+func (p *testIdP) setStatus(status int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = status
+}
+
+func TestNewAuthenticator_Discovery(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		setup   func(idp *testIdP)
+		wantErr error
+	}{
+		{
+			name:  "valid metadata",
+			setup: func(*testIdP) {},
+		},
+		{
+			name:    "issuer mismatch",
+			setup:   func(idp *testIdP) { idp.setMetadata("issuer", "https://evil.example.com") },
+			wantErr: ErrIssuerMismatch,
+		},
+		{
+			name:    "missing jwks_uri",
+			setup:   func(idp *testIdP) { idp.setMetadata("jwks_uri", "") },
+			wantErr: ErrDiscovery,
+		},
+		{
+			name:    "metadata not found",
+			setup:   func(idp *testIdP) { idp.setStatus(http.StatusNotFound) },
+			wantErr: ErrDiscovery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, pubKey := generateTestKeys(t)
+			idp := newTestIdP(t, pubKey)
+			tt.setup(idp)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			_, err := NewAuthenticator(ctx, Config{
+				Issuer:    idp.URL,
+				Audience:  "test-audience",
+				Discovery: true,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewAuthenticator() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticator_DiscoveryAlgorithms(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		algorithms []string
+		wantErr    error
+	}{
+		{
+			name:       "advertised algorithm",
+			algorithms: []string{"ES256", "RS256"},
+		},
+		{
+			name:       "no algorithms advertised",
+			algorithms: nil,
+		},
+		{
+			name:       "algorithm not advertised",
+			algorithms: []string{"ES256"},
+			wantErr:    ErrUnsupportedAlgorithm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			privKey, pubKey := generateTestKeys(t)
+			idp := newTestIdP(t, pubKey)
+			idp.setMetadata("id_token_signing_alg_values_supported", tt.algorithms)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			auth, err := NewAuthenticator(ctx, Config{
+				Issuer:    idp.URL,
+				Audience:  "test-audience",
+				Discovery: true,
+			})
+			if err != nil {
+				t.Fatalf("NewAuthenticator() error = %v", err)
+			}
+
+			token := signTestToken(t, privKey, map[string]any{
+				"iss": idp.URL,
+				"aud": "test-audience",
+				"sub": "user-123",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			})
+
+			claims, err := auth.Authenticate(ctx, token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && claims.UserID != "user-123" {
+				t.Errorf("UserID = %q, want %q", claims.UserID, "user-123")
+			}
+		})
+	}
+}
+
+func TestAuthenticator_DiscoveryRefresh(t *testing.T) {
+	t.Parallel()
+
+	oldPriv, oldPub := generateTestKeys(t)
+	idp := newTestIdP(t, oldPub)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	auth, err := NewAuthenticator(ctx, Config{
+		Issuer:    idp.URL,
+		Audience:  "test-audience",
+		Discovery: true,
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	iss := auth.issuers[idp.URL]
+
+	newPriv, newPub := generateTestKeys(t)
+	if err := newPub.Set(jwk.KeyIDKey, "rotated-key-id"); err != nil {
+		t.Fatalf("failed to set key ID: %v", err)
+	}
+
+	mint := func(priv *rsa.PrivateKey, kid string) string {
+		claims := map[string]any{
+			"iss": idp.URL,
+			"aud": "test-audience",
+			"sub": "user-123",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		return signTestTokenWithKeyID(t, priv, kid, claims)
+	}
+
+	// A mismatching issuer during refresh keeps the previous metadata.
+	idp.setMetadata("issuer", "https://evil.example.com")
+	auth.refreshIssuer(ctx, client, iss)
+	if got := iss.keys.Load().jwksURL; got != idp.URL+"/jwks" {
+		t.Fatalf("jwksURL after failed refresh = %q, want %q", got, idp.URL+"/jwks")
+	}
+	if _, err := auth.Authenticate(ctx, mint(oldPriv, "test-key-id")); err != nil {
+		t.Fatalf("Authenticate() with old key error = %v", err)
+	}
+
+	// A new jwks_uri is picked up on the next refresh.
+	idp.setKeys(t, "/jwks-v2", newPub)
+	idp.setMetadata("issuer", idp.URL)
+	idp.setMetadata("jwks_uri", idp.URL+"/jwks-v2")
+	auth.refreshIssuer(ctx, client, iss)
+	if got := iss.keys.Load().jwksURL; got != idp.URL+"/jwks-v2" {
+		t.Fatalf("jwksURL after refresh = %q, want %q", got, idp.URL+"/jwks-v2")
+	}
+	if _, err := auth.Authenticate(ctx, mint(newPriv, "rotated-key-id")); err != nil {
+		t.Errorf("Authenticate() with rotated key error = %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, mint(oldPriv, "test-key-id")); err == nil {
+		t.Error("Authenticate() with key from previous jwks_uri succeeded, want error")
+	}
+}
@@ -0,0 +1,303 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jws"
+)
+
+const (
+	// dpopHeader carries the DPoP proof JWT.
+	dpopHeader = "DPoP"
+
+	// dpopScheme is the Authorization scheme of DPoP-bound access tokens.
+	dpopScheme = "DPoP "
+
+	// dpopType is the required "typ" header of DPoP proofs.
+	dpopType = "dpop+jwt"
+)
+
+// DPoPConfig holds configuration for DPoP proof validation (RFC 9449).
+type DPoPConfig struct {
+	// BaseURL is the public scheme and host clients call, e.g. "https://api.example.com".
+	// A proof's "htu" must equal BaseURL followed by the Connect procedure path.
+	// If empty, only the path of "htu" is compared, for services behind proxies
+	// that rewrite the host.
+	BaseURL string `koanf:"base_url"`
+
+	// Required rejects access tokens that are not DPoP-bound.
+	// If false, bearer tokens without a "cnf.jkt" claim are still accepted.
+	Required bool `koanf:"required"`
+
+	// Algorithms lists accepted proof signing algorithms.
+	// Default: ES256, ES384, ES512, RS256, RS384, RS512, PS256, PS384, PS512, EdDSA.
+	Algorithms []string `koanf:"algorithms"`
+
+	// ProofLifetime is how long after its "iat" a proof is accepted.
+	// Proof IDs are remembered for this long to reject replays.
+	// Default: 1 minute.
+	ProofLifetime time.Duration `koanf:"proof_lifetime"`
+
+	// Leeway allows clock skew tolerance for the proof's "iat".
+	// Default: 5 seconds.
+	Leeway time.Duration `koanf:"leeway"`
+}
+
+// DefaultDPoPConfig returns a DPoPConfig with sensible default values.
+func DefaultDPoPConfig() DPoPConfig {
+	return DPoPConfig{
+		Algorithms:    defaultDPoPAlgorithms(),
+		ProofLifetime: time.Minute,
+		Leeway:        5 * time.Second,
+	}
+}
+
+// Validate checks that all fields are within range.
+// Returns nil if configuration is valid.
+func (c DPoPConfig) Validate() error {
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			return ErrInvalidBaseURL
+		}
+	}
+	for _, alg := range c.Algorithms {
+		if alg == "none" || strings.HasPrefix(alg, "HS") {
+			return fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+		}
+	}
+	return nil
+}
+
+func defaultDPoPAlgorithms() []string {
+	return []string{"ES256", "ES384", "ES512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "EdDSA"}
+}
+
+// ReplayStore remembers DPoP proof IDs to reject replayed proofs.
+// Implementations must be safe for concurrent use and shared by all
+// instances that accept the same tokens.
+type ReplayStore interface {
+	// Seen records key until expiresAt and reports whether it was already recorded.
+	Seen(ctx context.Context, key string, expiresAt time.Time) (bool, error)
+}
+
+// WithDPoP validates DPoP proofs for sender-constrained tokens.
+//
+// Tokens presented with "Authorization: DPoP <token>" must carry a "cnf.jkt" claim
+// and come with a "DPoP" header proof whose key matches that thumbprint, whose
+// "htm"/"htu" match the RPC, and whose "ath" matches the token. DPoP-bound tokens
+// presented as bearer tokens are rejected. Proof IDs are checked against store;
+// nil uses an InMemoryReplayStore, which only protects a single instance.
+//
+// Server-issued nonces (the "DPoP-Nonce" header, RFC 9449 section 8) are not
+// supported: proofs are bounded by ProofLifetime and the replay store instead,
+// and a proof's "nonce" claim is ignored.
+//
+// DPoP requires the interceptor's authenticator to be an *Authenticator or *Introspector.
+// NewValidatedInterceptor returns an error if cfg is invalid or the authenticator
+// does not support DPoP; NewInterceptor panics.
+func WithDPoP(cfg DPoPConfig, store ReplayStore) InterceptorOption {
+	return func(i *interceptor) {
+		i.dpopCfg = &cfg
+		i.dpopStore = store
+	}
+}
+
+// newDPoPValidator creates a validator for a valid cfg. A nil store uses an
+// InMemoryReplayStore.
+func newDPoPValidator(cfg DPoPConfig, store ReplayStore) *dpopValidator {
+	if store == nil {
+		store = NewInMemoryReplayStore()
+	}
+
+	v := &dpopValidator{
+		required:   cfg.Required,
+		algorithms: cfg.Algorithms,
+		lifetime:   cfg.ProofLifetime,
+		leeway:     cfg.Leeway,
+		store:      store,
+		now:        time.Now,
+	}
+	if len(v.algorithms) == 0 {
+		v.algorithms = defaultDPoPAlgorithms()
+	}
+	if v.lifetime == 0 {
+		v.lifetime = time.Minute
+	}
+	if cfg.BaseURL != "" {
+		v.baseURL, _ = url.Parse(strings.TrimSuffix(cfg.BaseURL, "/"))
+	}
+	return v
+}
+
+// dpopValidator checks DPoP proofs against the request and the access token.
+type dpopValidator struct {
+	baseURL    *url.URL
+	required   bool
+	algorithms []string
+	lifetime   time.Duration
+	leeway     time.Duration
+	store      ReplayStore
+	now        func() time.Time
+}
+
+// dpopProof is the payload of a DPoP proof JWT.
+type dpopProof struct {
+	ID          string  `json:"jti"`
+	Method      string  `json:"htm"`
+	URI         string  `json:"htu"`
+	IssuedAt    float64 `json:"iat"`
+	AccessToken string  `json:"ath"`
+}
+
+// dpopToken returns the access token of an "Authorization: DPoP <token>" header.
+func dpopToken(headers http.Header) (string, bool) {
+	authHeader := headers.Get("Authorization")
+	if !strings.HasPrefix(authHeader, dpopScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(authHeader, dpopScheme), true
+}
+
+// authenticateDPoP validates token and, for DPoP-bound tokens, the request's proof.
+// dpop reports whether the token was presented with the DPoP scheme.
+func (i *interceptor) authenticateDPoP(ctx context.Context, token string, dpop bool, method, procedure string, headers http.Header) (context.Context, error) {
+	auth := i.auth.(identityAuthenticator)
+	res, err := auth.authenticateToken(ctx, token)
+	if err != nil {
+		return nil, i.mapToConnectError(err)
+	}
+
+	switch {
+	case res.identity.Thumbprint == "" && (dpop || i.dpop.required):
+		return nil, connect.NewError(connect.CodeUnauthenticated, ErrDPoPBindingRequired)
+	case res.identity.Thumbprint != "" && !dpop:
+		return nil, connect.NewError(connect.CodeUnauthenticated, ErrDPoPProofRequired)
+	case res.identity.Thumbprint != "":
+		if err := i.dpop.validate(ctx, headers.Values(dpopHeader), method, procedure, token, res.identity.Thumbprint); err != nil {
+			return nil, i.mapToConnectError(err)
+		}
+	}
+
+	return res.context(ctx), nil
+}
+
+// validate checks the DPoP proofs of a request for an access token bound to thumbprint.
+func (v *dpopValidator) validate(ctx context.Context, proofs []string, method, procedure, token, thumbprint string) error {
+	if len(proofs) == 0 {
+		return ErrDPoPProofRequired
+	}
+	if len(proofs) > 1 {
+		return fmt.Errorf("%w: multiple proofs", ErrInvalidDPoPProof)
+	}
+
+	key, proof, err := v.verify(proofs[0])
+	if err != nil {
+		return err
+	}
+
+	if proof.ID == "" {
+		return fmt.Errorf("%w: missing jti", ErrInvalidDPoPProof)
+	}
+	if proof.Method != method {
+		return fmt.Errorf("%w: htm %q does not match %s", ErrInvalidDPoPProof, proof.Method, method)
+	}
+	if !v.matchURI(proof.URI, procedure) {
+		return fmt.Errorf("%w: htu %q does not match %s", ErrInvalidDPoPProof, proof.URI, procedure)
+	}
+
+	now := v.now()
+	issuedAt := time.Unix(int64(proof.IssuedAt), 0)
+	if issuedAt.After(now.Add(v.leeway)) || !issuedAt.Add(v.lifetime+v.leeway).After(now) {
+		return fmt.Errorf("%w: iat outside the accepted window", ErrInvalidDPoPProof)
+	}
+
+	ath := sha256.Sum256([]byte(token))
+	if subtle.ConstantTimeCompare([]byte(proof.AccessToken), []byte(base64.RawURLEncoding.EncodeToString(ath[:]))) != 1 {
+		return fmt.Errorf("%w: ath does not match the access token", ErrInvalidDPoPProof)
+	}
+
+	jkt, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("%w: thumbprint: %v", ErrInvalidDPoPProof, err)
+	}
+	if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(jkt)), []byte(thumbprint)) != 1 {
+		return ErrDPoPKeyMismatch
+	}
+
+	// Proof IDs are only unique per key, so replays are tracked per thumbprint.
+	seen, err := v.store.Seen(ctx, thumbprint+":"+proof.ID, issuedAt.Add(v.lifetime+v.leeway))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrReplayCheck, err)
+	}
+	if seen {
+		return ErrDPoPReplay
+	}
+	return nil
+}
+
+// verify checks the proof's header and signature against its embedded public key.
+func (v *dpopValidator) verify(proof string) (jwk.Key, dpopProof, error) {
+	msg, err := jws.Parse([]byte(proof))
+	if err != nil {
+		return nil, dpopProof{}, fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+	}
+	sigs := msg.Signatures()
+	if len(sigs) != 1 {
+		return nil, dpopProof{}, fmt.Errorf("%w: expected one signature", ErrInvalidDPoPProof)
+	}
+
+	headers := sigs[0].ProtectedHeaders()
+	if typ, _ := headers.Type(); typ != dpopType {
+		return nil, dpopProof{}, fmt.Errorf("%w: typ %q", ErrInvalidDPoPProof, typ)
+	}
+	alg, ok := headers.Algorithm()
+	if !ok || !slices.Contains(v.algorithms, alg.String()) {
+		return nil, dpopProof{}, fmt.Errorf("%w: alg %q", ErrInvalidDPoPProof, alg)
+	}
+	key, ok := headers.JWK()
+	if !ok {
+		return nil, dpopProof{}, fmt.Errorf("%w: missing jwk header", ErrInvalidDPoPProof)
+	}
+	if private, err := jwk.IsPrivateKey(key); err != nil || private {
+		return nil, dpopProof{}, fmt.Errorf("%w: jwk header is not a public asymmetric key", ErrInvalidDPoPProof)
+	}
+
+	payload, err := jws.Verify([]byte(proof), jws.WithKey(alg, key))
+	if err != nil {
+		return nil, dpopProof{}, fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+	}
+
+	var p dpopProof
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, dpopProof{}, fmt.Errorf("%w: %v", ErrInvalidDPoPProof, err)
+	}
+	return key, p, nil
+}
+
+// matchURI compares a proof's "htu" with the procedure URL, ignoring query and fragment.
+func (v *dpopValidator) matchURI(htu, procedure string) bool {
+	u, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	if v.baseURL == nil {
+		return u.Path == procedure
+	}
+	return strings.EqualFold(u.Scheme, v.baseURL.Scheme) &&
+		strings.EqualFold(u.Host, v.baseURL.Host) &&
+		u.Path == v.baseURL.Path+procedure
+}
@@ -0,0 +1,506 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jws"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+const dpopTestProcedure = "/test.Service/Method"
+
+func TestDPoPConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     DPoPConfig
+		wantErr error
+	}{
+		{
+			name:    "default config",
+			cfg:     DefaultDPoPConfig(),
+			wantErr: nil,
+		},
+		{
+			name:    "base url",
+			cfg:     DPoPConfig{BaseURL: "https://api.example.com"},
+			wantErr: nil,
+		},
+		{
+			name:    "relative base url",
+			cfg:     DPoPConfig{BaseURL: "api.example.com"},
+			wantErr: ErrInvalidBaseURL,
+		},
+		{
+			name:    "base url with query",
+			cfg:     DPoPConfig{BaseURL: "https://api.example.com?x=1"},
+			wantErr: ErrInvalidBaseURL,
+		},
+		{
+			name:    "symmetric algorithm",
+			cfg:     DPoPConfig{Algorithms: []string{"HS256"}},
+			wantErr: ErrUnsupportedAlgorithm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.cfg.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInterceptor_DPoP(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	srv := setupTestJWKSServer(t, pubKey)
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = srv.URL
+	cfg.Issuer = "test-issuer"
+	cfg.Audience = "test-audience"
+	auth, err := NewAuthenticator(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	dpopCfg := DefaultDPoPConfig()
+	dpopCfg.BaseURL = "https://api.example.com"
+	i := newTestInterceptor(t, auth, WithDPoP(dpopCfg, nil))
+
+	proofKey := generateProofKey(t)
+	otherKey := generateProofKey(t)
+
+	token := func(jkt string) string {
+		claims := map[string]any{
+			"iss": "test-issuer",
+			"aud": []string{"test-audience"},
+			"sub": "user-123",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		if jkt != "" {
+			claims["cnf"] = map[string]any{"jkt": jkt}
+		}
+		return signTestToken(t, privKey, claims)
+	}
+	bound := token(thumbprint(t, proofKey))
+	unbound := token("")
+
+	validClaims := func(tok string) map[string]any {
+		return map[string]any{
+			"jti": rand.Text(),
+			"htm": http.MethodPost,
+			"htu": "https://api.example.com" + dpopTestProcedure,
+			"iat": time.Now().Unix(),
+			"ath": accessTokenHash(tok),
+		}
+	}
+	with := func(key, value string) map[string]any {
+		c := validClaims(bound)
+		c[key] = value
+		return c
+	}
+
+	replayed := signProof(t, proofKey, dpopType, validClaims(bound))
+	if _, err := i.authenticateProcedure(context.Background(), dpopTestProcedure, http.MethodPost, http.Header{
+		"Authorization": {"DPoP " + bound},
+		"Dpop":          {replayed},
+	}, connect.Peer{}); err != nil {
+		t.Fatalf("authenticateProcedure() first use error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr error
+	}{
+		{
+			name: "valid proof",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, proofKey, dpopType, validClaims(bound))},
+			},
+		},
+		{
+			name: "htu with query",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, proofKey, dpopType, with("htu", "https://API.example.com"+dpopTestProcedure+"?x=1"))},
+			},
+		},
+		{
+			name:   "unbound bearer token",
+			header: http.Header{"Authorization": {"Bearer " + unbound}},
+		},
+		{
+			name: "replayed proof",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {replayed},
+			},
+			wantErr: ErrDPoPReplay,
+		},
+		{
+			name:    "missing proof",
+			header:  http.Header{"Authorization": {"DPoP " + bound}},
+			wantErr: ErrDPoPProofRequired,
+		},
+		{
+			name:    "bound token as bearer",
+			header:  http.Header{"Authorization": {"Bearer " + bound}},
+			wantErr: ErrDPoPProofRequired,
+		},
+		{
+			name: "unbound token with dpop scheme",
+			header: http.Header{
+				"Authorization": {"DPoP " + unbound},
+				"Dpop":          {signProof(t, proofKey, dpopType, validClaims(unbound))},
+			},
+			wantErr: ErrDPoPBindingRequired,
+		},
+		{
+			name: "wrong method",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, proofKey, dpopType, with("htm", http.MethodGet))},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+		{
+			name: "wrong procedure",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, proofKey, dpopType, with("htu", "https://api.example.com/test.Service/Other"))},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+		{
+			name: "wrong host",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, proofKey, dpopType, with("htu", "https://evil.example.com"+dpopTestProcedure))},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+		{
+			name: "wrong access token hash",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, proofKey, dpopType, with("ath", accessTokenHash(unbound)))},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+		{
+			name: "stale proof",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop": {signProof(t, proofKey, dpopType, func() map[string]any {
+					c := validClaims(bound)
+					c["iat"] = time.Now().Add(-5 * time.Minute).Unix()
+					return c
+				}())},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+		{
+			name: "wrong typ",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, proofKey, "JWT", validClaims(bound))},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+		{
+			name: "private jwk header",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProofWithJWK(t, proofKey, privateJWK(t, proofKey), dpopType, validClaims(bound))},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+		{
+			name: "key does not match binding",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop":          {signProof(t, otherKey, dpopType, validClaims(bound))},
+			},
+			wantErr: ErrDPoPKeyMismatch,
+		},
+		{
+			name: "multiple proofs",
+			header: http.Header{
+				"Authorization": {"DPoP " + bound},
+				"Dpop": {
+					signProof(t, proofKey, dpopType, validClaims(bound)),
+					signProof(t, proofKey, dpopType, validClaims(bound)),
+				},
+			},
+			wantErr: ErrInvalidDPoPProof,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, err := i.authenticateProcedure(context.Background(), dpopTestProcedure, http.MethodPost, tt.header, connect.Peer{})
+			if tt.wantErr != nil {
+				var connectErr *connect.Error
+				if !errors.As(err, &connectErr) {
+					t.Fatalf("expected connect.Error, got %T", err)
+				}
+				if connectErr.Code() != connect.CodeUnauthenticated {
+					t.Errorf("code = %v, want %v", connectErr.Code(), connect.CodeUnauthenticated)
+				}
+				if !errors.Is(connectErr.Unwrap(), tt.wantErr) {
+					t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticateProcedure() error = %v", err)
+			}
+			if id, _ := ctxutil.UserID(ctx); id != "user-123" {
+				t.Errorf("UserID = %q, want %q", id, "user-123")
+			}
+		})
+	}
+}
+
+func TestInterceptor_DPoPRequired(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	srv := setupTestJWKSServer(t, pubKey)
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = srv.URL
+	cfg.Issuer = "test-issuer"
+	cfg.Audience = "test-audience"
+	auth, err := NewAuthenticator(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	dpopCfg := DefaultDPoPConfig()
+	dpopCfg.Required = true
+	i := newTestInterceptor(t, auth, WithDPoP(dpopCfg, nil))
+
+	token := signTestToken(t, privKey, map[string]any{
+		"iss": "test-issuer",
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	_, err = i.authenticateProcedure(context.Background(), dpopTestProcedure, http.MethodPost,
+		http.Header{"Authorization": {"Bearer " + token}}, connect.Peer{})
+	if !errors.Is(err, ErrDPoPBindingRequired) {
+		t.Errorf("authenticateProcedure() error = %v, want %v", err, ErrDPoPBindingRequired)
+	}
+}
+
+func TestInterceptor_DPoPReplayStoreFailure(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	srv := setupTestJWKSServer(t, pubKey)
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = srv.URL
+	cfg.Issuer = "test-issuer"
+	cfg.Audience = "test-audience"
+	auth, err := NewAuthenticator(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	i := newTestInterceptor(t, auth, WithDPoP(DefaultDPoPConfig(), failingReplayStore{}))
+
+	proofKey := generateProofKey(t)
+	token := signTestToken(t, privKey, map[string]any{
+		"iss": "test-issuer",
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"cnf": map[string]any{"jkt": thumbprint(t, proofKey)},
+	})
+	proof := signProof(t, proofKey, dpopType, map[string]any{
+		"jti": rand.Text(),
+		"htm": http.MethodPost,
+		"htu": "https://anywhere.example.com" + dpopTestProcedure,
+		"iat": time.Now().Unix(),
+		"ath": accessTokenHash(token),
+	})
+
+	_, err = i.authenticateProcedure(context.Background(), dpopTestProcedure, http.MethodPost,
+		http.Header{"Authorization": {"DPoP " + token}, "Dpop": {proof}}, connect.Peer{})
+	if connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Errorf("code = %v, want %v", connect.CodeOf(err), connect.CodeUnavailable)
+	}
+	if !errors.Is(err, ErrReplayCheck) {
+		t.Errorf("authenticateProcedure() error = %v, want %v", err, ErrReplayCheck)
+	}
+}
+
+func TestNewValidatedInterceptor_DPoPErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		auth    TokenAuthenticator
+		cfg     DPoPConfig
+		wantErr error
+	}{
+		{
+			name:    "unsupported authenticator",
+			auth:    &mockAuthenticator{},
+			cfg:     DefaultDPoPConfig(),
+			wantErr: ErrDPoPNotSupported,
+		},
+		{
+			name:    "invalid config",
+			auth:    &Authenticator{},
+			cfg:     DPoPConfig{BaseURL: "/relative"},
+			wantErr: ErrInvalidBaseURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewValidatedInterceptor(tt.auth, WithDPoP(tt.cfg, nil))
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewValidatedInterceptor() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInMemoryReplayStore(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewInMemoryReplayStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if seen, _ := store.Seen(ctx, "jkt:1", now.Add(time.Minute)); seen {
+		t.Error("Seen() first use = true, want false")
+	}
+	if seen, _ := store.Seen(ctx, "jkt:1", now.Add(time.Minute)); !seen {
+		t.Error("Seen() second use = false, want true")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if seen, _ := store.Seen(ctx, "jkt:1", now.Add(time.Minute)); seen {
+		t.Error("Seen() after expiry = true, want false")
+	}
+}
+
+func generateProofKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	return key
+}
+
+func privateJWK(t *testing.T, key *ecdsa.PrivateKey) jwk.Key {
+	t.Helper()
+
+	priv, err := jwk.Import(key)
+	if err != nil {
+		t.Fatalf("failed to import private key: %v", err)
+	}
+	return priv
+}
+
+func thumbprint(t *testing.T, key *ecdsa.PrivateKey) string {
+	t.Helper()
+
+	pub, err := jwk.Import(key.Public())
+	if err != nil {
+		t.Fatalf("failed to import public key: %v", err)
+	}
+	tp, err := pub.Thumbprint(crypto.SHA256)
+	if err != nil {
+		t.Fatalf("failed to compute thumbprint: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(tp)
+}
+
+func accessTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func signProof(t *testing.T, key *ecdsa.PrivateKey, typ string, claims map[string]any) string {
+	t.Helper()
+
+	pub, err := jwk.Import(key.Public())
+	if err != nil {
+		t.Fatalf("failed to import public key: %v", err)
+	}
+	return signProofWithJWK(t, key, pub, typ, claims)
+}
+
+// signProofWithJWK signs a proof with key and embeds pub as its "jwk" header.
+func signProofWithJWK(t *testing.T, key *ecdsa.PrivateKey, pub jwk.Key, typ string, claims map[string]any) string {
+	t.Helper()
+
+	priv, err := jwk.Import(key)
+	if err != nil {
+		t.Fatalf("failed to import private key: %v", err)
+	}
+
+	headers := jws.NewHeaders()
+	if err := headers.Set(jws.TypeKey, typ); err != nil {
+		t.Fatalf("failed to set typ: %v", err)
+	}
+	if err := headers.Set(jws.JWKKey, pub); err != nil {
+		t.Fatalf("failed to set jwk: %v", err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("failed to marshal proof claims: %v", err)
+	}
+	signed, err := jws.Sign(payload, jws.WithKey(jwa.ES256(), priv, jws.WithProtectedHeaders(headers)))
+	if err != nil {
+		t.Fatalf("failed to sign proof: %v", err)
+	}
+	return string(signed)
+}
+
+type failingReplayStore struct{}
+
+func (failingReplayStore) Seen(context.Context, string, time.Time) (bool, error) {
+	return false, errors.New("connection refused")
+}
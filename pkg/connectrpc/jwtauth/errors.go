@@ -29,7 +29,84 @@ var (
 	// ErrJWKSFetch is returned when JWKS cannot be fetched.
 	ErrJWKSFetch = errors.New("failed to fetch JWKS")
 
-	// ErrJWKSURLRequired is returned when JWKSURL is empty.
+	// ErrInvalidStaticJWKS is returned when a configured static JWKS cannot be
+	// read or contains anything but public asymmetric keys.
+	ErrInvalidStaticJWKS = errors.New("invalid static JWKS")
+
+	// ErrUnsupportedAlgorithm is returned when the token's signing algorithm is
+	// not advertised in the issuer's discovery metadata.
+	ErrUnsupportedAlgorithm = errors.New("unsupported token signing algorithm")
+
+	// ErrDiscovery is returned when OIDC discovery metadata cannot be fetched or is invalid.
+	ErrDiscovery = errors.New("failed to fetch OIDC discovery metadata")
+
+	// ErrIssuerMismatch is returned when the discovered issuer differs from the configured issuer.
+	ErrIssuerMismatch = errors.New("discovered issuer does not match configured issuer")
+
+	// ErrTokenInactive is returned when the introspection endpoint reports the token as inactive.
+	ErrTokenInactive = errors.New("token is not active")
+
+	// ErrIntrospection is returned when the introspection endpoint cannot be reached
+	// or returns an invalid response.
+	ErrIntrospection = errors.New("token introspection failed")
+
+	// ErrIntrospectionURLRequired is returned when IntrospectionConfig.URL is empty.
+	ErrIntrospectionURLRequired = errors.New("introspection url is required")
+
+	// ErrIntrospectionClientIDRequired is returned when IntrospectionConfig.ClientID is empty.
+	ErrIntrospectionClientIDRequired = errors.New("introspection client_id is required")
+
+	// ErrIntrospectionClientSecretRequired is returned when IntrospectionConfig.ClientSecret is empty.
+	ErrIntrospectionClientSecretRequired = errors.New("introspection client_secret is required")
+
+	// ErrInvalidCustomClaims is returned by the constructors when the type
+	// passed to WithCustomClaims is not a struct or has an empty claim path.
+	ErrInvalidCustomClaims = errors.New("invalid custom claims type")
+
+	// ErrMissingClaim is returned when a required custom claim is absent from the token.
+	ErrMissingClaim = errors.New("missing required claim")
+
+	// ErrInvalidClaim is returned when a custom claim cannot be decoded into its field type.
+	ErrInvalidClaim = errors.New("invalid claim value")
+
+	// ErrTokenRevoked is returned when the revocation checker reports the token as revoked.
+	ErrTokenRevoked = errors.New("token has been revoked")
+
+	// ErrRevocationCheck is returned when the revocation checker fails.
+	ErrRevocationCheck = errors.New("token revocation check failed")
+
+	// ErrInvalidFalsePositiveRate is returned when RevocationConfig.FalsePositiveRate is not in [0, 1).
+	ErrInvalidFalsePositiveRate = errors.New("false_positive_rate must be in [0, 1)")
+
+	// ErrDPoPProofRequired is returned when a DPoP-bound token is presented without
+	// the DPoP scheme or without a DPoP proof.
+	ErrDPoPProofRequired = errors.New("dpop proof required")
+
+	// ErrDPoPBindingRequired is returned when a token presented with the DPoP scheme,
+	// or any token when DPoP is required, has no "cnf.jkt" claim.
+	ErrDPoPBindingRequired = errors.New("token is not dpop-bound")
+
+	// ErrInvalidDPoPProof is returned when the DPoP proof is malformed, has an invalid
+	// signature, or does not match the request or access token.
+	ErrInvalidDPoPProof = errors.New("invalid dpop proof")
+
+	// ErrDPoPKeyMismatch is returned when the proof key does not match the token's "cnf.jkt".
+	ErrDPoPKeyMismatch = errors.New("dpop proof key does not match token binding")
+
+	// ErrDPoPReplay is returned when a DPoP proof is used more than once.
+	ErrDPoPReplay = errors.New("dpop proof replayed")
+
+	// ErrReplayCheck is returned when the DPoP replay store fails.
+	ErrReplayCheck = errors.New("dpop replay check failed")
+
+	// ErrDPoPNotSupported is returned when DPoP is enabled for an authenticator
+	// that is neither an *Authenticator nor an *Introspector.
+	ErrDPoPNotSupported = errors.New("authenticator does not support dpop")
+
+	// ErrInvalidBaseURL is returned when DPoPConfig.BaseURL is not an absolute URL.
+	ErrInvalidBaseURL = errors.New("base_url must be an absolute url without query or fragment")
+
+	// ErrJWKSURLRequired is returned when JWKSURL is empty and discovery is disabled.
 	ErrJWKSURLRequired = errors.New("jwks_url is required")
 
 	// ErrIssuerRequired is returned when Issuer is empty.
@@ -37,4 +114,13 @@ var (
 
 	// ErrAudienceRequired is returned when Audience is empty.
 	ErrAudienceRequired = errors.New("audience is required")
+
+	// ErrDuplicateIssuer is returned when the same issuer is configured twice.
+	ErrDuplicateIssuer = errors.New("duplicate issuer")
+
+	// ErrInvalidMode is returned when an interceptor mode is not recognized.
+	ErrInvalidMode = errors.New("invalid authentication mode")
+
+	// ErrProcedureRequired is returned when a procedure mode entry has an empty procedure.
+	ErrProcedureRequired = errors.New("procedure is required")
 )
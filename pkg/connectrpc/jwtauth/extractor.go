@@ -0,0 +1,85 @@
+package jwtauth
+
+import (
+	"net/http"
+	"strings"
+
+	"connectrpc.com/connect"
+)
+
+// TokenExtractor returns the raw bearer token carried by a request.
+// It returns ("", nil) when the request carries no token in its location so the
+// next extractor can be tried, and an error when a token is present but malformed.
+type TokenExtractor func(header http.Header, peer connect.Peer) (string, error)
+
+// FromAuthorizationHeader extracts the token from an "Authorization: Bearer <token>" header.
+// Other schemes, such as Basic, count as no token so that the next extractor is tried.
+// This is the default extractor.
+func FromAuthorizationHeader() TokenExtractor {
+	return func(header http.Header, _ connect.Peer) (string, error) {
+		authHeader := header.Get("Authorization")
+		const bearerPrefix = "Bearer "
+		if !strings.HasPrefix(authHeader, bearerPrefix) {
+			return "", nil
+		}
+		return strings.TrimPrefix(authHeader, bearerPrefix), nil
+	}
+}
+
+// FromHeader extracts the raw token from a custom header (e.g., "X-Access-Token").
+func FromHeader(name string) TokenExtractor {
+	return func(header http.Header, _ connect.Peer) (string, error) {
+		return header.Get(name), nil
+	}
+}
+
+// FromCookie extracts the token from the named cookie (e.g., for browser clients).
+func FromCookie(name string) TokenExtractor {
+	return func(header http.Header, _ connect.Peer) (string, error) {
+		for _, line := range header.Values("Cookie") {
+			cookies, err := http.ParseCookie(line)
+			if err != nil {
+				continue
+			}
+			for _, c := range cookies {
+				if c.Name == name && c.Value != "" {
+					return c.Value, nil
+				}
+			}
+		}
+		return "", nil
+	}
+}
+
+// FromQuery extracts the token from a URL query parameter.
+// Query parameters end up in access logs and browser history; prefer headers
+// and use this only where clients cannot set them (e.g., WebSocket-style GET requests).
+func FromQuery(param string) TokenExtractor {
+	return func(_ http.Header, peer connect.Peer) (string, error) {
+		return peer.Query.Get(param), nil
+	}
+}
+
+// WithTokenExtractors sets where the interceptor looks for the token.
+// Extractors are tried in order; the first one that finds a token wins.
+// Default: FromAuthorizationHeader().
+func WithTokenExtractors(extractors ...TokenExtractor) InterceptorOption {
+	return func(i *interceptor) {
+		i.extractors = extractors
+	}
+}
+
+// extractToken runs the configured extractors in order.
+// Returns "" with nil error when no extractor finds a token.
+func (i *interceptor) extractToken(header http.Header, peer connect.Peer) (string, error) {
+	for _, extract := range i.extractors {
+		token, err := extract(header, peer)
+		if err != nil {
+			return "", err
+		}
+		if token != "" {
+			return token, nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,204 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// mockAuthenticator accepts a single known token.
+type mockAuthenticator struct {
+	token  string
+	claims ctxutil.Claims
+}
+
+func (m *mockAuthenticator) Authenticate(_ context.Context, token string) (ctxutil.Claims, error) {
+	if token != m.token {
+		return ctxutil.Claims{}, ErrSignatureVerification
+	}
+	return m.claims, nil
+}
+
+func TestTokenExtractors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		extractor TokenExtractor
+		header    http.Header
+		query     url.Values
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "authorization header",
+			extractor: FromAuthorizationHeader(),
+			header:    http.Header{"Authorization": {"Bearer abc"}},
+			want:      "abc",
+		},
+		{
+			name:      "authorization header missing",
+			extractor: FromAuthorizationHeader(),
+			header:    http.Header{},
+			want:      "",
+		},
+		{
+			name:      "authorization header with other scheme",
+			extractor: FromAuthorizationHeader(),
+			header:    http.Header{"Authorization": {"Basic abc"}},
+			want:      "",
+		},
+		{
+			name:      "custom header",
+			extractor: FromHeader("X-Access-Token"),
+			header:    http.Header{"X-Access-Token": {"abc"}},
+			want:      "abc",
+		},
+		{
+			name:      "cookie",
+			extractor: FromCookie("session"),
+			header:    http.Header{"Cookie": {"theme=dark; session=abc"}},
+			want:      "abc",
+		},
+		{
+			name:      "cookie in second header line",
+			extractor: FromCookie("session"),
+			header:    http.Header{"Cookie": {"theme=dark", "session=abc"}},
+			want:      "abc",
+		},
+		{
+			name:      "cookie missing",
+			extractor: FromCookie("session"),
+			header:    http.Header{"Cookie": {"theme=dark"}},
+			want:      "",
+		},
+		{
+			name:      "query parameter",
+			extractor: FromQuery("access_token"),
+			query:     url.Values{"access_token": {"abc"}},
+			want:      "abc",
+		},
+		{
+			name:      "query parameter missing",
+			extractor: FromQuery("access_token"),
+			want:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := tt.extractor(tt.header, connect.Peer{Query: tt.query})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("extract error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("extract = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptor_WithTokenExtractors(t *testing.T) {
+	t.Parallel()
+
+	auth := &mockAuthenticator{token: "abc", claims: ctxutil.Claims{UserID: "user-123"}}
+	i := newTestInterceptor(t, auth,
+		WithTokenExtractors(FromCookie("session"), FromQuery("access_token")),
+		WithInterceptorConfig(InterceptorConfig{
+			Procedures: []ProcedureMode{{Procedure: "/pkg.Service/List", Mode: ModeOptional}},
+		}),
+	)
+
+	tests := []struct {
+		name      string
+		procedure string
+		header    http.Header
+		query     url.Values
+		wantUser  string
+		wantErr   error
+	}{
+		{
+			name:      "token from cookie",
+			procedure: "/pkg.Service/Get",
+			header:    http.Header{"Cookie": {"session=abc"}},
+			wantUser:  "user-123",
+		},
+		{
+			name:      "token from query when cookie is absent",
+			procedure: "/pkg.Service/Get",
+			header:    http.Header{},
+			query:     url.Values{"access_token": {"abc"}},
+			wantUser:  "user-123",
+		},
+		{
+			name:      "authorization header is ignored",
+			procedure: "/pkg.Service/Get",
+			header:    http.Header{"Authorization": {"Bearer abc"}},
+			wantErr:   ErrMissingToken,
+		},
+		{
+			name:      "optional procedure without token",
+			procedure: "/pkg.Service/List",
+			header:    http.Header{},
+		},
+		{
+			name:      "invalid token",
+			procedure: "/pkg.Service/Get",
+			header:    http.Header{"Cookie": {"session=wrong"}},
+			wantErr:   ErrSignatureVerification,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, err := i.authenticateProcedure(context.Background(), tt.procedure, http.MethodPost, tt.header, connect.Peer{Query: tt.query})
+			if tt.wantErr != nil {
+				var connectErr *connect.Error
+				if !errors.As(err, &connectErr) {
+					t.Fatalf("expected connect.Error, got %T", err)
+				}
+				if connectErr.Code() != connect.CodeUnauthenticated {
+					t.Errorf("code = %v, want %v", connectErr.Code(), connect.CodeUnauthenticated)
+				}
+				if !errors.Is(connectErr.Unwrap(), tt.wantErr) {
+					t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("authenticateProcedure() error = %v", err)
+			}
+
+			claims, _ := ctxutil.GetClaims(ctx)
+			if claims.UserID != tt.wantUser {
+				t.Errorf("UserID = %q, want %q", claims.UserID, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestInterceptor_OtherSchemeTriesNextExtractor(t *testing.T) {
+	t.Parallel()
+
+	auth := &mockAuthenticator{token: "abc", claims: ctxutil.Claims{UserID: "user-123"}}
+	i := newTestInterceptor(t, auth, WithTokenExtractors(FromAuthorizationHeader(), FromCookie("session")))
+
+	header := http.Header{"Authorization": {"Basic dXNlcjpwYXNz"}, "Cookie": {"session=abc"}}
+	ctx, err := i.authenticateProcedure(context.Background(), "/pkg.Service/Get", http.MethodPost, header, connect.Peer{})
+	if err != nil {
+		t.Fatalf("authenticateProcedure() error = %v", err)
+	}
+	if got, _ := ctxutil.UserID(ctx); got != "user-123" {
+		t.Errorf("UserID = %q, want %q", got, "user-123")
+	}
+}
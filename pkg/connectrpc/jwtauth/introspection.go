@@ -0,0 +1,264 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+	"github.com/deepworx/go-utils/pkg/tracing"
+)
+
+// maxIntrospectionResponseSize bounds the introspection response body.
+const maxIntrospectionResponseSize = 1 << 20
+
+// IntrospectionConfig holds configuration for validating opaque tokens with
+// OAuth2 Token Introspection (RFC 7662).
+type IntrospectionConfig struct {
+	// URL is the introspection endpoint (e.g., "https://idp.example.com/oauth2/introspect").
+	// Required.
+	URL string `koanf:"url"`
+
+	// ClientID and ClientSecret authenticate this resource server at the
+	// endpoint using HTTP Basic authentication. Use a koanfutil file:// value
+	// to load the secret from disk. Required.
+	ClientID     string `koanf:"client_id"`
+	ClientSecret string `koanf:"client_secret"`
+
+	// Audiences optionally restricts accepted tokens to those whose "aud"
+	// contains at least one of these values. Empty accepts any audience.
+	Audiences []string `koanf:"audiences"`
+
+	// ClaimsMapping defines how introspection response fields map to application
+	// claims, using the same dot notation as JWT claims.
+	ClaimsMapping *ClaimsMapping `koanf:"claims_mapping"`
+
+	// HTTPTimeout is the timeout for introspection requests.
+	HTTPTimeout time.Duration `koanf:"http_timeout"`
+
+	// CacheSize is the maximum number of cached active tokens.
+	// Active tokens are cached until their "exp"; tokens without "exp" are not cached.
+	// 0 disables caching.
+	CacheSize int `koanf:"cache_size"`
+
+	// NegativeCacheSize is the maximum number of cached inactive tokens. They
+	// are kept apart from active tokens, so a flood of invalid tokens cannot
+	// evict them. 0 disables negative caching.
+	NegativeCacheSize int `koanf:"negative_cache_size"`
+
+	// NegativeCacheTTL is how long inactive tokens are cached.
+	// 0 disables negative caching.
+	NegativeCacheTTL time.Duration `koanf:"negative_cache_ttl"`
+}
+
+// DefaultIntrospectionConfig returns an IntrospectionConfig with sensible default values.
+// URL, ClientID, and ClientSecret must be set by the caller.
+func DefaultIntrospectionConfig() IntrospectionConfig {
+	return IntrospectionConfig{
+		HTTPTimeout:       10 * time.Second,
+		CacheSize:         10000,
+		NegativeCacheSize: 1000,
+		NegativeCacheTTL:  10 * time.Second,
+		ClaimsMapping: &ClaimsMapping{
+			UserID:      "sub",
+			Permissions: "scope",
+		},
+	}
+}
+
+// Validate checks that all required fields are set.
+// Returns nil if configuration is valid.
+func (c IntrospectionConfig) Validate() error {
+	if c.URL == "" {
+		return ErrIntrospectionURLRequired
+	}
+	if c.ClientID == "" {
+		return ErrIntrospectionClientIDRequired
+	}
+	if c.ClientSecret == "" {
+		return ErrIntrospectionClientSecretRequired
+	}
+	return nil
+}
+
+// Introspector validates opaque access tokens against an OAuth2 introspection endpoint.
+type Introspector struct {
+	url          string
+	clientID     string
+	clientSecret string
+	audiences    []string
+	mapping      ClaimsMapping
+	client       *http.Client
+	cache        *lru[authResult]
+	negative     *lru[struct{}]
+	negativeTTL  time.Duration
+	custom       claimsDecoder
+	revocation   RevocationChecker
+	now          func() time.Time
+}
+
+// NewIntrospector creates an authenticator for opaque tokens.
+// Returns error if required config fields are empty or an option is invalid.
+func NewIntrospector(cfg IntrospectionConfig, opts ...AuthenticatorOption) (*Introspector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create introspector: %w", err)
+	}
+	options, err := newAuthenticatorOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("create introspector: %w", err)
+	}
+
+	httpTimeout := cfg.HTTPTimeout
+	if httpTimeout == 0 {
+		httpTimeout = 10 * time.Second
+	}
+
+	mapping := ClaimsMapping{UserID: "sub", Permissions: "scope"}
+	if cfg.ClaimsMapping != nil {
+		mapping = *cfg.ClaimsMapping
+		if mapping.UserID == "" {
+			mapping.UserID = "sub"
+		}
+	}
+
+	i := &Introspector{
+		url:          cfg.URL,
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		audiences:    cfg.Audiences,
+		mapping:      mapping,
+		client:       &http.Client{Timeout: httpTimeout},
+		negativeTTL:  cfg.NegativeCacheTTL,
+		custom:       options.custom,
+		revocation:   options.revocation,
+		now:          time.Now,
+	}
+	if cfg.CacheSize > 0 {
+		i.cache = newLRU[authResult](cfg.CacheSize)
+	}
+	if cfg.NegativeCacheSize > 0 && cfg.NegativeCacheTTL > 0 {
+		i.negative = newLRU[struct{}](cfg.NegativeCacheSize)
+	}
+	return i, nil
+}
+
+// Authenticate introspects the opaque token and returns extracted claims.
+// Token should be the raw token string (without "Bearer " prefix).
+func (i *Introspector) Authenticate(ctx context.Context, token string) (ctxutil.Claims, error) {
+	res, err := i.authenticateToken(ctx, token)
+	return res.claims, err
+}
+
+// authenticateToken introspects the opaque token and returns extracted claims, custom claims and its identity.
+func (i *Introspector) authenticateToken(ctx context.Context, token string) (authResult, error) {
+	key := tokenKey(token)
+	now := i.now()
+
+	if i.negative != nil {
+		if _, ok := i.negative.get(key, now); ok {
+			return authResult{}, fmt.Errorf("validate token: %w", ErrTokenInactive)
+		}
+	}
+	if i.cache != nil {
+		if res, ok := i.cache.get(key, now); ok {
+			if err := checkRevocation(ctx, i.revocation, res.identity); err != nil {
+				return authResult{}, err
+			}
+			return res, nil
+		}
+	}
+
+	resp, err := tracing.WithSpanResult(ctx, "jwtauth.introspect", func(ctx context.Context) (map[string]any, error) {
+		return i.introspect(ctx, token)
+	})
+	if err != nil {
+		return authResult{}, err
+	}
+
+	if active, _ := resp["active"].(bool); !active {
+		if i.negative != nil {
+			i.negative.add(key, struct{}{}, now.Add(i.negativeTTL))
+		}
+		return authResult{}, fmt.Errorf("validate token: %w", ErrTokenInactive)
+	}
+
+	exp, hasExp := numericDate(resp["exp"])
+	if hasExp && !now.Before(exp) {
+		return authResult{}, fmt.Errorf("validate token: %w", ErrTokenExpired)
+	}
+	if nbf, ok := numericDate(resp["nbf"]); ok && now.Before(nbf) {
+		return authResult{}, fmt.Errorf("validate token: %w", ErrTokenNotYetValid)
+	}
+
+	if len(i.audiences) > 0 {
+		aud, _ := toStringSlice(resp["aud"])
+		if !slices.ContainsFunc(i.audiences, func(want string) bool { return slices.Contains(aud, want) }) {
+			return authResult{}, fmt.Errorf("validate token: %w", ErrInvalidAudience)
+		}
+	}
+
+	lookup := func(path string) (any, bool) {
+		return getNestedValue(resp, path)
+	}
+	custom, err := decodeCustom(i.custom, lookup)
+	if err != nil {
+		return authResult{}, err
+	}
+
+	res := authResult{claims: i.mapping.extract(lookup), identity: tokenIdentity(lookup), custom: custom}
+	if i.cache != nil && hasExp {
+		i.cache.add(key, res, exp)
+	}
+	if err := checkRevocation(ctx, i.revocation, res.identity); err != nil {
+		return authResult{}, err
+	}
+	return res, nil
+}
+
+// introspect calls the introspection endpoint and decodes its JSON response.
+func (i *Introspector) introspect(ctx context.Context, token string) (map[string]any, error) {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospection, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// RFC 6749 section 2.3.1: credentials are form-encoded before Basic encoding.
+	req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrIntrospection, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrIntrospection, resp.StatusCode)
+	}
+
+	var body map[string]any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxIntrospectionResponseSize)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("%w: decode response: %v", ErrIntrospection, err)
+	}
+	return body, nil
+}
+
+// numericDate converts a JSON NumericDate (seconds since epoch) to time.Time.
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(f), 0), true
+}
@@ -0,0 +1,325 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// testIntrospectionServer answers RFC 7662 requests from a fixed token table.
+type testIntrospectionServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]map[string]any
+	status    int
+	calls     atomic.Int32
+}
+
+func newTestIntrospectionServer(t *testing.T) *testIntrospectionServer {
+	t.Helper()
+
+	s := &testIntrospectionServer{
+		responses: make(map[string]map[string]any),
+		status:    http.StatusOK,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.status != http.StatusOK {
+			w.WriteHeader(s.status)
+			return
+		}
+		resp, ok := s.responses[r.PostForm.Get("token")]
+		if !ok {
+			resp = map[string]any{"active": false}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testIntrospectionServer) set(token string, resp map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[token] = resp
+}
+
+func newTestIntrospector(t *testing.T, srv *testIntrospectionServer, now *time.Time) *Introspector {
+	t.Helper()
+
+	cfg := DefaultIntrospectionConfig()
+	cfg.URL = srv.URL
+	cfg.ClientID = "client"
+	cfg.ClientSecret = "s3cr3t"
+	cfg.ClaimsMapping.TenantID = "ext.org_id"
+
+	i, err := NewIntrospector(cfg)
+	if err != nil {
+		t.Fatalf("NewIntrospector() error = %v", err)
+	}
+	i.now = func() time.Time { return *now }
+	return i
+}
+
+func TestIntrospectionConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     IntrospectionConfig
+		wantErr error
+	}{
+		{
+			name:    "valid config",
+			cfg:     IntrospectionConfig{URL: "https://idp.example.com/introspect", ClientID: "client", ClientSecret: "s3cr3t"},
+			wantErr: nil,
+		},
+		{
+			name:    "missing URL",
+			cfg:     DefaultIntrospectionConfig(),
+			wantErr: ErrIntrospectionURLRequired,
+		},
+		{
+			name:    "missing client ID",
+			cfg:     IntrospectionConfig{URL: "https://idp.example.com/introspect", ClientSecret: "s3cr3t"},
+			wantErr: ErrIntrospectionClientIDRequired,
+		},
+		{
+			name:    "missing client secret",
+			cfg:     IntrospectionConfig{URL: "https://idp.example.com/introspect", ClientID: "client"},
+			wantErr: ErrIntrospectionClientSecretRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.cfg.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIntrospector_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	exp := float64(now.Add(time.Hour).Unix())
+
+	tests := []struct {
+		name      string
+		response  map[string]any
+		audiences []string
+		wantErr   error
+		wantUser  string
+		wantPerms []string
+		wantOrg   string
+	}{
+		{
+			name: "active token maps sub, scope and custom fields",
+			response: map[string]any{
+				"active": true,
+				"sub":    "user-123",
+				"scope":  "read write",
+				"exp":    exp,
+				"ext":    map[string]any{"org_id": "tenant-1"},
+			},
+			wantUser:  "user-123",
+			wantPerms: []string{"read", "write"},
+			wantOrg:   "tenant-1",
+		},
+		{
+			name:     "inactive token",
+			response: map[string]any{"active": false},
+			wantErr:  ErrTokenInactive,
+		},
+		{
+			name:     "active token already expired",
+			response: map[string]any{"active": true, "sub": "user-123", "exp": float64(now.Add(-time.Second).Unix())},
+			wantErr:  ErrTokenExpired,
+		},
+		{
+			name:     "active token not yet valid",
+			response: map[string]any{"active": true, "sub": "user-123", "exp": exp, "nbf": float64(now.Add(time.Minute).Unix())},
+			wantErr:  ErrTokenNotYetValid,
+		},
+		{
+			name:      "audience match",
+			response:  map[string]any{"active": true, "sub": "user-123", "aud": []any{"other", "my-api"}},
+			audiences: []string{"my-api"},
+			wantUser:  "user-123",
+		},
+		{
+			name:      "audience mismatch",
+			response:  map[string]any{"active": true, "sub": "user-123", "aud": "other"},
+			audiences: []string{"my-api"},
+			wantErr:   ErrInvalidAudience,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newTestIntrospectionServer(t)
+			srv.set("opaque", tt.response)
+			i := newTestIntrospector(t, srv, &now)
+			i.audiences = tt.audiences
+
+			claims, err := i.Authenticate(context.Background(), "opaque")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if claims.UserID != tt.wantUser {
+				t.Errorf("UserID = %q, want %q", claims.UserID, tt.wantUser)
+			}
+			if !stringSliceEqual(claims.Permissions, tt.wantPerms) {
+				t.Errorf("Permissions = %v, want %v", claims.Permissions, tt.wantPerms)
+			}
+			if claims.TenantID != tt.wantOrg {
+				t.Errorf("TenantID = %q, want %q", claims.TenantID, tt.wantOrg)
+			}
+		})
+	}
+}
+
+func TestIntrospector_Caching(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	srv := newTestIntrospectionServer(t)
+	srv.set("active", map[string]any{"active": true, "sub": "user-123", "exp": float64(now.Add(time.Minute).Unix())})
+	srv.set("no-exp", map[string]any{"active": true, "sub": "user-123"})
+	i := newTestIntrospector(t, srv, &now)
+	ctx := context.Background()
+
+	authenticate := func(token string, wantCalls int32) {
+		t.Helper()
+		_, _ = i.Authenticate(ctx, token)
+		if got := srv.calls.Load(); got != wantCalls {
+			t.Fatalf("after %q: endpoint calls = %d, want %d", token, got, wantCalls)
+		}
+	}
+
+	// Positive results are cached until exp.
+	authenticate("active", 1)
+	authenticate("active", 1)
+	now = now.Add(time.Minute)
+	authenticate("active", 2)
+
+	// Tokens without exp are never cached.
+	authenticate("no-exp", 3)
+	authenticate("no-exp", 4)
+
+	// Inactive results are cached for NegativeCacheTTL.
+	authenticate("revoked", 5)
+	authenticate("revoked", 5)
+	now = now.Add(10 * time.Second)
+	authenticate("revoked", 6)
+}
+
+func TestIntrospector_NegativeCacheDoesNotEvictActive(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	srv := newTestIntrospectionServer(t)
+	srv.set("active", map[string]any{"active": true, "sub": "user-123", "exp": float64(now.Add(time.Hour).Unix())})
+	i := newTestIntrospector(t, srv, &now)
+	i.cache = newLRU[authResult](2)
+	i.negative = newLRU[struct{}](2)
+	ctx := context.Background()
+
+	if _, err := i.Authenticate(ctx, "active"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	for n := range 10 {
+		_, _ = i.Authenticate(ctx, fmt.Sprintf("bogus-%d", n))
+	}
+
+	calls := srv.calls.Load()
+	if _, err := i.Authenticate(ctx, "active"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if got := srv.calls.Load(); got != calls {
+		t.Errorf("endpoint calls = %d, want %d: active token was evicted by inactive ones", got, calls)
+	}
+}
+
+func TestIntrospector_EndpointFailure(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	srv := newTestIntrospectionServer(t)
+	srv.mu.Lock()
+	srv.status = http.StatusInternalServerError
+	srv.mu.Unlock()
+	i := newTestIntrospector(t, srv, &now)
+
+	_, err := i.Authenticate(context.Background(), "opaque")
+	if !errors.Is(err, ErrIntrospection) {
+		t.Fatalf("Authenticate() error = %v, want %v", err, ErrIntrospection)
+	}
+
+	if code := (&interceptor{}).mapToConnectError(err).Code(); code != connect.CodeUnavailable {
+		t.Errorf("code = %v, want %v", code, connect.CodeUnavailable)
+	}
+
+	// Failures are not cached.
+	_, _ = i.Authenticate(context.Background(), "opaque")
+	if got := srv.calls.Load(); got != 2 {
+		t.Errorf("endpoint calls = %d, want 2", got)
+	}
+}
+
+func TestInterceptor_WithIntrospector(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	srv := newTestIntrospectionServer(t)
+	srv.set("opaque", map[string]any{"active": true, "sub": "svc-a", "exp": float64(now.Add(time.Hour).Unix())})
+	i := newTestInterceptor(t, newTestIntrospector(t, srv, &now))
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer opaque")
+
+	ctx, err := i.authenticateProcedure(context.Background(), "/test.Service/Method", http.MethodPost, headers, connect.Peer{})
+	if err != nil {
+		t.Fatalf("authenticate() error = %v", err)
+	}
+	claims, ok := ctxutil.GetClaims(ctx)
+	if !ok || claims.UserID != "svc-a" {
+		t.Errorf("claims = %+v, want UserID %q", claims, "svc-a")
+	}
+}
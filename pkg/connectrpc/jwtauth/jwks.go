@@ -0,0 +1,216 @@
+package jwtauth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/httprc/v3"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// fileURIPrefix marks a StaticJWKS value that names a file instead of inline JSON.
+const fileURIPrefix = "file://"
+
+// jwksObserver wraps the HTTP client used for JWKS fetches and records the
+// outcome, duration, and key count of every fetch, including background
+// refreshes by the jwk.Cache.
+type jwksObserver struct {
+	client    *http.Client
+	refreshes metric.Int64Counter
+	duration  metric.Float64Histogram
+	kidMisses metric.Int64Counter
+
+	mu   sync.Mutex
+	keys map[string]int64
+}
+
+func newJWKSObserver(client *http.Client, mp metric.MeterProvider) (*jwksObserver, error) {
+	meter := mp.Meter(meterName)
+	o := &jwksObserver{
+		client: client,
+		keys:   make(map[string]int64),
+	}
+
+	var err error
+	o.refreshes, err = meter.Int64Counter(
+		"jwtauth.jwks.refreshes",
+		metric.WithDescription("Number of JWKS fetches by result (success, failure)"),
+		metric.WithUnit("{refresh}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create jwks.refreshes metric: %w", err)
+	}
+
+	o.duration, err = meter.Float64Histogram(
+		"jwtauth.jwks.refresh.duration",
+		metric.WithDescription("Duration of JWKS fetches"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create jwks.refresh.duration metric: %w", err)
+	}
+
+	o.kidMisses, err = meter.Int64Counter(
+		"jwtauth.jwks.kid_misses",
+		metric.WithDescription("Number of rejected tokens whose kid was not in the cached JWKS"),
+		metric.WithUnit("{miss}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create jwks.kid_misses metric: %w", err)
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"jwtauth.jwks.keys",
+		metric.WithDescription("Number of keys in the last successfully fetched JWKS"),
+		metric.WithUnit("{key}"),
+		metric.WithInt64Callback(o.observeKeys),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create jwks.keys metric: %w", err)
+	}
+
+	return o, nil
+}
+
+// Do fetches a JWKS and records the outcome. The response is returned
+// unchanged, with its body buffered so the jwk.Cache can still parse it.
+func (o *jwksObserver) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := o.client.Do(req)
+
+	keys, fetchErr := 0, err
+	if err == nil {
+		keys, fetchErr = inspectJWKS(resp)
+	}
+	o.record(req.Context(), req.URL.String(), time.Since(start), keys, fetchErr)
+	return resp, err
+}
+
+// inspectJWKS returns the number of keys in a JWKS response, leaving the body readable.
+func inspectJWKS(resp *http.Response) (int, error) {
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, httprc.MaxBufferSize))
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("read body: %w", err)
+	}
+
+	set, err := jwk.Parse(body)
+	if err != nil {
+		return 0, fmt.Errorf("parse jwks: %w", err)
+	}
+	return set.Len(), nil
+}
+
+// record emits metrics and log events for one JWKS fetch.
+func (o *jwksObserver) record(ctx context.Context, url string, d time.Duration, keys int, err error) {
+	urlAttr := attribute.String("jwks_url", url)
+	o.duration.Record(ctx, d.Seconds(), metric.WithAttributes(urlAttr))
+
+	if err != nil {
+		o.refreshes.Add(ctx, 1, metric.WithAttributes(urlAttr, attribute.String("result", "failure")))
+		slog.WarnContext(ctx, "jwks refresh failed",
+			"jwks_url", url,
+			"error", err,
+		)
+		return
+	}
+
+	o.refreshes.Add(ctx, 1, metric.WithAttributes(urlAttr, attribute.String("result", "success")))
+	o.mu.Lock()
+	prev, seen := o.keys[url]
+	o.keys[url] = int64(keys)
+	o.mu.Unlock()
+
+	if seen && prev != int64(keys) {
+		slog.InfoContext(ctx, "jwks key count changed",
+			"jwks_url", url,
+			"keys", keys,
+			"previous_keys", prev,
+		)
+		return
+	}
+	slog.DebugContext(ctx, "jwks refreshed",
+		"jwks_url", url,
+		"keys", keys,
+	)
+}
+
+// observeKeys reports the key count of every successfully fetched JWKS.
+func (o *jwksObserver) observeKeys(_ context.Context, obs metric.Int64Observer) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for url, n := range o.keys {
+		obs.Observe(n, metric.WithAttributes(attribute.String("jwks_url", url)))
+	}
+	return nil
+}
+
+// kidMiss records a rejected token whose kid was not in the cached JWKS at url.
+func (o *jwksObserver) kidMiss(ctx context.Context, url, kid string) {
+	o.kidMisses.Add(ctx, 1, metric.WithAttributes(attribute.String("jwks_url", url)))
+
+	// Misses are logged at debug level so tokens with made-up key IDs
+	// cannot flood the logs; the metric shows their rate.
+	slog.DebugContext(ctx, "jwks kid miss",
+		"jwks_url", url,
+		"kid", kid,
+	)
+}
+
+// unknownKeyID returns the "kid" header of token if keyset has no key with that ID.
+func unknownKeyID(token string, keyset jwk.Set) (string, bool) {
+	msg, err := jws.Parse([]byte(token))
+	if err != nil || len(msg.Signatures()) != 1 {
+		return "", false
+	}
+	kid, ok := msg.Signatures()[0].ProtectedHeaders().KeyID()
+	if !ok || kid == "" {
+		return "", false
+	}
+	_, found := keyset.LookupKeyID(kid)
+	return kid, !found
+}
+
+// loadStaticJWKS parses a static JWKS given as inline JSON or as a
+// "file://" path. Only public asymmetric keys are accepted.
+func loadStaticJWKS(value string) (jwk.Set, error) {
+	data := []byte(value)
+	if path, ok := strings.CutPrefix(value, fileURIPrefix); ok {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("%w: read file %s: %v", ErrInvalidStaticJWKS, path, err)
+		}
+	}
+
+	set, err := jwk.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStaticJWKS, err)
+	}
+	if set.Len() == 0 {
+		return nil, fmt.Errorf("%w: no keys", ErrInvalidStaticJWKS)
+	}
+	for n := range set.Len() {
+		key, _ := set.Key(n)
+		if private, err := jwk.IsPrivateKey(key); err != nil || private {
+			return nil, fmt.Errorf("%w: key %d is not a public asymmetric key", ErrInvalidStaticJWKS, n)
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,344 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestLoadStaticJWKS(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	public := marshalKeySet(t, pubKey)
+
+	privJWK, err := jwk.Import(privKey)
+	if err != nil {
+		t.Fatalf("failed to create JWK: %v", err)
+	}
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	symJWK, err := jwk.Import(secret)
+	if err != nil {
+		t.Fatalf("failed to create JWK: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(public), 0o600); err != nil {
+		t.Fatalf("failed to write JWKS file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "inline json", value: public},
+		{name: "file uri", value: "file://" + path},
+		{name: "missing file", value: "file://" + filepath.Join(t.TempDir(), "missing.json"), wantErr: true},
+		{name: "invalid json", value: "{", wantErr: true},
+		{name: "empty set", value: `{"keys":[]}`, wantErr: true},
+		{name: "private key", value: marshalKeySet(t, privJWK), wantErr: true},
+		{name: "symmetric key", value: marshalKeySet(t, symJWK), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			set, err := loadStaticJWKS(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidStaticJWKS) {
+					t.Errorf("loadStaticJWKS() error = %v, want %v", err, ErrInvalidStaticJWKS)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadStaticJWKS() error = %v", err)
+			}
+			if _, ok := set.LookupKeyID("test-key-id"); !ok {
+				t.Error("loadStaticJWKS() set is missing key test-key-id")
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator_StaticJWKSFallback(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	idp := newTestIdP(t, pubKey)
+
+	newConfig := func(static string) Config {
+		cfg := DefaultConfig()
+		cfg.JWKSURL = idp.URL + "/unavailable"
+		cfg.Issuer = idp.URL
+		cfg.Audience = "test-audience"
+		cfg.HTTPTimeout = 200 * time.Millisecond
+		cfg.StaticJWKS = static
+		return cfg
+	}
+	ctx := context.Background()
+
+	if _, err := NewAuthenticator(ctx, newConfig("")); !errors.Is(err, ErrJWKSFetch) {
+		t.Fatalf("NewAuthenticator() without static JWKS error = %v, want %v", err, ErrJWKSFetch)
+	}
+
+	auth, err := NewAuthenticator(ctx, newConfig(marshalKeySet(t, pubKey)))
+	if err != nil {
+		t.Fatalf("NewAuthenticator() with static JWKS error = %v", err)
+	}
+
+	claims := map[string]any{
+		"iss": idp.URL,
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if _, err := auth.Authenticate(ctx, signTestToken(t, privKey, claims)); err != nil {
+		t.Fatalf("Authenticate() with static key error = %v", err)
+	}
+
+	// Once the JWKS is reachable and fetched by the cache, the fetched keys
+	// replace the static ones.
+	newPriv, newPub := generateTestKeys(t)
+	if err := newPub.Set(jwk.KeyIDKey, "key-2"); err != nil {
+		t.Fatalf("failed to set key ID: %v", err)
+	}
+	idp.setKeys(t, "/unavailable", newPub)
+	if _, err := auth.cache.Refresh(ctx, idp.URL+"/unavailable"); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	if _, err := auth.Authenticate(ctx, signTestTokenWithKeyID(t, newPriv, "key-2", claims)); err != nil {
+		t.Fatalf("Authenticate() with fetched key error = %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, signTestToken(t, privKey, claims)); err == nil {
+		t.Error("Authenticate() with static key after fetch succeeded, want error")
+	}
+}
+
+func TestNewAuthenticator_StaticJWKSDiscoveryFallback(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	idp := newTestIdP(t, pubKey)
+	idp.setStatus(http.StatusServiceUnavailable)
+
+	cfg := DefaultConfig()
+	cfg.Issuer = idp.URL
+	cfg.Discovery = true
+	cfg.Audience = "test-audience"
+	cfg.StaticJWKS = marshalKeySet(t, pubKey)
+
+	ctx := context.Background()
+	auth, err := NewAuthenticator(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	claims := map[string]any{
+		"iss": idp.URL,
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if _, err := auth.Authenticate(ctx, signTestToken(t, privKey, claims)); err != nil {
+		t.Errorf("Authenticate() error = %v", err)
+	}
+}
+
+func TestNewAuthenticator_InvalidStaticJWKS(t *testing.T) {
+	t.Parallel()
+
+	_, pubKey := generateTestKeys(t)
+	server := setupTestJWKSServer(t, pubKey)
+	t.Cleanup(server.Close)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = server.URL
+	cfg.Issuer = "https://issuer.example.com"
+	cfg.Audience = "test-audience"
+	cfg.StaticJWKS = "not json"
+
+	if _, err := NewAuthenticator(context.Background(), cfg); !errors.Is(err, ErrInvalidStaticJWKS) {
+		t.Errorf("NewAuthenticator() error = %v, want %v", err, ErrInvalidStaticJWKS)
+	}
+}
+
+func TestAuthenticator_JWKSMetrics(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	idp := newTestIdP(t, pubKey)
+
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(ctx) })
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = idp.URL + "/jwks"
+	cfg.Issuer = idp.URL
+	cfg.Audience = "test-audience"
+
+	auth, err := newAuthenticator(ctx, cfg, mp)
+	if err != nil {
+		t.Fatalf("newAuthenticator() error = %v", err)
+	}
+
+	claims := map[string]any{
+		"iss": idp.URL,
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for range 2 {
+		_, _ = auth.Authenticate(ctx, signTestTokenWithKeyID(t, privKey, "unknown", claims))
+	}
+
+	rm := collectMetrics(t, reader)
+	url := attribute.String("jwks_url", cfg.JWKSURL)
+
+	tests := []struct {
+		metric string
+		attrs  []attribute.KeyValue
+		want   int64
+	}{
+		{metric: "jwtauth.jwks.refreshes", attrs: []attribute.KeyValue{url, attribute.String("result", "success")}, want: 1},
+		{metric: "jwtauth.jwks.keys", attrs: []attribute.KeyValue{url}, want: 1},
+		{metric: "jwtauth.jwks.kid_misses", attrs: []attribute.KeyValue{url}, want: 2},
+	}
+	for _, tt := range tests {
+		if got := int64Value(t, rm, tt.metric, attribute.NewSet(tt.attrs...)); got != tt.want {
+			t.Errorf("%s%v = %d, want %d", tt.metric, tt.attrs, got, tt.want)
+		}
+	}
+
+	if !hasMetric(rm, "jwtauth.jwks.refresh.duration") {
+		t.Error("jwtauth.jwks.refresh.duration not recorded")
+	}
+}
+
+func TestJWKSObserver_Failure(t *testing.T) {
+	t.Parallel()
+
+	_, pubKey := generateTestKeys(t)
+	idp := newTestIdP(t, pubKey)
+
+	ctx := context.Background()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(ctx) })
+
+	o, err := newJWKSObserver(http.DefaultClient, mp)
+	if err != nil {
+		t.Fatalf("newJWKSObserver() error = %v", err)
+	}
+
+	for _, path := range []string{"/missing", "/jwks"} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, idp.URL+path, nil)
+		resp, err := o.Do(req)
+		if err != nil {
+			t.Fatalf("Do() error = %v", err)
+		}
+		_ = resp.Body.Close()
+	}
+
+	// The response body stays readable after inspection.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, idp.URL+"/jwks", nil)
+	resp, err := o.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := jwk.ParseReader(resp.Body); err != nil {
+		t.Errorf("ParseReader() error = %v", err)
+	}
+
+	rm := collectMetrics(t, reader)
+	failure := attribute.NewSet(attribute.String("jwks_url", idp.URL+"/missing"), attribute.String("result", "failure"))
+	if got := int64Value(t, rm, "jwtauth.jwks.refreshes", failure); got != 1 {
+		t.Errorf("failed refreshes = %d, want 1", got)
+	}
+	success := attribute.NewSet(attribute.String("jwks_url", idp.URL+"/jwks"), attribute.String("result", "success"))
+	if got := int64Value(t, rm, "jwtauth.jwks.refreshes", success); got != 2 {
+		t.Errorf("successful refreshes = %d, want 2", got)
+	}
+}
+
+func marshalKeySet(t *testing.T, keys ...jwk.Key) string {
+	t.Helper()
+
+	set := jwk.NewSet()
+	for _, key := range keys {
+		if err := set.AddKey(key); err != nil {
+			t.Fatalf("failed to add key to set: %v", err)
+		}
+	}
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("failed to marshal JWKS: %v", err)
+	}
+	return string(data)
+}
+
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) metricdata.ResourceMetrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	return rm
+}
+
+func hasMetric(rm metricdata.ResourceMetrics, name string) bool {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// int64Value returns the value of the int64 sum or gauge data point of name with attrs.
+func int64Value(t *testing.T, rm metricdata.ResourceMetrics, name string, attrs attribute.Set) int64 {
+	t.Helper()
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			var points []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				points = data.DataPoints
+			case metricdata.Gauge[int64]:
+				points = data.DataPoints
+			default:
+				t.Fatalf("metric %s data = %T, want int64 sum or gauge", name, m.Data)
+			}
+			for _, dp := range points {
+				if dp.Attributes.Equals(&attrs) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
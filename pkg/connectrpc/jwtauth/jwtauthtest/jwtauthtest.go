@@ -0,0 +1,319 @@
+// Package jwtauthtest provides a local token issuer for testing code that uses jwtauth.
+//
+// An Issuer serves a JWKS endpoint on a loopback httptest.Server, mints signed
+// tokens with arbitrary claims, and supports key rotation mid-test:
+//
+//	iss := jwtauthtest.NewIssuer(t)
+//	auth := iss.NewAuthenticator(t)
+//	token := iss.Token(map[string]any{"sub": "user-123"})
+//
+// An Authenticator fetches the JWKS when it is created, so create a new one
+// after Rotate, AddKey or Retire to validate against the changed key set.
+package jwtauthtest
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwa"
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"github.com/lestrrat-go/jwx/v3/jwt"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/jwtauth"
+)
+
+// DefaultAudience is the "aud" claim of minted tokens unless overridden.
+const DefaultAudience = "test-audience"
+
+// KeyType selects the signing key algorithm.
+type KeyType string
+
+const (
+	// RSA generates a 2048-bit RSA key signing with RS256.
+	RSA KeyType = "RSA"
+
+	// EC generates a P-256 key signing with ES256.
+	EC KeyType = "EC"
+
+	// EdDSA generates an Ed25519 key signing with EdDSA.
+	EdDSA KeyType = "EdDSA"
+)
+
+// Key is a signing key held by an Issuer.
+type Key struct {
+	// ID is the key's "kid".
+	ID string
+
+	// Type is the key algorithm family.
+	Type KeyType
+
+	alg     jwa.SignatureAlgorithm
+	raw     crypto.Signer
+	private jwk.Key
+	public  jwk.Key
+}
+
+// Issuer is a local token issuer with a JWKS endpoint.
+type Issuer struct {
+	tb       testing.TB
+	server   *httptest.Server
+	audience string
+
+	mu      sync.Mutex
+	keys    []*Key
+	signing *Key
+}
+
+// Option configures an Issuer.
+type Option func(*Issuer)
+
+// WithAudience sets the default "aud" claim and the audience in Config.
+func WithAudience(audience string) Option {
+	return func(i *Issuer) {
+		i.audience = audience
+	}
+}
+
+// NewIssuer starts a JWKS endpoint with one RSA signing key.
+// The server is closed when the test finishes.
+func NewIssuer(tb testing.TB, opts ...Option) *Issuer {
+	tb.Helper()
+
+	i := &Issuer{tb: tb, audience: DefaultAudience}
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	i.server = httptest.NewServer(http.HandlerFunc(i.serveJWKS))
+	tb.Cleanup(i.server.Close)
+
+	i.Rotate(RSA)
+	return i
+}
+
+// URL returns the issuer URL, used as the "iss" claim.
+func (i *Issuer) URL() string {
+	return i.server.URL
+}
+
+// JWKSURL returns the URL of the JWKS endpoint.
+func (i *Issuer) JWKSURL() string {
+	return i.server.URL + "/jwks"
+}
+
+// Config returns a jwtauth.Config pointed at this issuer.
+func (i *Issuer) Config() jwtauth.Config {
+	cfg := jwtauth.DefaultConfig()
+	cfg.JWKSURL = i.JWKSURL()
+	cfg.Issuer = i.URL()
+	cfg.Audience = i.audience
+	return cfg
+}
+
+// NewAuthenticator creates a jwtauth.Authenticator from Config and opts,
+// using the keys currently published in the JWKS.
+// Its background refresh stops when the test finishes.
+func (i *Issuer) NewAuthenticator(tb testing.TB, opts ...jwtauth.AuthenticatorOption) *jwtauth.Authenticator {
+	tb.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.Cleanup(cancel)
+
+	auth, err := jwtauth.NewAuthenticator(ctx, i.Config(), opts...)
+	if err != nil {
+		tb.Fatalf("jwtauthtest: create authenticator: %v", err)
+	}
+	return auth
+}
+
+// AddKey generates a key of type kt and publishes it in the JWKS without
+// using it for signing.
+func (i *Issuer) AddKey(kt KeyType) *Key {
+	i.tb.Helper()
+
+	k := i.generateKey(kt)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = append(i.keys, k)
+	return k
+}
+
+// Rotate generates a key of type kt, publishes it, and makes it the signing key.
+// Previous keys stay published until removed with Retire.
+func (i *Issuer) Rotate(kt KeyType) *Key {
+	i.tb.Helper()
+
+	k := i.AddKey(kt)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.signing = k
+	return k
+}
+
+// Retire removes the key with the given ID from the JWKS.
+// The key can still sign tokens, which then fail verification.
+func (i *Issuer) Retire(kid string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = slices.DeleteFunc(i.keys, func(k *Key) bool { return k.ID == kid })
+}
+
+// SigningKey returns the current signing key.
+func (i *Issuer) SigningKey() *Key {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.signing
+}
+
+// TokenOption configures a minted token.
+type TokenOption func(*tokenOptions)
+
+type tokenOptions struct {
+	expiresIn time.Duration
+	key       *Key
+	keyID     *string
+}
+
+// ExpiresIn sets "exp" relative to now. Negative values mint expired tokens.
+// Default: 1 hour.
+func ExpiresIn(d time.Duration) TokenOption {
+	return func(o *tokenOptions) {
+		o.expiresIn = d
+	}
+}
+
+// SignedWith signs the token with k instead of the current signing key.
+func SignedWith(k *Key) TokenOption {
+	return func(o *tokenOptions) {
+		o.key = k
+	}
+}
+
+// WithKeyID overrides the "kid" header. An empty string omits it.
+func WithKeyID(kid string) TokenOption {
+	return func(o *tokenOptions) {
+		o.keyID = &kid
+	}
+}
+
+// Token mints a signed token. "iss", "aud", "iat", and "exp" are set by default;
+// entries in claims override them. Nested maps become nested JSON objects.
+func (i *Issuer) Token(claims map[string]any, opts ...TokenOption) string {
+	i.tb.Helper()
+
+	o := tokenOptions{expiresIn: time.Hour, key: i.SigningKey()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	now := time.Now()
+	all := map[string]any{
+		jwt.IssuerKey:     i.URL(),
+		jwt.AudienceKey:   []string{i.audience},
+		jwt.IssuedAtKey:   now.Unix(),
+		jwt.ExpirationKey: now.Add(o.expiresIn).Unix(),
+	}
+	maps.Copy(all, claims)
+
+	tok := jwt.New()
+	for name, v := range all {
+		if err := tok.Set(name, v); err != nil {
+			i.tb.Fatalf("jwtauthtest: set claim %s: %v", name, err)
+		}
+	}
+
+	// The signing JWK's "kid" becomes the token header, so overriding it
+	// needs a fresh JWK for the same raw key.
+	private := o.key.private
+	if o.keyID != nil {
+		var err error
+		if private, err = jwk.Import(o.key.raw); err != nil {
+			i.tb.Fatalf("jwtauthtest: import private key: %v", err)
+		}
+		if *o.keyID != "" {
+			if err := private.Set(jwk.KeyIDKey, *o.keyID); err != nil {
+				i.tb.Fatalf("jwtauthtest: set kid: %v", err)
+			}
+		}
+	}
+
+	signed, err := jwt.Sign(tok, jwt.WithKey(o.key.alg, private))
+	if err != nil {
+		i.tb.Fatalf("jwtauthtest: sign token: %v", err)
+	}
+	return string(signed)
+}
+
+func (i *Issuer) serveJWKS(w http.ResponseWriter, _ *http.Request) {
+	i.mu.Lock()
+	set := jwk.NewSet()
+	for _, k := range i.keys {
+		_ = set.AddKey(k.public)
+	}
+	i.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(set)
+}
+
+func (i *Issuer) generateKey(kt KeyType) *Key {
+	i.tb.Helper()
+
+	var (
+		raw crypto.Signer
+		alg jwa.SignatureAlgorithm
+		err error
+	)
+	switch kt {
+	case RSA:
+		raw, err = rsa.GenerateKey(rand.Reader, 2048)
+		alg = jwa.RS256()
+	case EC:
+		raw, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		alg = jwa.ES256()
+	case EdDSA:
+		_, raw, err = ed25519.GenerateKey(rand.Reader)
+		alg = jwa.EdDSA()
+	default:
+		i.tb.Fatalf("jwtauthtest: unsupported key type %q", kt)
+	}
+	if err != nil {
+		i.tb.Fatalf("jwtauthtest: generate %s key: %v", kt, err)
+	}
+
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	k := &Key{ID: hex.EncodeToString(id), Type: kt, alg: alg, raw: raw}
+
+	if k.private, err = jwk.Import(raw); err != nil {
+		i.tb.Fatalf("jwtauthtest: import private key: %v", err)
+	}
+	if k.public, err = jwk.Import(raw.Public()); err != nil {
+		i.tb.Fatalf("jwtauthtest: import public key: %v", err)
+	}
+	for _, key := range []jwk.Key{k.private, k.public} {
+		if err := key.Set(jwk.KeyIDKey, k.ID); err != nil {
+			i.tb.Fatalf("jwtauthtest: set kid: %v", err)
+		}
+		if err := key.Set(jwk.AlgorithmKey, alg); err != nil {
+			i.tb.Fatalf("jwtauthtest: set alg: %v", err)
+		}
+	}
+	return k
+}
@@ -0,0 +1,195 @@
+package jwtauthtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/lestrrat-go/jwx/v3/jws"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/jwtauth"
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestIssuer_KeyTypes(t *testing.T) {
+	t.Parallel()
+
+	for _, kt := range []KeyType{RSA, EC, EdDSA} {
+		t.Run(string(kt), func(t *testing.T) {
+			t.Parallel()
+
+			iss := NewIssuer(t)
+			iss.Rotate(kt)
+			auth := iss.NewAuthenticator(t)
+
+			claims, err := auth.Authenticate(context.Background(), iss.Token(map[string]any{
+				"sub":   "user-123",
+				"roles": []string{"admin"},
+			}))
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if claims.UserID != "user-123" {
+				t.Errorf("UserID = %q, want %q", claims.UserID, "user-123")
+			}
+		})
+	}
+}
+
+func TestIssuer_Token(t *testing.T) {
+	t.Parallel()
+
+	iss := NewIssuer(t, WithAudience("my-api"))
+	auth := iss.NewAuthenticator(t)
+	unpublished := NewIssuer(t).SigningKey()
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		opts    []TokenOption
+		wantErr error
+	}{
+		{
+			name:   "defaults",
+			claims: map[string]any{"sub": "user-123"},
+		},
+		{
+			name:    "expired",
+			claims:  map[string]any{"sub": "user-123"},
+			opts:    []TokenOption{ExpiresIn(-2 * time.Minute)},
+			wantErr: jwtauth.ErrTokenExpired,
+		},
+		{
+			name:    "overridden audience",
+			claims:  map[string]any{"sub": "user-123", "aud": "other-api"},
+			wantErr: jwtauth.ErrInvalidAudience,
+		},
+		{
+			name:    "overridden issuer",
+			claims:  map[string]any{"sub": "user-123", "iss": "https://evil.example.com"},
+			wantErr: jwtauth.ErrInvalidIssuer,
+		},
+		{
+			name:   "unpublished key",
+			claims: map[string]any{"sub": "user-123"},
+			opts:   []TokenOption{SignedWith(unpublished)},
+		},
+		{
+			name:   "unknown kid",
+			claims: map[string]any{"sub": "user-123"},
+			opts:   []TokenOption{WithKeyID("unknown")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := auth.Authenticate(context.Background(), iss.Token(tt.claims, tt.opts...))
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Errorf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			case tt.wantErr == nil && len(tt.opts) == 0 && err != nil:
+				t.Errorf("Authenticate() error = %v", err)
+			case tt.wantErr == nil && len(tt.opts) > 0 && err == nil:
+				t.Error("Authenticate() succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestIssuer_KeyIDHeader(t *testing.T) {
+	t.Parallel()
+
+	iss := NewIssuer(t)
+
+	tests := []struct {
+		name string
+		opts []TokenOption
+		want string
+	}{
+		{name: "signing key id", want: iss.SigningKey().ID},
+		{name: "custom kid", opts: []TokenOption{WithKeyID("custom")}, want: "custom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			msg, err := jws.Parse([]byte(iss.Token(nil, tt.opts...)))
+			if err != nil {
+				t.Fatalf("jws.Parse() error = %v", err)
+			}
+			kid, _ := msg.Signatures()[0].ProtectedHeaders().KeyID()
+			if kid != tt.want {
+				t.Errorf("kid = %q, want %q", kid, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssuer_RotationMidTest(t *testing.T) {
+	t.Parallel()
+
+	iss := NewIssuer(t)
+	ctx := context.Background()
+
+	oldKey := iss.SigningKey()
+	oldToken := iss.Token(map[string]any{"sub": "user-123"})
+	if _, err := iss.NewAuthenticator(t).Authenticate(ctx, oldToken); err != nil {
+		t.Fatalf("Authenticate() before rotation error = %v", err)
+	}
+
+	// Both keys are published during the overlap.
+	iss.Rotate(EC)
+	auth := iss.NewAuthenticator(t)
+	if _, err := auth.Authenticate(ctx, iss.Token(map[string]any{"sub": "user-123"})); err != nil {
+		t.Fatalf("Authenticate() with rotated key error = %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, oldToken); err != nil {
+		t.Errorf("Authenticate() with previous key during overlap error = %v", err)
+	}
+
+	// Once retired, the previous key is no longer accepted.
+	iss.Retire(oldKey.ID)
+	iss.Rotate(EdDSA)
+	auth = iss.NewAuthenticator(t)
+	if _, err := auth.Authenticate(ctx, iss.Token(map[string]any{"sub": "user-123"})); err != nil {
+		t.Fatalf("Authenticate() with second rotated key error = %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, oldToken); err == nil {
+		t.Error("Authenticate() with retired key succeeded, want error")
+	}
+}
+
+func TestIssuer_CustomClaims(t *testing.T) {
+	t.Parallel()
+
+	type tenantClaims struct {
+		Region string `claim:"tenant.region,required"`
+	}
+
+	iss := NewIssuer(t)
+	auth := iss.NewAuthenticator(t, jwtauth.WithCustomClaims[tenantClaims]())
+
+	req := connect.NewRequest(&emptypb.Empty{})
+	req.Header().Set("Authorization", "Bearer "+iss.Token(map[string]any{
+		"sub":    "user-123",
+		"tenant": map[string]any{"region": "eu-west-1"},
+	}))
+
+	var custom tenantClaims
+	handler := jwtauth.NewInterceptor(auth).WrapUnary(func(ctx context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		custom, _ = ctxutil.CustomClaims[tenantClaims](ctx)
+		return connect.NewResponse(&emptypb.Empty{}), nil
+	})
+	if _, err := handler(context.Background(), req); err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+	if custom.Region != "eu-west-1" {
+		t.Errorf("custom claims = %+v, want Region eu-west-1", custom)
+	}
+}
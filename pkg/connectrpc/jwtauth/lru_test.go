@@ -0,0 +1,35 @@
+package jwtauth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	c := newLRU[int](2)
+
+	c.add("a", 1, now.Add(time.Minute))
+	c.add("b", 2, now.Add(time.Minute))
+
+	// Touch "a" so "b" becomes least recently used.
+	if v, ok := c.get("a", now); !ok || v != 1 {
+		t.Fatalf("get(a) = %d, %v, want 1, true", v, ok)
+	}
+	c.add("c", 3, now.Add(time.Second))
+
+	if _, ok := c.get("b", now); ok {
+		t.Error("get(b) found evicted entry")
+	}
+	if v, ok := c.get("c", now); !ok || v != 3 {
+		t.Errorf("get(c) = %d, %v, want 3, true", v, ok)
+	}
+	if _, ok := c.get("c", now.Add(time.Second)); ok {
+		t.Error("get(c) found expired entry")
+	}
+	if v, ok := c.get("a", now.Add(time.Second)); !ok || v != 1 {
+		t.Errorf("get(a) = %d, %v, want 1, true", v, ok)
+	}
+}
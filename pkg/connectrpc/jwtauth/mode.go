@@ -0,0 +1,115 @@
+package jwtauth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mode controls how the interceptor authenticates a procedure.
+type Mode string
+
+const (
+	// ModeRequired rejects requests without a valid bearer token.
+	ModeRequired Mode = "required"
+
+	// ModeOptional validates the bearer token if present and attaches claims.
+	// Requests without a token continue anonymously (no claims in context).
+	// Requests with an invalid token are still rejected.
+	ModeOptional Mode = "optional"
+
+	// ModeSkip performs no authentication at all.
+	ModeSkip Mode = "skip"
+)
+
+// ProcedureMode assigns an authentication mode to a procedure or service.
+type ProcedureMode struct {
+	// Procedure is either an exact procedure name ("/pkg.Service/Method")
+	// or a service prefix ending in "/" ("/pkg.Service/").
+	// Exact names take precedence over prefixes; longer prefixes win over shorter ones.
+	Procedure string `koanf:"procedure"`
+
+	// Mode is the authentication mode for matching procedures.
+	Mode Mode `koanf:"mode"`
+}
+
+// InterceptorConfig holds per-procedure settings for the JWT interceptor.
+type InterceptorConfig struct {
+	// DefaultMode applies to procedures without a matching entry.
+	// Default: ModeRequired
+	DefaultMode Mode `koanf:"default_mode"`
+
+	// Procedures overrides the mode for specific procedures or services.
+	Procedures []ProcedureMode `koanf:"procedures"`
+}
+
+// DefaultInterceptorConfig returns an InterceptorConfig that requires
+// authentication for every procedure.
+func DefaultInterceptorConfig() InterceptorConfig {
+	return InterceptorConfig{
+		DefaultMode: ModeRequired,
+	}
+}
+
+// Validate checks that all modes are known and procedures are set.
+// Returns nil if configuration is valid.
+func (c InterceptorConfig) Validate() error {
+	if c.DefaultMode != "" && !c.DefaultMode.valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidMode, c.DefaultMode)
+	}
+	for _, p := range c.Procedures {
+		if p.Procedure == "" {
+			return ErrProcedureRequired
+		}
+		if !p.Mode.valid() {
+			return fmt.Errorf("%w: %q for %s", ErrInvalidMode, p.Mode, p.Procedure)
+		}
+	}
+	return nil
+}
+
+func (m Mode) valid() bool {
+	switch m {
+	case ModeRequired, ModeOptional, ModeSkip:
+		return true
+	default:
+		return false
+	}
+}
+
+// modeResolver resolves the authentication mode for a procedure.
+type modeResolver struct {
+	defaultMode Mode
+	exact       map[string]Mode
+	prefixes    []ProcedureMode
+}
+
+func newModeResolver(cfg InterceptorConfig) modeResolver {
+	r := modeResolver{
+		defaultMode: cfg.DefaultMode,
+		exact:       make(map[string]Mode),
+	}
+	if r.defaultMode == "" {
+		r.defaultMode = ModeRequired
+	}
+	for _, p := range cfg.Procedures {
+		if strings.HasSuffix(p.Procedure, "/") {
+			r.prefixes = append(r.prefixes, p)
+			continue
+		}
+		r.exact[p.Procedure] = p.Mode
+	}
+	return r
+}
+
+func (r modeResolver) modeFor(procedure string) Mode {
+	if mode, ok := r.exact[procedure]; ok {
+		return mode
+	}
+	mode, longest := r.defaultMode, 0
+	for _, p := range r.prefixes {
+		if len(p.Procedure) > longest && strings.HasPrefix(procedure, p.Procedure) {
+			mode, longest = p.Mode, len(p.Procedure)
+		}
+	}
+	return mode
+}
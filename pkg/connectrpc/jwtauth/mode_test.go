@@ -0,0 +1,198 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestInterceptorConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     InterceptorConfig
+		wantErr error
+	}{
+		{
+			name:    "default config",
+			cfg:     DefaultInterceptorConfig(),
+			wantErr: nil,
+		},
+		{
+			name:    "empty config",
+			cfg:     InterceptorConfig{},
+			wantErr: nil,
+		},
+		{
+			name: "valid procedures",
+			cfg: InterceptorConfig{
+				DefaultMode: ModeOptional,
+				Procedures: []ProcedureMode{
+					{Procedure: "/pkg.Service/", Mode: ModeSkip},
+					{Procedure: "/pkg.Service/Login", Mode: ModeRequired},
+				},
+			},
+			wantErr: nil,
+		},
+		{
+			name:    "invalid default mode",
+			cfg:     InterceptorConfig{DefaultMode: "public"},
+			wantErr: ErrInvalidMode,
+		},
+		{
+			name:    "invalid procedure mode",
+			cfg:     InterceptorConfig{Procedures: []ProcedureMode{{Procedure: "/pkg.Service/A", Mode: ""}}},
+			wantErr: ErrInvalidMode,
+		},
+		{
+			name:    "missing procedure",
+			cfg:     InterceptorConfig{Procedures: []ProcedureMode{{Mode: ModeSkip}}},
+			wantErr: ErrProcedureRequired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.cfg.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestModeResolver_ModeFor(t *testing.T) {
+	t.Parallel()
+
+	r := newModeResolver(InterceptorConfig{
+		Procedures: []ProcedureMode{
+			{Procedure: "/pkg.Public/", Mode: ModeSkip},
+			{Procedure: "/pkg.Public/Secret", Mode: ModeRequired},
+			{Procedure: "/pkg.Mixed/", Mode: ModeOptional},
+			{Procedure: "/pkg.Mixed.Admin/", Mode: ModeRequired},
+			{Procedure: "/pkg.Auth/Login", Mode: ModeSkip},
+		},
+	})
+
+	tests := []struct {
+		procedure string
+		want      Mode
+	}{
+		{procedure: "/pkg.Public/Status", want: ModeSkip},
+		{procedure: "/pkg.Public/Secret", want: ModeRequired},
+		{procedure: "/pkg.Mixed/List", want: ModeOptional},
+		{procedure: "/pkg.Mixed.Admin/Delete", want: ModeRequired},
+		{procedure: "/pkg.Auth/Login", want: ModeSkip},
+		{procedure: "/pkg.Auth/Logout", want: ModeRequired},
+		{procedure: "/pkg.Other/Method", want: ModeRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.procedure, func(t *testing.T) {
+			t.Parallel()
+			if got := r.modeFor(tt.procedure); got != tt.want {
+				t.Errorf("modeFor(%q) = %q, want %q", tt.procedure, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewValidatedInterceptor_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewValidatedInterceptor(nil, WithInterceptorConfig(InterceptorConfig{DefaultMode: "nope"}))
+	if !errors.Is(err, ErrInvalidMode) {
+		t.Errorf("NewValidatedInterceptor() error = %v, want %v", err, ErrInvalidMode)
+	}
+}
+
+func TestNewInterceptor_InvalidConfigPanics(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("NewInterceptor() should panic for an invalid config")
+		}
+	}()
+	NewInterceptor(nil, WithInterceptorConfig(InterceptorConfig{DefaultMode: "nope"}))
+}
+
+func TestInterceptor_AuthenticateProcedure(t *testing.T) {
+	t.Parallel()
+
+	// The authenticator is never reached in these cases, so nil is fine.
+	i := newTestInterceptor(t, nil, WithInterceptorConfig(InterceptorConfig{
+		Procedures: []ProcedureMode{
+			{Procedure: "/pkg.Service/Status", Mode: ModeSkip},
+			{Procedure: "/pkg.Service/List", Mode: ModeOptional},
+		},
+	}))
+
+	tests := []struct {
+		name       string
+		procedure  string
+		authHeader string
+		wantErr    error
+	}{
+		{
+			name:       "skip ignores invalid header",
+			procedure:  "/pkg.Service/Status",
+			authHeader: "Basic abc",
+		},
+		{
+			name:      "optional without token continues anonymously",
+			procedure: "/pkg.Service/List",
+		},
+		{
+			name:       "optional with other scheme continues anonymously",
+			procedure:  "/pkg.Service/List",
+			authHeader: "Basic abc",
+		},
+		{
+			name:      "required without token is rejected",
+			procedure: "/pkg.Service/Delete",
+			wantErr:   ErrMissingToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			headers := http.Header{}
+			if tt.authHeader != "" {
+				headers.Set("Authorization", tt.authHeader)
+			}
+
+			ctx, err := i.authenticateProcedure(context.Background(), tt.procedure, http.MethodPost, headers, connect.Peer{})
+
+			if tt.wantErr != nil {
+				var connectErr *connect.Error
+				if !errors.As(err, &connectErr) {
+					t.Fatalf("expected connect.Error, got %T", err)
+				}
+				if connectErr.Code() != connect.CodeUnauthenticated {
+					t.Errorf("code = %v, want %v", connectErr.Code(), connect.CodeUnauthenticated)
+				}
+				if !errors.Is(connectErr.Unwrap(), tt.wantErr) {
+					t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("authenticateProcedure() error = %v", err)
+			}
+			if _, ok := ctxutil.GetClaims(ctx); ok {
+				t.Error("expected no claims in context")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package jwtauth
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// replayPruneInterval is how often InMemoryReplayStore drops expired entries.
+const replayPruneInterval = time.Minute
+
+// InMemoryReplayStore implements ReplayStore with a map.
+// It only detects replays against the same process; use a shared store when
+// several instances accept the same tokens.
+type InMemoryReplayStore struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewInMemoryReplayStore creates an empty replay store.
+func NewInMemoryReplayStore() *InMemoryReplayStore {
+	return &InMemoryReplayStore{
+		entries: make(map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Seen records key until expiresAt and reports whether it was already recorded.
+func (s *InMemoryReplayStore) Seen(_ context.Context, key string, expiresAt time.Time) (bool, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastPrune) >= replayPruneInterval {
+		maps.DeleteFunc(s.entries, func(_ string, exp time.Time) bool {
+			return !now.Before(exp)
+		})
+		s.lastPrune = now
+	}
+
+	if exp, ok := s.entries[key]; ok && now.Before(exp) {
+		return true, nil
+	}
+	s.entries[key] = expiresAt
+	return false, nil
+}
+
+// compile-time check
+var _ ReplayStore = (*InMemoryReplayStore)(nil)
@@ -0,0 +1,97 @@
+package jwtauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/deepworx/go-utils/pkg/tracing"
+)
+
+// TokenIdentity identifies an authenticated token for revocation checks and DPoP binding.
+type TokenIdentity struct {
+	// Issuer is the "iss" claim.
+	Issuer string
+
+	// ID is the "jti" claim.
+	ID string
+
+	// Subject is the "sub" claim.
+	Subject string
+
+	// SessionID is the "sid" claim.
+	SessionID string
+
+	// IssuedAt is the "iat" claim. Zero if the token has none.
+	IssuedAt time.Time
+
+	// Thumbprint is the "cnf.jkt" claim of DPoP-bound tokens (RFC 9449).
+	Thumbprint string
+}
+
+// RevocationChecker reports whether a signature-valid, unexpired token has been revoked.
+// Implementations must be safe for concurrent use.
+type RevocationChecker interface {
+	// IsRevoked reports whether the token was revoked by its ID, its session,
+	// or a revocation of all tokens of its subject issued before a cutoff.
+	// Revocations only apply to tokens of the issuer they were made for.
+	IsRevoked(ctx context.Context, tok TokenIdentity) (bool, error)
+}
+
+// WithRevocationChecker rejects revoked tokens with ErrTokenRevoked.
+// Checker failures reject the token with ErrRevocationCheck.
+func WithRevocationChecker(checker RevocationChecker) AuthenticatorOption {
+	return func(o *authenticatorOptions) {
+		o.revocation = checker
+	}
+}
+
+// tokenIdentity reads the revocation-relevant claims with lookup.
+func tokenIdentity(lookup func(path string) (any, bool)) TokenIdentity {
+	str := func(name string) string {
+		v, _ := lookup(name)
+		s, _ := v.(string)
+		return s
+	}
+
+	tok := TokenIdentity{
+		Issuer:     str("iss"),
+		ID:         str("jti"),
+		Subject:    str("sub"),
+		SessionID:  str("sid"),
+		Thumbprint: str("cnf.jkt"),
+	}
+	if v, ok := lookup("iat"); ok {
+		switch iat := v.(type) {
+		case time.Time:
+			tok.IssuedAt = iat
+		case float64:
+			tok.IssuedAt = time.Unix(int64(iat), 0)
+		case json.Number:
+			if n, err := iat.Int64(); err == nil {
+				tok.IssuedAt = time.Unix(n, 0)
+			}
+		}
+	}
+	return tok
+}
+
+// checkRevocation returns ErrTokenRevoked if checker reports tok as revoked.
+// A nil checker accepts every token.
+func checkRevocation(ctx context.Context, checker RevocationChecker, tok TokenIdentity) error {
+	if checker == nil {
+		return nil
+	}
+
+	revoked, err := tracing.WithSpanResult(ctx, "jwtauth.check_revocation", func(ctx context.Context) (bool, error) {
+		return checker.IsRevoked(ctx, tok)
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRevocationCheck, err)
+	}
+	if revoked {
+		return fmt.Errorf("validate token: %w", ErrTokenRevoked)
+	}
+	return nil
+}
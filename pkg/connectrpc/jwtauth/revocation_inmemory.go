@@ -0,0 +1,107 @@
+package jwtauth
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"time"
+)
+
+// InMemoryRevocationList implements RevocationChecker with maps.
+// Intended for tests and single-instance services; revocations are lost on restart.
+type InMemoryRevocationList struct {
+	mu       sync.RWMutex
+	tokens   map[revocationKey]time.Time
+	sessions map[revocationKey]time.Time
+	subjects map[revocationKey]time.Time
+	now      func() time.Time
+}
+
+// revocationKey scopes a revoked "jti", "sid" or "sub" to the issuer that
+// issued it, since identifiers of different issuers may collide.
+type revocationKey struct {
+	issuer string
+	value  string
+}
+
+// NewInMemoryRevocationList creates an empty revocation list.
+func NewInMemoryRevocationList() *InMemoryRevocationList {
+	return &InMemoryRevocationList{
+		tokens:   make(map[revocationKey]time.Time),
+		sessions: make(map[revocationKey]time.Time),
+		subjects: make(map[revocationKey]time.Time),
+		now:      time.Now,
+	}
+}
+
+// RevokeToken revokes the token of issuer iss with the given "jti" until
+// expiresAt, normally the token's own expiry. A zero expiresAt never expires.
+func (l *InMemoryRevocationList) RevokeToken(_ context.Context, iss, jti string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune()
+	l.tokens[revocationKey{issuer: iss, value: jti}] = expiresAt
+	return nil
+}
+
+// RevokeSession revokes all tokens of issuer iss with the given "sid" until
+// expiresAt. A zero expiresAt never expires.
+func (l *InMemoryRevocationList) RevokeSession(_ context.Context, iss, sid string, expiresAt time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune()
+	l.sessions[revocationKey{issuer: iss, value: sid}] = expiresAt
+	return nil
+}
+
+// RevokeSubject revokes all tokens of issuer iss for the subject issued before
+// issuedBefore. Tokens issued afterwards, e.g. after the user re-authenticates,
+// stay valid.
+func (l *InMemoryRevocationList) RevokeSubject(_ context.Context, iss, sub string, issuedBefore time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := revocationKey{issuer: iss, value: sub}
+	if prev, ok := l.subjects[key]; !ok || issuedBefore.After(prev) {
+		l.subjects[key] = issuedBefore
+	}
+	return nil
+}
+
+// IsRevoked reports whether tok was revoked by its issuer.
+func (l *InMemoryRevocationList) IsRevoked(_ context.Context, tok TokenIdentity) (bool, error) {
+	now := l.now()
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if tok.ID != "" && active(l.tokens, revocationKey{issuer: tok.Issuer, value: tok.ID}, now) {
+		return true, nil
+	}
+	if tok.SessionID != "" && active(l.sessions, revocationKey{issuer: tok.Issuer, value: tok.SessionID}, now) {
+		return true, nil
+	}
+	cutoff, ok := l.subjects[revocationKey{issuer: tok.Issuer, value: tok.Subject}]
+	if ok && tok.Subject != "" && tok.IssuedAt.Before(cutoff) {
+		return true, nil
+	}
+	return false, nil
+}
+
+// prune drops expired token and session entries. Callers must hold l.mu.
+func (l *InMemoryRevocationList) prune() {
+	now := l.now()
+	for _, entries := range []map[revocationKey]time.Time{l.tokens, l.sessions} {
+		maps.DeleteFunc(entries, func(_ revocationKey, expiresAt time.Time) bool {
+			return !expiresAt.IsZero() && !now.Before(expiresAt)
+		})
+	}
+}
+
+// active reports whether entries holds key with an expiry that has not passed.
+func active(entries map[revocationKey]time.Time, key revocationKey, now time.Time) bool {
+	expiresAt, ok := entries[key]
+	return ok && (expiresAt.IsZero() || now.Before(expiresAt))
+}
+
+// compile-time check
+var _ RevocationChecker = (*InMemoryRevocationList)(nil)
@@ -0,0 +1,328 @@
+package jwtauth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/deepworx/go-utils/pkg/postgres"
+)
+
+// DefaultRevocationTable is the table used by PgRevocationList when none is configured.
+const DefaultRevocationTable = "token_revocations"
+
+// Revocation kinds stored in the kind column.
+const (
+	revokedToken   = "jti"
+	revokedSession = "sid"
+	revokedSubject = "sub"
+)
+
+// RevocationConfig holds configuration for PgRevocationList.
+type RevocationConfig struct {
+	// Table is the revocation table name, optionally schema-qualified ("schema.table").
+	// Default: DefaultRevocationTable.
+	Table string `koanf:"table"`
+
+	// RefreshInterval is how often revocations are reloaded into the Bloom filter.
+	// Revocations made by other instances take effect after at most this long.
+	// Default: 30s.
+	RefreshInterval time.Duration `koanf:"refresh_interval"`
+
+	// FalsePositiveRate is the target Bloom filter false positive rate.
+	// Each false positive costs one database round-trip.
+	// Default: 0.001.
+	FalsePositiveRate float64 `koanf:"false_positive_rate"`
+}
+
+// DefaultRevocationConfig returns a RevocationConfig with sensible default values.
+func DefaultRevocationConfig() RevocationConfig {
+	return RevocationConfig{
+		Table:             DefaultRevocationTable,
+		RefreshInterval:   30 * time.Second,
+		FalsePositiveRate: 0.001,
+	}
+}
+
+// Validate checks that all fields are within range.
+// Returns nil if configuration is valid.
+func (c RevocationConfig) Validate() error {
+	if c.FalsePositiveRate < 0 || c.FalsePositiveRate >= 1 {
+		return ErrInvalidFalsePositiveRate
+	}
+	return nil
+}
+
+// PgRevocationList implements RevocationChecker using a PostgreSQL table.
+//
+// All active revocations are loaded periodically into a Bloom filter. Tokens
+// that miss the filter, which is almost all of them, are accepted without a
+// database round-trip; hits are confirmed with a query.
+type PgRevocationList struct {
+	pool     *pgxpool.Pool
+	table    string
+	interval time.Duration
+	fpRate   float64
+
+	// filter is nil until the first successful refresh; until then every
+	// check queries the database.
+	filter atomic.Pointer[bloomFilter]
+
+	// refreshMu serializes refreshes.
+	refreshMu sync.Mutex
+
+	// mu guards adding local revocations to filter against swapping it.
+	// While a refresh is loading, local revocations are also kept in
+	// pending, since the loaded rows may predate them.
+	mu         sync.Mutex
+	refreshing bool
+	pending    []string
+}
+
+// NewPgRevocationList creates a revocation list backed by the given pool.
+// Call CreateTable to create the table and Start to load and refresh the filter.
+// Returns error if cfg is invalid.
+func NewPgRevocationList(pool *pgxpool.Pool, cfg RevocationConfig) (*PgRevocationList, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create revocation list: %w", err)
+	}
+
+	table := cfg.Table
+	if table == "" {
+		table = DefaultRevocationTable
+	}
+	interval := cfg.RefreshInterval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	fpRate := cfg.FalsePositiveRate
+	if fpRate == 0 {
+		fpRate = 0.001
+	}
+
+	return &PgRevocationList{
+		pool:     pool,
+		table:    postgres.QuoteTable(table),
+		interval: interval,
+		fpRate:   fpRate,
+	}, nil
+}
+
+// CreateTable creates the revocation table if it does not exist.
+func (l *PgRevocationList) CreateTable(ctx context.Context) error {
+	_, err := l.pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+l.table+` (
+		issuer        TEXT NOT NULL,
+		kind          TEXT NOT NULL,
+		value         TEXT NOT NULL,
+		issued_before TIMESTAMPTZ,
+		expires_at    TIMESTAMPTZ,
+		revoked_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (issuer, kind, value)
+	)`)
+	if err != nil {
+		return fmt.Errorf("create revocation table: %w", err)
+	}
+	return nil
+}
+
+// Start loads the Bloom filter and refreshes it every RefreshInterval until ctx is done.
+// Returns error if the initial load fails.
+func (l *PgRevocationList) Start(ctx context.Context) error {
+	if err := l.Refresh(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := l.Refresh(ctx); err != nil {
+					slog.WarnContext(ctx, "revocation list refresh failed", "error", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Refresh rebuilds the Bloom filter from all active revocations.
+// Revocations made by this instance while it runs are kept.
+func (l *PgRevocationList) Refresh(ctx context.Context) error {
+	l.refreshMu.Lock()
+	defer l.refreshMu.Unlock()
+
+	l.beginRefresh()
+	keys, err := l.load(ctx)
+	if err != nil {
+		l.finishRefresh(nil)
+		return err
+	}
+
+	filter := newBloomFilter(max(len(keys), 1024), l.fpRate)
+	for _, key := range keys {
+		filter.add(key)
+	}
+	l.finishRefresh(filter)
+	return nil
+}
+
+// load returns the filter keys of all active revocations.
+func (l *PgRevocationList) load(ctx context.Context) ([]string, error) {
+	rows, err := l.pool.Query(ctx, `SELECT issuer, kind, value FROM `+l.table+`
+		WHERE expires_at IS NULL OR expires_at > now()`)
+	if err != nil {
+		return nil, fmt.Errorf("load revocations: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var issuer, kind, value string
+		if err := rows.Scan(&issuer, &kind, &value); err != nil {
+			return nil, fmt.Errorf("load revocations: %w", err)
+		}
+		keys = append(keys, filterKey(issuer, kind, value))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load revocations: %w", err)
+	}
+	return keys, nil
+}
+
+// beginRefresh starts recording local revocations in pending.
+func (l *PgRevocationList) beginRefresh() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refreshing = true
+	l.pending = nil
+}
+
+// finishRefresh adds the pending revocations to filter and swaps it in.
+// A nil filter keeps the current one.
+func (l *PgRevocationList) finishRefresh(filter *bloomFilter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if filter != nil {
+		for _, key := range l.pending {
+			filter.add(key)
+		}
+		l.filter.Store(filter)
+	}
+	l.refreshing = false
+	l.pending = nil
+}
+
+// addRevoked adds a local revocation to the current filter, and to the
+// pending ones if a refresh is loading.
+func (l *PgRevocationList) addRevoked(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if filter := l.filter.Load(); filter != nil {
+		filter.add(key)
+	}
+	if l.refreshing {
+		l.pending = append(l.pending, key)
+	}
+}
+
+// IsRevoked reports whether tok was revoked by its issuer.
+func (l *PgRevocationList) IsRevoked(ctx context.Context, tok TokenIdentity) (bool, error) {
+	var jti, sid, sub string
+	filter := l.filter.Load()
+	if tok.ID != "" && (filter == nil || filter.mayContain(filterKey(tok.Issuer, revokedToken, tok.ID))) {
+		jti = tok.ID
+	}
+	if tok.SessionID != "" && (filter == nil || filter.mayContain(filterKey(tok.Issuer, revokedSession, tok.SessionID))) {
+		sid = tok.SessionID
+	}
+	if tok.Subject != "" && (filter == nil || filter.mayContain(filterKey(tok.Issuer, revokedSubject, tok.Subject))) {
+		sub = tok.Subject
+	}
+	if jti == "" && sid == "" && sub == "" {
+		return false, nil
+	}
+
+	var revoked bool
+	err := l.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM `+l.table+`
+		WHERE issuer = $1 AND (expires_at IS NULL OR expires_at > now()) AND (
+			(kind = $2 AND value = $3) OR
+			(kind = $4 AND value = $5) OR
+			(kind = $6 AND value = $7 AND issued_before > $8)
+		))`,
+		tok.Issuer, revokedToken, jti, revokedSession, sid, revokedSubject, sub, tok.IssuedAt).
+		Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("check revocation: %w", err)
+	}
+	return revoked, nil
+}
+
+// RevokeToken revokes the token of issuer iss with the given "jti" until
+// expiresAt, normally the token's own expiry. A zero expiresAt never expires.
+func (l *PgRevocationList) RevokeToken(ctx context.Context, iss, jti string, expiresAt time.Time) error {
+	return l.revoke(ctx, iss, revokedToken, jti, nil, expiresAt)
+}
+
+// RevokeSession revokes all tokens of issuer iss with the given "sid" until
+// expiresAt. A zero expiresAt never expires.
+func (l *PgRevocationList) RevokeSession(ctx context.Context, iss, sid string, expiresAt time.Time) error {
+	return l.revoke(ctx, iss, revokedSession, sid, nil, expiresAt)
+}
+
+// RevokeSubject revokes all tokens of issuer iss for the subject issued before
+// issuedBefore. Tokens issued afterwards, e.g. after the user re-authenticates,
+// stay valid.
+func (l *PgRevocationList) RevokeSubject(ctx context.Context, iss, sub string, issuedBefore time.Time) error {
+	return l.revoke(ctx, iss, revokedSubject, sub, &issuedBefore, time.Time{})
+}
+
+// DeleteExpired removes revocations whose expiry has passed.
+func (l *PgRevocationList) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := l.pool.Exec(ctx, `DELETE FROM `+l.table+` WHERE expires_at <= now()`)
+	if err != nil {
+		return 0, fmt.Errorf("delete expired revocations: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+// revoke upserts a revocation and adds it to the local filter, so it takes
+// effect on this instance immediately.
+func (l *PgRevocationList) revoke(ctx context.Context, issuer, kind, value string, issuedBefore *time.Time, expiresAt time.Time) error {
+	var expires *time.Time
+	if !expiresAt.IsZero() {
+		expires = &expiresAt
+	}
+
+	_, err := l.pool.Exec(ctx, `INSERT INTO `+l.table+` (issuer, kind, value, issued_before, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (issuer, kind, value) DO UPDATE SET
+			issued_before = GREATEST(`+l.table+`.issued_before, EXCLUDED.issued_before),
+			expires_at    = EXCLUDED.expires_at,
+			revoked_at    = now()`,
+		issuer, kind, value, issuedBefore, expires)
+	if err != nil {
+		return fmt.Errorf("revoke %s: %w", kind, err)
+	}
+
+	l.addRevoked(filterKey(issuer, kind, value))
+	return nil
+}
+
+// filterKey returns the Bloom filter key of a revocation. NUL separates the
+// parts, since issuers are URLs that contain ':'.
+func filterKey(issuer, kind, value string) string {
+	return issuer + "\x00" + kind + "\x00" + value
+}
+
+// compile-time check
+var _ RevocationChecker = (*PgRevocationList)(nil)
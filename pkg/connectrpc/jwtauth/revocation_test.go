@@ -0,0 +1,347 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+)
+
+func TestRevocationConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     RevocationConfig
+		wantErr error
+	}{
+		{
+			name:    "default config",
+			cfg:     DefaultRevocationConfig(),
+			wantErr: nil,
+		},
+		{
+			name:    "zero uses default",
+			cfg:     RevocationConfig{},
+			wantErr: nil,
+		},
+		{
+			name:    "false positive rate too high",
+			cfg:     RevocationConfig{FalsePositiveRate: 1},
+			wantErr: ErrInvalidFalsePositiveRate,
+		},
+		{
+			name:    "negative false positive rate",
+			cfg:     RevocationConfig{FalsePositiveRate: -0.1},
+			wantErr: ErrInvalidFalsePositiveRate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.cfg.Validate()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInMemoryRevocationList(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	const iss = "https://idp.example.com"
+	list := NewInMemoryRevocationList()
+	list.now = func() time.Time { return now }
+	_ = list.RevokeToken(ctx, iss, "revoked-jti", now.Add(time.Hour))
+	_ = list.RevokeToken(ctx, iss, "expired-jti", now.Add(-time.Minute))
+	_ = list.RevokeSession(ctx, iss, "revoked-sid", time.Time{})
+	_ = list.RevokeSubject(ctx, iss, "user-123", now)
+	_ = list.RevokeSubject(ctx, iss, "user-123", now.Add(-time.Hour)) // earlier cutoff is ignored
+
+	tests := []struct {
+		name string
+		tok  TokenIdentity
+		want bool
+	}{
+		{
+			name: "unrelated token",
+			tok:  TokenIdentity{Issuer: iss, ID: "jti-1", Subject: "user-456", SessionID: "sid-1", IssuedAt: now},
+			want: false,
+		},
+		{
+			name: "revoked jti",
+			tok:  TokenIdentity{Issuer: iss, ID: "revoked-jti", Subject: "user-456", IssuedAt: now},
+			want: true,
+		},
+		{
+			name: "revocation past its expiry",
+			tok:  TokenIdentity{Issuer: iss, ID: "expired-jti", Subject: "user-456", IssuedAt: now},
+			want: false,
+		},
+		{
+			name: "revoked session",
+			tok:  TokenIdentity{Issuer: iss, ID: "jti-1", Subject: "user-456", SessionID: "revoked-sid", IssuedAt: now},
+			want: true,
+		},
+		{
+			name: "subject token issued before cutoff",
+			tok:  TokenIdentity{Issuer: iss, ID: "jti-1", Subject: "user-123", IssuedAt: now.Add(-30 * time.Minute)},
+			want: true,
+		},
+		{
+			name: "subject token issued after cutoff",
+			tok:  TokenIdentity{Issuer: iss, ID: "jti-1", Subject: "user-123", IssuedAt: now.Add(time.Second)},
+			want: false,
+		},
+		{
+			name: "subject token without iat",
+			tok:  TokenIdentity{Issuer: iss, ID: "jti-1", Subject: "user-123"},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := list.IsRevoked(ctx, tt.tok)
+			if err != nil {
+				t.Fatalf("IsRevoked() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryRevocationList_ScopedByIssuer(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	const issA, issB = "https://idp-a.example.com", "https://idp-b.example.com"
+	list := NewInMemoryRevocationList()
+	list.now = func() time.Time { return now }
+	_ = list.RevokeToken(ctx, issA, "jti-1", time.Time{})
+	_ = list.RevokeSession(ctx, issA, "sid-1", time.Time{})
+	_ = list.RevokeSubject(ctx, issA, "123", now)
+
+	tests := []struct {
+		name string
+		tok  TokenIdentity
+		want bool
+	}{
+		{
+			name: "jti of revoking issuer",
+			tok:  TokenIdentity{Issuer: issA, ID: "jti-1", IssuedAt: now},
+			want: true,
+		},
+		{
+			name: "same jti of other issuer",
+			tok:  TokenIdentity{Issuer: issB, ID: "jti-1", IssuedAt: now},
+			want: false,
+		},
+		{
+			name: "same sid of other issuer",
+			tok:  TokenIdentity{Issuer: issB, SessionID: "sid-1", IssuedAt: now},
+			want: false,
+		},
+		{
+			name: "subject of revoking issuer",
+			tok:  TokenIdentity{Issuer: issA, Subject: "123", IssuedAt: now.Add(-time.Minute)},
+			want: true,
+		},
+		{
+			name: "same subject of other issuer",
+			tok:  TokenIdentity{Issuer: issB, Subject: "123", IssuedAt: now.Add(-time.Minute)},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := list.IsRevoked(ctx, tt.tok)
+			if err != nil {
+				t.Fatalf("IsRevoked() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPgRevocationList_RevokeDuringRefresh(t *testing.T) {
+	t.Parallel()
+
+	l := &PgRevocationList{}
+	l.filter.Store(newBloomFilter(16, 0.001))
+
+	// A revocation made after the refresh loaded its rows must survive the swap.
+	l.beginRefresh()
+	key := filterKey("https://idp.example.com", revokedToken, "jti-1")
+	l.addRevoked(key)
+	if !l.filter.Load().mayContain(key) {
+		t.Error("current filter does not contain the revocation")
+	}
+	l.finishRefresh(newBloomFilter(16, 0.001))
+	if !l.filter.Load().mayContain(key) {
+		t.Error("refreshed filter lost the revocation made during the refresh")
+	}
+
+	// Outside a refresh nothing is kept for the next one.
+	l.addRevoked(filterKey("https://idp.example.com", revokedToken, "jti-2"))
+	if len(l.pending) != 0 {
+		t.Errorf("pending = %v, want none outside a refresh", l.pending)
+	}
+}
+
+func TestAuthenticator_Revocation(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	srv := setupTestJWKSServer(t, pubKey)
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = srv.URL
+	cfg.Issuer = "test-issuer"
+	cfg.Audience = "test-audience"
+
+	ctx := context.Background()
+	list := NewInMemoryRevocationList()
+	auth, err := NewAuthenticator(ctx, cfg, WithRevocationChecker(list))
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	issuedAt := time.Now().Add(-time.Minute)
+	token := func(jti, sid string) string {
+		return signTestToken(t, privKey, map[string]any{
+			"iss": "test-issuer",
+			"aud": []string{"test-audience"},
+			"sub": "user-123",
+			"jti": jti,
+			"sid": sid,
+			"iat": issuedAt.Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+	}
+
+	if _, err := auth.Authenticate(ctx, token("jti-1", "sid-1")); err != nil {
+		t.Fatalf("Authenticate() before revocation error = %v", err)
+	}
+
+	_ = list.RevokeToken(ctx, "test-issuer", "jti-1", time.Now().Add(time.Hour))
+	if _, err := auth.Authenticate(ctx, token("jti-1", "sid-1")); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() revoked jti error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := auth.Authenticate(ctx, token("jti-2", "sid-1")); err != nil {
+		t.Errorf("Authenticate() other jti error = %v", err)
+	}
+
+	_ = list.RevokeSession(ctx, "test-issuer", "sid-1", time.Time{})
+	if _, err := auth.Authenticate(ctx, token("jti-2", "sid-1")); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() revoked session error = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	_ = list.RevokeSubject(ctx, "test-issuer", "user-123", issuedAt.Add(-time.Minute))
+	if _, err := auth.Authenticate(ctx, token("jti-3", "sid-2")); err != nil {
+		t.Errorf("Authenticate() issued after subject cutoff error = %v", err)
+	}
+
+	_ = list.RevokeSubject(ctx, "test-issuer", "user-123", time.Now())
+	if _, err := auth.Authenticate(ctx, token("jti-3", "sid-2")); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() revoked subject error = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestInterceptor_RevocationCheckFailure(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	srv := setupTestJWKSServer(t, pubKey)
+	t.Cleanup(srv.Close)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = srv.URL
+	cfg.Issuer = "test-issuer"
+	cfg.Audience = "test-audience"
+
+	auth, err := NewAuthenticator(context.Background(), cfg, WithRevocationChecker(failingRevocationChecker{}))
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	i := newTestInterceptor(t, auth)
+
+	_, err = i.authenticate(context.Background(), signTestToken(t, privKey, map[string]any{
+		"iss": "test-issuer",
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}))
+
+	var connectErr *connect.Error
+	if !errors.As(err, &connectErr) {
+		t.Fatalf("expected connect.Error, got %T", err)
+	}
+	if connectErr.Code() != connect.CodeUnavailable {
+		t.Errorf("code = %v, want %v", connectErr.Code(), connect.CodeUnavailable)
+	}
+	if !errors.Is(connectErr.Unwrap(), ErrRevocationCheck) {
+		t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), ErrRevocationCheck)
+	}
+}
+
+func TestIntrospector_RevocationOnCacheHit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	srv := newTestIntrospectionServer(t)
+	srv.set("opaque", map[string]any{
+		"active": true,
+		"iss":    "https://idp.example.com",
+		"sub":    "user-123",
+		"jti":    "jti-1",
+		"exp":    float64(now.Add(time.Hour).Unix()),
+	})
+
+	cfg := DefaultIntrospectionConfig()
+	cfg.URL = srv.URL
+	cfg.ClientID = "client"
+	cfg.ClientSecret = "s3cr3t"
+
+	list := NewInMemoryRevocationList()
+	i, err := NewIntrospector(cfg, WithRevocationChecker(list))
+	if err != nil {
+		t.Fatalf("NewIntrospector() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if _, err := i.Authenticate(ctx, "opaque"); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	_ = list.RevokeToken(ctx, "https://idp.example.com", "jti-1", now.Add(time.Hour))
+	if _, err := i.Authenticate(ctx, "opaque"); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() cached revoked token error = %v, want %v", err, ErrTokenRevoked)
+	}
+	if calls := srv.calls.Load(); calls != 1 {
+		t.Errorf("introspection calls = %d, want 1", calls)
+	}
+}
+
+type failingRevocationChecker struct{}
+
+func (failingRevocationChecker) IsRevoked(context.Context, TokenIdentity) (bool, error) {
+	return false, errors.New("connection refused")
+}
@@ -0,0 +1,133 @@
+package jwtauth
+
+import (
+	"context"
+	"crypto"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+const meterName = "github.com/deepworx/go-utils/pkg/connectrpc/jwtauth"
+
+// Token cache lookup results recorded in the "result" attribute.
+var (
+	cacheHit  = metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "hit")))
+	cacheMiss = metric.WithAttributeSet(attribute.NewSet(attribute.String("result", "miss")))
+)
+
+// verifiedToken is the cached outcome of a successful token verification.
+type verifiedToken struct {
+	claims   ctxutil.Claims
+	identity TokenIdentity
+
+	// jwksURL is the key set the token was verified against.
+	jwksURL string
+
+	// algorithms is the discovered algorithm allow-list the token was checked against.
+	algorithms []string
+}
+
+// tokenCache caches verified tokens by token hash. All entries are dropped
+// when the keys published at any JWKS URL change.
+type tokenCache struct {
+	entries *lru[verifiedToken]
+	ttl     time.Duration
+	lookups metric.Int64Counter
+
+	mu           sync.Mutex
+	sets         map[string]jwk.Set
+	fingerprints map[string]string
+}
+
+func newTokenCache(size int, ttl time.Duration, mp metric.MeterProvider) (*tokenCache, error) {
+	lookups, err := mp.Meter(meterName).Int64Counter(
+		"jwtauth.token_cache.lookups",
+		metric.WithDescription("Number of verified-token cache lookups by result (hit, miss)"),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create token_cache.lookups metric: %w", err)
+	}
+
+	return &tokenCache{
+		entries:      newLRU[verifiedToken](size),
+		ttl:          ttl,
+		lookups:      lookups,
+		sets:         make(map[string]jwk.Set),
+		fingerprints: make(map[string]string),
+	}, nil
+}
+
+// get returns the cached verification for token.
+func (c *tokenCache) get(token string, now time.Time) (verifiedToken, bool) {
+	return c.entries.get(tokenKey(token), now)
+}
+
+// add caches v until the token expires or the TTL elapses, whichever comes first.
+func (c *tokenCache) add(token string, v verifiedToken, exp, now time.Time) {
+	expires := now.Add(c.ttl)
+	if !exp.IsZero() && exp.Before(expires) {
+		expires = exp
+	}
+	c.entries.add(tokenKey(token), v, expires)
+}
+
+// record counts a lookup as a hit or miss.
+func (c *tokenCache) record(ctx context.Context, hit bool) {
+	if hit {
+		c.lookups.Add(ctx, 1, cacheHit)
+	} else {
+		c.lookups.Add(ctx, 1, cacheMiss)
+	}
+}
+
+// observe records the key set currently served for url. If its keys differ
+// from the previously observed set, all cached tokens are dropped and observe
+// returns true.
+func (c *tokenCache) observe(url string, set jwk.Set) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prev, seen := c.sets[url]
+	if seen && prev == set {
+		return false
+	}
+	c.sets[url] = set
+
+	fp := keysetFingerprint(set)
+	rotated := seen && c.fingerprints[url] != fp
+	c.fingerprints[url] = fp
+	if rotated {
+		c.entries.purge()
+	}
+	return rotated
+}
+
+// keysetFingerprint identifies the keys in set independent of their order.
+func keysetFingerprint(set jwk.Set) string {
+	keys := make([]string, 0, set.Len())
+	for n := range set.Len() {
+		key, ok := set.Key(n)
+		if !ok {
+			continue
+		}
+		kid, _ := key.KeyID()
+		tp, err := key.Thumbprint(crypto.SHA256)
+		if err != nil {
+			continue
+		}
+		keys = append(keys, kid+":"+hex.EncodeToString(tp))
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, ",")
+}
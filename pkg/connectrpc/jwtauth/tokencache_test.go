@@ -0,0 +1,214 @@
+package jwtauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v3/jwk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestTokenCache_Expiry(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		exp    time.Time
+		at     time.Time
+		wantOK bool
+	}{
+		{
+			name:   "before ttl",
+			exp:    now.Add(time.Hour),
+			at:     now.Add(30 * time.Second),
+			wantOK: true,
+		},
+		{
+			name:   "ttl elapsed",
+			exp:    now.Add(time.Hour),
+			at:     now.Add(time.Minute),
+			wantOK: false,
+		},
+		{
+			name:   "token expired before ttl",
+			exp:    now.Add(10 * time.Second),
+			at:     now.Add(10 * time.Second),
+			wantOK: false,
+		},
+		{
+			name:   "no exp uses ttl",
+			at:     now.Add(30 * time.Second),
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := newTokenCache(10, time.Minute, noop.NewMeterProvider())
+			if err != nil {
+				t.Fatalf("newTokenCache() error = %v", err)
+			}
+			c.add("token", verifiedToken{claims: ctxutil.Claims{UserID: "user-123"}}, tt.exp, now)
+
+			_, ok := c.get("token", tt.at)
+			if ok != tt.wantOK {
+				t.Errorf("get() ok = %v, want %v", ok, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTokenCache_Observe(t *testing.T) {
+	t.Parallel()
+
+	_, key1 := generateTestKeys(t)
+	_, key2 := generateTestKeys(t)
+	if err := key2.Set(jwk.KeyIDKey, "key-2"); err != nil {
+		t.Fatalf("failed to set key ID: %v", err)
+	}
+
+	set := func(keys ...jwk.Key) jwk.Set {
+		s := jwk.NewSet()
+		for _, k := range keys {
+			_ = s.AddKey(k)
+		}
+		return s
+	}
+
+	c, err := newTokenCache(10, time.Minute, noop.NewMeterProvider())
+	if err != nil {
+		t.Fatalf("newTokenCache() error = %v", err)
+	}
+	now := time.Now()
+
+	if c.observe("https://idp/jwks", set(key1, key2)) {
+		t.Error("observe() first set = rotated, want not rotated")
+	}
+	c.add("token", verifiedToken{}, time.Time{}, now)
+
+	if c.observe("https://idp/jwks", set(key2, key1)) {
+		t.Error("observe() same keys refetched = rotated, want not rotated")
+	}
+	if _, ok := c.get("token", now); !ok {
+		t.Fatal("get() after refetch of same keys = miss, want hit")
+	}
+
+	if !c.observe("https://idp/jwks", set(key2)) {
+		t.Error("observe() changed keys = not rotated, want rotated")
+	}
+	if _, ok := c.get("token", now); ok {
+		t.Error("get() after rotation = hit, want miss")
+	}
+}
+
+func TestAuthenticator_TokenCache(t *testing.T) {
+	t.Parallel()
+
+	privKey, pubKey := generateTestKeys(t)
+	idp := newTestIdP(t, pubKey)
+
+	cfg := DefaultConfig()
+	cfg.JWKSURL = idp.URL + "/jwks"
+	cfg.Issuer = idp.URL
+	cfg.Audience = "test-audience"
+	cfg.TokenCacheSize = 100
+
+	ctx := context.Background()
+	revocations := NewInMemoryRevocationList()
+	auth, err := NewAuthenticator(ctx, cfg, WithRevocationChecker(revocations))
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(ctx) })
+	if auth.tokens, err = newTokenCache(cfg.TokenCacheSize, cfg.TokenCacheTTL, mp); err != nil {
+		t.Fatalf("newTokenCache() error = %v", err)
+	}
+
+	claims := map[string]any{
+		"iss": idp.URL,
+		"aud": []string{"test-audience"},
+		"sub": "user-123",
+		"jti": "jti-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	token := signTestToken(t, privKey, claims)
+
+	for range 3 {
+		got, err := auth.Authenticate(ctx, token)
+		if err != nil {
+			t.Fatalf("Authenticate() error = %v", err)
+		}
+		if got.UserID != "user-123" {
+			t.Errorf("UserID = %q, want %q", got.UserID, "user-123")
+		}
+	}
+	if hits, misses := cacheLookups(t, reader); hits != 2 || misses != 1 {
+		t.Errorf("lookups = %d hits, %d misses, want 2 hits, 1 miss", hits, misses)
+	}
+
+	// Revocation is checked on cache hits.
+	_ = revocations.RevokeToken(ctx, idp.URL, "jti-1", time.Now().Add(time.Hour))
+	if _, err := auth.Authenticate(ctx, token); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Authenticate() revoked cached token error = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	// A discovered allow-list that drops the token's algorithm rejects it,
+	// even though it was cached.
+	claims["jti"] = "jti-3"
+	token = signTestToken(t, privKey, claims)
+	if _, err := auth.Authenticate(ctx, token); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	iss := auth.issuers[idp.URL]
+	src := iss.keys.Load()
+	iss.keys.Store(&keySource{jwksURL: src.jwksURL, algorithms: []string{"ES256"}})
+	if _, err := auth.Authenticate(ctx, token); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("Authenticate() cached token with disallowed algorithm error = %v, want %v", err, ErrUnsupportedAlgorithm)
+	}
+	iss.keys.Store(src)
+
+	// Rotate the IdP to a new key. The next JWKS refresh drops tokens
+	// verified against the old keys.
+	claims["jti"] = "jti-2"
+	token = signTestToken(t, privKey, claims)
+	if _, err := auth.Authenticate(ctx, token); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	newPriv, newPub := generateTestKeys(t)
+	if err := newPub.Set(jwk.KeyIDKey, "key-2"); err != nil {
+		t.Fatalf("failed to set key ID: %v", err)
+	}
+	idp.setKeys(t, "/jwks", newPub)
+	if _, err := auth.cache.Refresh(ctx, cfg.JWKSURL); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, signTestTokenWithKeyID(t, newPriv, "key-2", claims)); err != nil {
+		t.Fatalf("Authenticate() with rotated key error = %v", err)
+	}
+	if _, err := auth.Authenticate(ctx, token); err == nil {
+		t.Error("Authenticate() token signed with retired key succeeded, want error")
+	}
+}
+
+func cacheLookups(t *testing.T, reader *sdkmetric.ManualReader) (hits, misses int64) {
+	t.Helper()
+
+	rm := collectMetrics(t, reader)
+	hits = int64Value(t, rm, "jwtauth.token_cache.lookups", attribute.NewSet(attribute.String("result", "hit")))
+	misses = int64Value(t, rm, "jwtauth.token_cache.lookups", attribute.NewSet(attribute.String("result", "miss")))
+	return hits, misses
+}
@@ -0,0 +1,141 @@
+package loadshed
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// gate counts in-flight requests against a fixed or adaptive limit.
+type gate struct {
+	mu        sync.Mutex
+	limit     float64
+	inflight  int
+	algorithm algorithm
+}
+
+func newGate(limit int, alg algorithm) *gate {
+	return &gate{limit: float64(limit), algorithm: alg}
+}
+
+// acquire takes a slot if fewer than share of the limit are in flight.
+func (g *gate) acquire(share float64) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.inflight >= capacity(g.limit, share) {
+		return false
+	}
+	g.inflight++
+	return true
+}
+
+// release frees a slot and, if sample is set, feeds the request to the algorithm.
+func (g *gate) release(latency time.Duration, dropped, sample bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if sample && g.algorithm != nil {
+		g.limit = g.algorithm.update(g.limit, latency, g.inflight, dropped)
+	}
+	g.inflight--
+}
+
+func (g *gate) currentLimit() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.limit
+}
+
+// algorithm computes a new limit from a completed request.
+// Calls are serialized by the gate.
+type algorithm interface {
+	// update returns the limit after a request that took latency while
+	// inflight requests, including itself, were running.
+	update(limit float64, latency time.Duration, inflight int, dropped bool) float64
+}
+
+// newAlgorithm returns the configured algorithm, or nil for a fixed limit.
+func newAlgorithm(cfg Adaptive) algorithm {
+	bounds := bounds{min: float64(cfg.MinLimit), max: float64(cfg.MaxLimit)}
+	switch cfg.Algorithm {
+	case AlgorithmAIMD:
+		return &aimd{bounds: bounds, timeout: cfg.Timeout, backoffRatio: cfg.BackoffRatio}
+	case AlgorithmGradient:
+		return &gradient{bounds: bounds, tolerance: cfg.Tolerance, smoothing: cfg.Smoothing}
+	default:
+		return nil
+	}
+}
+
+type bounds struct {
+	min, max float64
+}
+
+func (b bounds) clamp(limit float64) float64 {
+	return min(b.max, max(b.min, limit))
+}
+
+// aimd increases the limit additively while requests are fast and decreases
+// it multiplicatively when one is slow or hits its deadline.
+type aimd struct {
+	bounds
+	timeout      time.Duration
+	backoffRatio float64
+}
+
+func (a *aimd) update(limit float64, latency time.Duration, inflight int, dropped bool) float64 {
+	switch {
+	case dropped || latency > a.timeout:
+		limit *= a.backoffRatio
+	case float64(inflight)*2 >= limit:
+		// Only grow while the limit is actually being used.
+		limit++
+	}
+	return a.clamp(limit)
+}
+
+// longWindow is the number of samples the gradient's baseline latency averages over.
+const longWindow = 600
+
+// gradient compares each request's latency with a slowly moving baseline.
+// When latency rises above the baseline, requests are queueing, and the
+// limit shrinks proportionally; otherwise it grows by a queue allowance of
+// sqrt(limit).
+type gradient struct {
+	bounds
+	tolerance float64
+	smoothing float64
+
+	// longRTT is an exponential moving average of latency in seconds.
+	longRTT float64
+}
+
+func (g *gradient) update(limit float64, latency time.Duration, inflight int, dropped bool) float64 {
+	rtt := latency.Seconds()
+	if rtt <= 0 {
+		return limit
+	}
+
+	if g.longRTT == 0 {
+		g.longRTT = rtt
+	} else {
+		g.longRTT += (rtt - g.longRTT) * 2 / (longWindow + 1)
+	}
+	// Let the baseline recover quickly after a sustained latency drop.
+	if g.longRTT > 2*rtt {
+		g.longRTT *= 0.95
+	}
+
+	// Do not grow the limit while the service is not using it.
+	if float64(inflight) < limit/2 && !dropped {
+		return limit
+	}
+
+	grad := max(0.5, min(1, g.tolerance*g.longRTT/rtt))
+	if dropped {
+		grad = 0.5
+	}
+	next := limit*grad + math.Sqrt(limit)
+	return g.clamp(limit*(1-g.smoothing) + next*g.smoothing)
+}
@@ -0,0 +1,377 @@
+// Package loadshed provides concurrency limiting and adaptive load shedding for Connect RPC handlers.
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+const meterName = "github.com/deepworx/go-utils/pkg/connectrpc/loadshed"
+
+// Adaptive limit algorithms.
+const (
+	// AlgorithmAIMD grows the limit by one while requests complete within
+	// Timeout and shrinks it by BackoffRatio when they do not.
+	AlgorithmAIMD = "aimd"
+
+	// AlgorithmGradient adjusts the limit by the ratio of long-term to
+	// short-term latency, shrinking it as requests start to queue.
+	AlgorithmGradient = "gradient"
+)
+
+// defaultPriority names requests that match no priority class.
+const defaultPriority = "default"
+
+// ErrOverloaded is returned when a request is shed.
+var ErrOverloaded = errors.New("server overloaded")
+
+// Sentinel errors for invalid configuration.
+var (
+	// ErrInvalidLimit is returned when Limit is negative or a procedure limit is less than 1.
+	ErrInvalidLimit = errors.New("limit must be non-negative and procedure limits at least 1")
+
+	// ErrInvalidAlgorithm is returned when Adaptive.Algorithm is unknown.
+	ErrInvalidAlgorithm = errors.New("algorithm must be empty, aimd or gradient")
+
+	// ErrInvalidAdaptive is returned when the adaptive limit settings are out of range.
+	ErrInvalidAdaptive = errors.New("adaptive limit requires limit within [min_limit, max_limit] and valid tuning parameters")
+
+	// ErrInvalidShare is returned when a priority share is outside (0, 1].
+	ErrInvalidShare = errors.New("share must be greater than 0 and at most 1")
+
+	// ErrInvalidPriority is returned when a priority class has no name.
+	ErrInvalidPriority = errors.New("priority name must not be empty")
+)
+
+// ProcedureLimit bounds the in-flight requests of a single procedure.
+type ProcedureLimit struct {
+	// Procedure is the full Connect procedure name (e.g., "/pkg.Service/Method").
+	Procedure string `koanf:"procedure"`
+
+	// Limit is the maximum number of in-flight requests of the procedure.
+	Limit int `koanf:"limit"`
+}
+
+// Adaptive configures an adaptive global limit based on observed latency.
+type Adaptive struct {
+	// Algorithm is AlgorithmAIMD, AlgorithmGradient, or empty for a fixed limit.
+	Algorithm string `koanf:"algorithm"`
+
+	// MinLimit is the lower bound of the adaptive limit.
+	// Default: 10
+	MinLimit int `koanf:"min_limit"`
+
+	// MaxLimit is the upper bound of the adaptive limit.
+	// Default: 1000
+	MaxLimit int `koanf:"max_limit"`
+
+	// Timeout is the latency above which AIMD treats a request as a sign of overload.
+	// Default: 1s
+	Timeout time.Duration `koanf:"timeout"`
+
+	// BackoffRatio multiplies the AIMD limit on overload, in (0, 1).
+	// Default: 0.9
+	BackoffRatio float64 `koanf:"backoff_ratio"`
+
+	// Tolerance is how much the gradient algorithm lets short-term latency
+	// exceed long-term latency before shrinking the limit, at least 1.
+	// Default: 1.5
+	Tolerance float64 `koanf:"tolerance"`
+
+	// Smoothing weights each gradient limit update, in (0, 1].
+	// Default: 0.2
+	Smoothing float64 `koanf:"smoothing"`
+}
+
+// Priority is a class of requests that may use a share of each limit.
+// Requests of classes with a lower share are shed first.
+type Priority struct {
+	// Name labels the class in metrics.
+	Name string `koanf:"name"`
+
+	// Share is the fraction of each limit the class may fill, in (0, 1].
+	Share float64 `koanf:"share"`
+
+	// Procedures lists the full procedure names in the class.
+	Procedures []string `koanf:"procedures"`
+
+	// Roles lists caller roles (from ctxutil.Claims) in the class.
+	// A request is in the class if the caller holds any of them.
+	Roles []string `koanf:"roles"`
+}
+
+// Config holds configuration for the load shedding interceptor.
+type Config struct {
+	// Limit is the maximum number of in-flight requests across all procedures,
+	// and the initial limit with an adaptive algorithm. Zero disables the global limit.
+	// Default: 100
+	Limit int `koanf:"limit"`
+
+	// Procedures bounds the in-flight requests of specific procedures.
+	Procedures []ProcedureLimit `koanf:"procedures"`
+
+	// Adaptive adjusts the global limit to observed latency.
+	Adaptive Adaptive `koanf:"adaptive"`
+
+	// Priorities assigns requests to classes. The first matching class applies.
+	Priorities []Priority `koanf:"priorities"`
+
+	// DefaultShare is the fraction of each limit requests without a class may
+	// fill, leaving the rest for classes with a higher share. Zero means 1.
+	// Default: 0.9
+	DefaultShare float64 `koanf:"default_share"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+// The global limit is fixed; set Adaptive.Algorithm to adapt it.
+func DefaultConfig() Config {
+	return Config{
+		Limit: 100,
+		Adaptive: Adaptive{
+			MinLimit:     10,
+			MaxLimit:     1000,
+			Timeout:      time.Second,
+			BackoffRatio: 0.9,
+			Tolerance:    1.5,
+			Smoothing:    0.2,
+		},
+		DefaultShare: 0.9,
+	}
+}
+
+// Validate checks that all fields are within range.
+// Returns nil if configuration is valid.
+func (c Config) Validate() error {
+	if c.Limit < 0 {
+		return ErrInvalidLimit
+	}
+	for _, p := range c.Procedures {
+		if p.Limit < 1 {
+			return fmt.Errorf("%w: %s", ErrInvalidLimit, p.Procedure)
+		}
+	}
+	if err := c.Adaptive.validate(c.Limit); err != nil {
+		return err
+	}
+	if c.DefaultShare < 0 || c.DefaultShare > 1 {
+		return ErrInvalidShare
+	}
+	for _, p := range c.Priorities {
+		if p.Name == "" {
+			return ErrInvalidPriority
+		}
+		if p.Share <= 0 || p.Share > 1 {
+			return fmt.Errorf("%w: %s", ErrInvalidShare, p.Name)
+		}
+	}
+	return nil
+}
+
+func (a Adaptive) validate(limit int) error {
+	switch a.Algorithm {
+	case "":
+		return nil
+	case AlgorithmAIMD:
+		if a.Timeout <= 0 || a.BackoffRatio <= 0 || a.BackoffRatio >= 1 {
+			return ErrInvalidAdaptive
+		}
+	case AlgorithmGradient:
+		if a.Tolerance < 1 || a.Smoothing <= 0 || a.Smoothing > 1 {
+			return ErrInvalidAdaptive
+		}
+	default:
+		return ErrInvalidAlgorithm
+	}
+	if a.MinLimit < 1 || a.MaxLimit < a.MinLimit || limit < a.MinLimit || limit > a.MaxLimit {
+		return ErrInvalidAdaptive
+	}
+	return nil
+}
+
+// NewInterceptor creates a Connect RPC interceptor that bounds the number of
+// in-flight requests globally and per procedure. Requests over a limit are
+// shed with connect.CodeResourceExhausted wrapping ErrOverloaded.
+//
+// Each request belongs to the first priority class matching its procedure or
+// the caller's roles, and may only fill that class's share of each limit, so
+// low-priority traffic is shed before critical traffic. With an adaptive
+// algorithm the global limit follows the latency of completed unary requests;
+// streams hold a slot while open but do not adjust the limit.
+//
+// Place it after jwtauth so that role-based priorities can read claims.
+// Shed requests and the global limit are recorded through the global
+// OpenTelemetry MeterProvider:
+//   - rpc.server.load_shed.rejected: shed requests by procedure, priority, and limit (global, procedure)
+//   - rpc.server.concurrency.limit: current global limit
+//
+// Returns error if cfg is invalid or metric instruments cannot be created.
+func NewInterceptor(cfg Config) (connect.Interceptor, error) {
+	return newInterceptor(cfg, otel.GetMeterProvider())
+}
+
+func newInterceptor(cfg Config, mp metric.MeterProvider) (*interceptor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create load shedding interceptor: %w", err)
+	}
+
+	meter := mp.Meter(meterName)
+
+	rejected, err := meter.Int64Counter(
+		"rpc.server.load_shed.rejected",
+		metric.WithDescription("Number of requests shed because a concurrency limit was reached"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create rejected metric: %w", err)
+	}
+
+	i := &interceptor{
+		procedures:   make(map[string]*gate, len(cfg.Procedures)),
+		priorities:   cfg.Priorities,
+		defaultShare: cfg.DefaultShare,
+		rejected:     rejected,
+		now:          time.Now,
+	}
+	if i.defaultShare == 0 {
+		i.defaultShare = 1
+	}
+	if cfg.Limit > 0 {
+		i.global = newGate(cfg.Limit, newAlgorithm(cfg.Adaptive))
+	}
+	for _, p := range cfg.Procedures {
+		i.procedures[p.Procedure] = newGate(p.Limit, nil)
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"rpc.server.concurrency.limit",
+		metric.WithDescription("Current global limit of in-flight requests"),
+		metric.WithUnit("{request}"),
+		metric.WithInt64Callback(i.observeLimit),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create limit metric: %w", err)
+	}
+
+	return i, nil
+}
+
+type interceptor struct {
+	global       *gate
+	procedures   map[string]*gate
+	priorities   []Priority
+	defaultShare float64
+
+	rejected metric.Int64Counter
+	now      func() time.Time
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+
+		procedure := req.Spec().Procedure
+		release, err := i.acquire(ctx, procedure)
+		if err != nil {
+			return nil, err
+		}
+
+		start := i.now()
+		resp, err := next(ctx, req)
+		release(i.now().Sub(start), connect.CodeOf(err) == connect.CodeDeadlineExceeded, true)
+		return resp, err
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		release, err := i.acquire(ctx, conn.Spec().Procedure)
+		if err != nil {
+			return err
+		}
+		defer release(0, false, false)
+		return next(ctx, conn)
+	}
+}
+
+// releaseFunc frees the slots of a request. If sample is set, the request's
+// latency and whether it hit its deadline adjust an adaptive limit.
+type releaseFunc func(latency time.Duration, dropped, sample bool)
+
+// acquire takes a slot from the procedure and global gates, or returns a
+// ResourceExhausted error if either is full for the request's priority.
+func (i *interceptor) acquire(ctx context.Context, procedure string) (releaseFunc, error) {
+	priority, share := i.priority(ctx, procedure)
+
+	pg := i.procedures[procedure]
+	if pg != nil && !pg.acquire(share) {
+		return nil, i.shed(ctx, procedure, priority, "procedure")
+	}
+	if i.global != nil && !i.global.acquire(share) {
+		if pg != nil {
+			pg.release(0, false, false)
+		}
+		return nil, i.shed(ctx, procedure, priority, "global")
+	}
+
+	return func(latency time.Duration, dropped, sample bool) {
+		if i.global != nil {
+			i.global.release(latency, dropped, sample)
+		}
+		if pg != nil {
+			pg.release(latency, dropped, sample)
+		}
+	}, nil
+}
+
+// priority returns the name and share of the first class matching the
+// procedure or one of the caller's roles.
+func (i *interceptor) priority(ctx context.Context, procedure string) (string, float64) {
+	roles, _ := ctxutil.Roles(ctx)
+	for _, p := range i.priorities {
+		if slices.Contains(p.Procedures, procedure) || slices.ContainsFunc(p.Roles, func(role string) bool {
+			return slices.Contains(roles, role)
+		}) {
+			return p.Name, p.Share
+		}
+	}
+	return defaultPriority, i.defaultShare
+}
+
+func (i *interceptor) shed(ctx context.Context, procedure, priority, limit string) error {
+	i.rejected.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("procedure", procedure),
+		attribute.String("priority", priority),
+		attribute.String("limit", limit),
+	))
+	return connect.NewError(connect.CodeResourceExhausted, ErrOverloaded)
+}
+
+func (i *interceptor) observeLimit(_ context.Context, o metric.Int64Observer) error {
+	if i.global != nil {
+		o.Observe(int64(i.global.currentLimit()))
+	}
+	return nil
+}
+
+// capacity returns how many requests of a class with share fit into limit.
+// Every class may run at least one request.
+func capacity(limit, share float64) int {
+	return max(1, int(math.Floor(limit*share)))
+}
@@ -0,0 +1,431 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr error
+	}{
+		{name: "default config", modify: func(*Config) {}},
+		{name: "zero config", modify: func(c *Config) { *c = Config{} }},
+		{name: "negative limit", modify: func(c *Config) { c.Limit = -1 }, wantErr: ErrInvalidLimit},
+		{
+			name:    "zero procedure limit",
+			modify:  func(c *Config) { c.Procedures = []ProcedureLimit{{Procedure: "/test.Service/Get"}} },
+			wantErr: ErrInvalidLimit,
+		},
+		{name: "aimd", modify: func(c *Config) { c.Adaptive.Algorithm = AlgorithmAIMD }},
+		{name: "gradient", modify: func(c *Config) { c.Adaptive.Algorithm = AlgorithmGradient }},
+		{name: "unknown algorithm", modify: func(c *Config) { c.Adaptive.Algorithm = "vegas" }, wantErr: ErrInvalidAlgorithm},
+		{
+			name: "limit outside adaptive bounds",
+			modify: func(c *Config) {
+				c.Adaptive.Algorithm = AlgorithmAIMD
+				c.Limit = 5
+			},
+			wantErr: ErrInvalidAdaptive,
+		},
+		{
+			name: "aimd backoff ratio 1",
+			modify: func(c *Config) {
+				c.Adaptive.Algorithm = AlgorithmAIMD
+				c.Adaptive.BackoffRatio = 1
+			},
+			wantErr: ErrInvalidAdaptive,
+		},
+		{
+			name: "gradient tolerance below 1",
+			modify: func(c *Config) {
+				c.Adaptive.Algorithm = AlgorithmGradient
+				c.Adaptive.Tolerance = 0.5
+			},
+			wantErr: ErrInvalidAdaptive,
+		},
+		{name: "default share above 1", modify: func(c *Config) { c.DefaultShare = 1.5 }, wantErr: ErrInvalidShare},
+		{
+			name:    "priority without share",
+			modify:  func(c *Config) { c.Priorities = []Priority{{Name: "batch"}} },
+			wantErr: ErrInvalidShare,
+		},
+		{
+			name:    "priority without name",
+			modify:  func(c *Config) { c.Priorities = []Priority{{Share: 1}} },
+			wantErr: ErrInvalidPriority,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			if err := cfg.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInterceptor_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewInterceptor(Config{Limit: -1})
+	if !errors.Is(err, ErrInvalidLimit) {
+		t.Errorf("NewInterceptor() error = %v, want %v", err, ErrInvalidLimit)
+	}
+}
+
+func TestInterceptor_Limits(t *testing.T) {
+	t.Parallel()
+
+	const (
+		get    = "/test.Service/Get"
+		export = "/test.Service/Export"
+		admin  = "/test.Service/Admin"
+	)
+
+	cfg := Config{
+		Limit:      10,
+		Procedures: []ProcedureLimit{{Procedure: export, Limit: 2}},
+		Priorities: []Priority{
+			{Name: "critical", Share: 1, Procedures: []string{admin}, Roles: []string{"operator"}},
+			{Name: "batch", Share: 0.5, Procedures: []string{export}},
+		},
+		DefaultShare: 0.8,
+	}
+	operator := ctxutil.WithClaims(context.Background(), ctxutil.Claims{Roles: []string{"operator"}})
+
+	tests := []struct {
+		name      string
+		inflight  map[string]int
+		ctx       context.Context
+		procedure string
+		wantErr   bool
+	}{
+		{name: "below limit", inflight: map[string]int{get: 7}, procedure: get},
+		{name: "default share reached", inflight: map[string]int{get: 8}, procedure: get, wantErr: true},
+		{name: "critical procedure uses full limit", inflight: map[string]int{get: 9}, procedure: admin},
+		{name: "critical role uses full limit", inflight: map[string]int{get: 9}, ctx: operator, procedure: get},
+		{name: "global limit reached", inflight: map[string]int{get: 10}, procedure: admin, wantErr: true},
+		{name: "batch share of global limit", inflight: map[string]int{get: 5}, procedure: export, wantErr: true},
+		{name: "batch share of procedure limit", inflight: map[string]int{export: 1}, procedure: export, wantErr: true},
+		{name: "at least one request per class", procedure: export},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i, err := newInterceptor(cfg, noop.NewMeterProvider())
+			if err != nil {
+				t.Fatalf("newInterceptor() error = %v", err)
+			}
+			for procedure, n := range tt.inflight {
+				for range n {
+					if _, err := i.acquire(operator, procedure); err != nil {
+						t.Fatalf("acquire(%s) error = %v", procedure, err)
+					}
+				}
+			}
+
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			called := false
+			wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+				called = true
+				return &mockResponse{}, nil
+			})
+			_, err = wrapped(ctx, &mockRequest{spec: connect.Spec{Procedure: tt.procedure}})
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if called == tt.wantErr {
+				t.Errorf("handler called = %v, want %v", called, !tt.wantErr)
+			}
+			if tt.wantErr {
+				if connect.CodeOf(err) != connect.CodeResourceExhausted || !errors.Is(err, ErrOverloaded) {
+					t.Errorf("error = %v, want resource exhausted wrapping %v", err, ErrOverloaded)
+				}
+			}
+		})
+	}
+}
+
+func TestInterceptor_ReleasesSlots(t *testing.T) {
+	t.Parallel()
+
+	const procedure = "/test.Service/Get"
+	i, err := newInterceptor(Config{
+		Limit:      1,
+		Procedures: []ProcedureLimit{{Procedure: procedure, Limit: 1}},
+	}, noop.NewMeterProvider())
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+
+	wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return nil, connect.NewError(connect.CodeInternal, errors.New("boom"))
+	})
+	req := &mockRequest{spec: connect.Spec{Procedure: procedure}}
+	for range 3 {
+		if _, err := wrapped(context.Background(), req); connect.CodeOf(err) != connect.CodeInternal {
+			t.Fatalf("error = %v, want handler error", err)
+		}
+	}
+
+	// A request shed by the global limit returns its procedure slot.
+	release, err := i.acquire(context.Background(), "/test.Service/Other")
+	if err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if _, err := i.acquire(context.Background(), procedure); err == nil {
+		t.Fatal("acquire() should shed at the global limit")
+	}
+	release(0, false, false)
+	if _, err := i.acquire(context.Background(), procedure); err != nil {
+		t.Errorf("acquire() error = %v, want procedure slot returned", err)
+	}
+}
+
+func TestInterceptor_Streaming(t *testing.T) {
+	t.Parallel()
+
+	i, err := newInterceptor(Config{Limit: 1}, noop.NewMeterProvider())
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+	conn := &mockStreamingHandlerConn{spec: connect.Spec{Procedure: "/test.Service/Watch"}}
+
+	var nested error
+	wrapped := i.WrapStreamingHandler(func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		nested = i.WrapStreamingHandler(func(context.Context, connect.StreamingHandlerConn) error {
+			return nil
+		})(ctx, conn)
+		return nil
+	})
+
+	if err := wrapped(context.Background(), conn); err != nil {
+		t.Fatalf("stream error = %v", err)
+	}
+	if connect.CodeOf(nested) != connect.CodeResourceExhausted {
+		t.Errorf("second stream error = %v, want resource exhausted", nested)
+	}
+	if err := wrapped(context.Background(), conn); err != nil {
+		t.Errorf("stream after release error = %v", err)
+	}
+}
+
+func TestInterceptor_ClientPassesThrough(t *testing.T) {
+	t.Parallel()
+
+	i, err := newInterceptor(Config{Limit: 1}, noop.NewMeterProvider())
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+	if _, err := i.acquire(context.Background(), "/test.Service/Get"); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+
+	wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+	if _, err := wrapped(context.Background(), &mockRequest{spec: connect.Spec{Procedure: "/test.Service/Get", IsClient: true}}); err != nil {
+		t.Errorf("client call error = %v", err)
+	}
+}
+
+func TestInterceptor_AdaptiveLimit(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.Adaptive.Algorithm = AlgorithmAIMD
+	cfg.Limit = 20
+	i, err := newInterceptor(cfg, noop.NewMeterProvider())
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+
+	var latency time.Duration
+	now := time.Now()
+	i.now = func() time.Time {
+		now = now.Add(latency)
+		return now
+	}
+	wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+	req := &mockRequest{spec: connect.Spec{Procedure: "/test.Service/Get"}}
+
+	latency = 2 * time.Second
+	for range 5 {
+		_, _ = wrapped(context.Background(), req)
+	}
+	if got := i.global.currentLimit(); got >= 20 {
+		t.Errorf("limit after slow requests = %v, want below 20", got)
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	t.Parallel()
+
+	a := &aimd{bounds: bounds{min: 10, max: 100}, timeout: time.Second, backoffRatio: 0.5}
+
+	tests := []struct {
+		name     string
+		limit    float64
+		latency  time.Duration
+		inflight int
+		dropped  bool
+		want     float64
+	}{
+		{name: "fast and busy grows", limit: 20, latency: time.Millisecond, inflight: 10, want: 21},
+		{name: "fast and idle holds", limit: 20, latency: time.Millisecond, inflight: 2, want: 20},
+		{name: "slow shrinks", limit: 40, latency: 2 * time.Second, inflight: 30, want: 20},
+		{name: "deadline shrinks", limit: 40, latency: time.Millisecond, inflight: 30, dropped: true, want: 20},
+		{name: "clamped to min", limit: 12, latency: 2 * time.Second, want: 10},
+		{name: "clamped to max", limit: 100, latency: time.Millisecond, inflight: 100, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := a.update(tt.limit, tt.latency, tt.inflight, tt.dropped); got != tt.want {
+				t.Errorf("update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGradient(t *testing.T) {
+	t.Parallel()
+
+	g := &gradient{bounds: bounds{min: 10, max: 1000}, tolerance: 1.5, smoothing: 0.2}
+
+	// Steady latency under load grows the limit.
+	limit := 100.0
+	for range 50 {
+		limit = g.update(limit, 10*time.Millisecond, int(limit), false)
+	}
+	if limit <= 100 {
+		t.Fatalf("limit after steady latency = %v, want above 100", limit)
+	}
+
+	// Latency well above the baseline shrinks it.
+	grown := limit
+	for range 20 {
+		limit = g.update(limit, 100*time.Millisecond, int(limit), false)
+	}
+	if limit >= grown {
+		t.Errorf("limit after latency increase = %v, want below %v", limit, grown)
+	}
+
+	// An idle service keeps its limit.
+	idle := g.update(limit, 10*time.Millisecond, 1, false)
+	if idle != limit {
+		t.Errorf("limit while idle = %v, want %v", idle, limit)
+	}
+}
+
+func TestInterceptor_Metrics(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	i, err := newInterceptor(Config{Limit: 1}, mp)
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+	if _, err := i.acquire(context.Background(), "/test.Service/Get"); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	wrapped := i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+	_, _ = wrapped(context.Background(), &mockRequest{spec: connect.Spec{Procedure: "/test.Service/Get"}})
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+
+	shed := attribute.NewSet(
+		attribute.String("procedure", "/test.Service/Get"),
+		attribute.String("priority", "default"),
+		attribute.String("limit", "global"),
+	)
+	if got := int64Value(rm, "rpc.server.load_shed.rejected", shed); got != 1 {
+		t.Errorf("rejected = %d, want 1", got)
+	}
+	if got := int64Value(rm, "rpc.server.concurrency.limit", *attribute.EmptySet()); got != 1 {
+		t.Errorf("limit = %d, want 1", got)
+	}
+}
+
+func int64Value(rm metricdata.ResourceMetrics, name string, attrs attribute.Set) int64 {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			var points []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				points = data.DataPoints
+			case metricdata.Gauge[int64]:
+				points = data.DataPoints
+			}
+			for _, dp := range points {
+				if dp.Attributes.Equals(&attrs) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	spec connect.Spec
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return r.spec
+}
+
+type mockResponse struct {
+	connect.AnyResponse
+}
+
+type mockStreamingHandlerConn struct {
+	connect.StreamingHandlerConn
+	spec connect.Spec
+}
+
+func (c *mockStreamingHandlerConn) Spec() connect.Spec {
+	return c.spec
+}
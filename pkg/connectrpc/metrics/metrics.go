@@ -0,0 +1,281 @@
+// Package metrics provides RED (rate, errors, duration) metrics for Connect RPC handlers.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+const meterName = "github.com/deepworx/go-utils/pkg/connectrpc/metrics"
+
+// OtherTenant is the tenant label used once MaxTenants distinct tenants have been seen.
+const OtherTenant = "other"
+
+// Config holds configuration for the metrics interceptor.
+type Config struct {
+	// DurationBuckets are the histogram bucket boundaries in seconds.
+	// Empty uses the OpenTelemetry SDK defaults.
+	DurationBuckets []float64 `koanf:"duration_buckets"`
+
+	// TenantLabel adds a "tenant" attribute from ctxutil.TenantID.
+	// Default: false (tenant IDs are unbounded and may explode cardinality)
+	TenantLabel bool `koanf:"tenant_label"`
+
+	// MaxTenants caps the number of distinct tenant label values.
+	// Tenants seen after the cap is reached are reported as OtherTenant.
+	// Only used when TenantLabel is true. Zero means 100.
+	MaxTenants int `koanf:"max_tenants"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+func DefaultConfig() Config {
+	return Config{
+		DurationBuckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		MaxTenants:      100,
+	}
+}
+
+// NewInterceptor creates a Connect RPC interceptor that records server metrics
+// through the global OpenTelemetry MeterProvider (see otel.Setup):
+//
+//   - rpc.server.requests: request count by procedure and status
+//   - rpc.server.request.duration: request latency histogram by procedure and status
+//     (named apart from the otelconnect rpc.server.duration histogram in ms)
+//   - rpc.server.active_requests: in-flight requests by procedure
+//   - rpc.server.stream.received_messages: messages received on streams
+//   - rpc.server.stream.sent_messages: messages sent on streams
+//
+// Place it before jwtauth so that rejected requests are counted too; the
+// tenant attribute is then taken from the claims jwtauth sets.
+// The status attribute is "ok" on success, the Connect code name for
+// *connect.Error values, and "unknown" otherwise.
+func NewInterceptor(cfg Config) (connect.Interceptor, error) {
+	return newInterceptor(cfg, otel.GetMeterProvider())
+}
+
+func newInterceptor(cfg Config, mp metric.MeterProvider) (*interceptor, error) {
+	meter := mp.Meter(meterName)
+
+	requests, err := meter.Int64Counter(
+		"rpc.server.requests",
+		metric.WithDescription("Number of completed RPC requests"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create requests metric: %w", err)
+	}
+
+	histOpts := []metric.Float64HistogramOption{
+		metric.WithDescription("Duration of RPC requests"),
+		metric.WithUnit("s"),
+	}
+	if len(cfg.DurationBuckets) > 0 {
+		histOpts = append(histOpts, metric.WithExplicitBucketBoundaries(cfg.DurationBuckets...))
+	}
+	duration, err := meter.Float64Histogram("rpc.server.request.duration", histOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("create duration metric: %w", err)
+	}
+
+	active, err := meter.Int64UpDownCounter(
+		"rpc.server.active_requests",
+		metric.WithDescription("Number of in-flight RPC requests"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create active_requests metric: %w", err)
+	}
+
+	received, err := meter.Int64Counter(
+		"rpc.server.stream.received_messages",
+		metric.WithDescription("Number of messages received on streaming RPCs"),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create received_messages metric: %w", err)
+	}
+
+	sent, err := meter.Int64Counter(
+		"rpc.server.stream.sent_messages",
+		metric.WithDescription("Number of messages sent on streaming RPCs"),
+		metric.WithUnit("{message}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create sent_messages metric: %w", err)
+	}
+
+	i := &interceptor{
+		requests: requests,
+		duration: duration,
+		active:   active,
+		received: received,
+		sent:     sent,
+	}
+	if cfg.TenantLabel {
+		maxTenants := cfg.MaxTenants
+		if maxTenants <= 0 {
+			maxTenants = 100
+		}
+		i.tenants = &tenantGuard{max: maxTenants, seen: make(map[string]struct{})}
+	}
+	return i, nil
+}
+
+type interceptor struct {
+	requests metric.Int64Counter
+	duration metric.Float64Histogram
+	active   metric.Int64UpDownCounter
+	received metric.Int64Counter
+	sent     metric.Int64Counter
+	tenants  *tenantGuard
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+
+		ctx, tenant := i.tenantAttributes(ctx)
+		done := i.begin(ctx, req.Spec().Procedure, tenant)
+		resp, err := next(ctx, req)
+		done(err)
+		return resp, err
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		procedure := conn.Spec().Procedure
+		ctx, tenant := i.tenantAttributes(ctx)
+		done := i.begin(ctx, procedure, tenant)
+		err := next(ctx, &countingConn{
+			StreamingHandlerConn: conn,
+			ctx:                  ctx,
+			received:             i.received,
+			sent:                 i.sent,
+			attrs: sync.OnceValue(func() metric.MeasurementOption {
+				return metric.WithAttributes(append([]attribute.KeyValue{attribute.String("procedure", procedure)}, tenant()...)...)
+			}),
+		})
+		done(err)
+		return err
+	}
+}
+
+// begin marks a request as in flight and returns a function that records
+// its completion. The tenant attributes are resolved on completion.
+func (i *interceptor) begin(ctx context.Context, procedure string, tenant func() []attribute.KeyValue) func(error) {
+	start := time.Now()
+	activeAttrs := metric.WithAttributes(attribute.String("procedure", procedure))
+	i.active.Add(ctx, 1, activeAttrs)
+
+	return func(err error) {
+		i.active.Add(ctx, -1, activeAttrs)
+
+		attrs := append([]attribute.KeyValue{attribute.String("procedure", procedure)}, tenant()...)
+		attrs = append(attrs, attribute.String("status", getStatus(err)))
+		doneAttrs := metric.WithAttributes(attrs...)
+		i.requests.Add(ctx, 1, doneAttrs)
+		i.duration.Record(ctx, time.Since(start).Seconds(), doneAttrs)
+	}
+}
+
+// tenantAttributes returns the context to pass down the chain and a function
+// that returns the tenant attribute, if enabled. When ctx carries no claims
+// yet, the tenant is read from the claims set further down the chain (see
+// ctxutil.WithClaimsRecorder), so the interceptor can run before jwtauth and
+// still count the requests it rejects.
+func (i *interceptor) tenantAttributes(ctx context.Context) (context.Context, func() []attribute.KeyValue) {
+	if i.tenants == nil {
+		return ctx, func() []attribute.KeyValue { return nil }
+	}
+	if claims, ok := ctxutil.GetClaims(ctx); ok {
+		attr := attribute.String("tenant", i.tenants.label(claims.TenantID))
+		return ctx, func() []attribute.KeyValue { return []attribute.KeyValue{attr} }
+	}
+
+	ctx, recorded := ctxutil.WithClaimsRecorder(ctx)
+	return ctx, func() []attribute.KeyValue {
+		claims, _ := recorded()
+		return []attribute.KeyValue{attribute.String("tenant", i.tenants.label(claims.TenantID))}
+	}
+}
+
+func getStatus(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr.Code().String()
+	}
+	return "unknown"
+}
+
+// tenantGuard bounds the number of distinct tenant label values.
+type tenantGuard struct {
+	max int
+
+	mu   sync.Mutex
+	seen map[string]struct{}
+}
+
+// label returns tenantID if it is already tracked or there is room to track it,
+// and OtherTenant otherwise.
+func (g *tenantGuard) label(tenantID string) string {
+	if tenantID == "" {
+		return ""
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.seen[tenantID]; ok {
+		return tenantID
+	}
+	if len(g.seen) >= g.max {
+		return OtherTenant
+	}
+	g.seen[tenantID] = struct{}{}
+	return tenantID
+}
+
+// countingConn counts messages flowing through a streaming handler.
+type countingConn struct {
+	connect.StreamingHandlerConn
+	ctx      context.Context
+	received metric.Int64Counter
+	sent     metric.Int64Counter
+	attrs    func() metric.MeasurementOption
+}
+
+func (c *countingConn) Receive(msg any) error {
+	err := c.StreamingHandlerConn.Receive(msg)
+	if err == nil {
+		c.received.Add(c.ctx, 1, c.attrs())
+	}
+	return err
+}
+
+func (c *countingConn) Send(msg any) error {
+	err := c.StreamingHandlerConn.Send(msg)
+	if err == nil {
+		c.sent.Add(c.ctx, 1, c.attrs())
+	}
+	return err
+}
@@ -0,0 +1,373 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestNewInterceptor(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewInterceptor(DefaultConfig()); err != nil {
+		t.Fatalf("NewInterceptor() error = %v", err)
+	}
+}
+
+func TestInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus string
+	}{
+		{
+			name:       "success",
+			wantStatus: "ok",
+		},
+		{
+			name:       "connect error",
+			err:        connect.NewError(connect.CodeNotFound, errors.New("not found")),
+			wantStatus: "not_found",
+		},
+		{
+			name:       "plain error",
+			err:        errors.New("boom"),
+			wantStatus: "unknown",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i, reader := newTestInterceptor(t, Config{})
+			wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				return &mockResponse{}, tt.err
+			})
+
+			_, _ = wrapped(context.Background(), &mockRequest{procedure: "/test.Service/Method"})
+
+			rm := collect(t, reader)
+			requests := findSum(t, rm, "rpc.server.requests")
+			if len(requests.DataPoints) != 1 {
+				t.Fatalf("requests data points = %d, want 1", len(requests.DataPoints))
+			}
+			dp := requests.DataPoints[0]
+			if dp.Value != 1 {
+				t.Errorf("requests = %d, want 1", dp.Value)
+			}
+			assertAttr(t, dp.Attributes, "procedure", "/test.Service/Method")
+			assertAttr(t, dp.Attributes, "status", tt.wantStatus)
+
+			duration := findHistogram(t, rm, "rpc.server.request.duration")
+			if len(duration.DataPoints) != 1 || duration.DataPoints[0].Count != 1 {
+				t.Errorf("duration data points = %+v, want one observation", duration.DataPoints)
+			}
+
+			active := findSum(t, rm, "rpc.server.active_requests")
+			for _, dp := range active.DataPoints {
+				if dp.Value != 0 {
+					t.Errorf("active_requests = %d, want 0", dp.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestInterceptor_WrapUnary_InFlight(t *testing.T) {
+	t.Parallel()
+
+	i, reader := newTestInterceptor(t, Config{})
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		active := findSum(t, collect(t, reader), "rpc.server.active_requests")
+		if len(active.DataPoints) != 1 || active.DataPoints[0].Value != 1 {
+			t.Errorf("active_requests during call = %+v, want 1", active.DataPoints)
+		}
+		return &mockResponse{}, nil
+	})
+
+	if _, err := wrapped(context.Background(), &mockRequest{procedure: "/test.Service/Method"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestInterceptor_WrapUnary_SkipsClient(t *testing.T) {
+	t.Parallel()
+
+	i, reader := newTestInterceptor(t, Config{})
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+
+	_, _ = wrapped(context.Background(), &mockRequest{procedure: "/test.Service/Method", isClient: true})
+
+	rm := collect(t, reader)
+	if len(rm.ScopeMetrics) != 0 {
+		t.Errorf("expected no metrics for client calls, got %d scopes", len(rm.ScopeMetrics))
+	}
+}
+
+func TestInterceptor_WrapStreamingHandler(t *testing.T) {
+	t.Parallel()
+
+	i, reader := newTestInterceptor(t, Config{})
+	wrapped := i.WrapStreamingHandler(func(_ context.Context, conn connect.StreamingHandlerConn) error {
+		for range 3 {
+			if err := conn.Receive(nil); err != nil {
+				return err
+			}
+		}
+		return conn.Send(nil)
+	})
+
+	conn := &mockStreamingConn{procedure: "/test.Service/Stream"}
+	if err := wrapped(context.Background(), conn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rm := collect(t, reader)
+	received := findSum(t, rm, "rpc.server.stream.received_messages")
+	if received.DataPoints[0].Value != 3 {
+		t.Errorf("received_messages = %d, want 3", received.DataPoints[0].Value)
+	}
+	sent := findSum(t, rm, "rpc.server.stream.sent_messages")
+	if sent.DataPoints[0].Value != 1 {
+		t.Errorf("sent_messages = %d, want 1", sent.DataPoints[0].Value)
+	}
+	requests := findSum(t, rm, "rpc.server.requests")
+	assertAttr(t, requests.DataPoints[0].Attributes, "status", "ok")
+}
+
+func TestInterceptor_TenantLabel(t *testing.T) {
+	t.Parallel()
+
+	i, reader := newTestInterceptor(t, Config{TenantLabel: true, MaxTenants: 2})
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+
+	for _, tenant := range []string{"t1", "t2", "t3", "t4", "t1"} {
+		ctx := ctxutil.WithClaims(context.Background(), ctxutil.Claims{TenantID: tenant})
+		_, _ = wrapped(ctx, &mockRequest{procedure: "/test.Service/Method"})
+	}
+
+	requests := findSum(t, collect(t, reader), "rpc.server.requests")
+	got := make(map[string]int64)
+	for _, dp := range requests.DataPoints {
+		v, _ := dp.Attributes.Value("tenant")
+		got[v.AsString()] += dp.Value
+	}
+
+	want := map[string]int64{"t1": 2, "t2": 1, OtherTenant: 2}
+	if len(got) != len(want) {
+		t.Fatalf("tenant labels = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("tenant %q count = %d, want %d", k, got[k], v)
+		}
+	}
+}
+
+func TestInterceptor_TenantLabelFromDownstreamClaims(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		next       connect.UnaryFunc
+		wantTenant string
+		wantStatus string
+	}{
+		{
+			name: "authenticated",
+			next: func(ctx context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				_ = ctxutil.WithClaims(ctx, ctxutil.Claims{TenantID: "t1"})
+				return &mockResponse{}, nil
+			},
+			wantTenant: "t1",
+			wantStatus: "ok",
+		},
+		{
+			name: "rejected by auth",
+			next: func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("missing token"))
+			},
+			wantTenant: "",
+			wantStatus: "unauthenticated",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i, reader := newTestInterceptor(t, Config{TenantLabel: true, MaxTenants: 10})
+			_, _ = i.WrapUnary(tt.next)(context.Background(), &mockRequest{procedure: "/test.Service/Method"})
+
+			requests := findSum(t, collect(t, reader), "rpc.server.requests")
+			if len(requests.DataPoints) != 1 || requests.DataPoints[0].Value != 1 {
+				t.Fatalf("requests = %+v, want one request", requests.DataPoints)
+			}
+			assertAttr(t, requests.DataPoints[0].Attributes, "tenant", tt.wantTenant)
+			assertAttr(t, requests.DataPoints[0].Attributes, "status", tt.wantStatus)
+		})
+	}
+}
+
+func TestTenantGuard_Label(t *testing.T) {
+	t.Parallel()
+
+	g := &tenantGuard{max: 1, seen: make(map[string]struct{})}
+
+	tests := []struct {
+		tenant string
+		want   string
+	}{
+		{tenant: "", want: ""},
+		{tenant: "a", want: "a"},
+		{tenant: "b", want: OtherTenant},
+		{tenant: "a", want: "a"},
+	}
+
+	for _, tt := range tests {
+		if got := g.label(tt.tenant); got != tt.want {
+			t.Errorf("label(%q) = %q, want %q", tt.tenant, got, tt.want)
+		}
+	}
+}
+
+func TestGetStatus(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "nil", err: nil, want: "ok"},
+		{name: "connect error", err: connect.NewError(connect.CodePermissionDenied, errors.New("x")), want: "permission_denied"},
+		{name: "plain error", err: errors.New("x"), want: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := getStatus(tt.err); got != tt.want {
+				t.Errorf("getStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test helpers
+
+func newTestInterceptor(t *testing.T, cfg Config) (*interceptor, *sdkmetric.ManualReader) {
+	t.Helper()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	i, err := newInterceptor(cfg, mp)
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+	return i, reader
+}
+
+func collect(t *testing.T, reader *sdkmetric.ManualReader) metricdata.ResourceMetrics {
+	t.Helper()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	return rm
+}
+
+func findMetric(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Metrics {
+	t.Helper()
+
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	t.Fatalf("metric %q not found", name)
+	return metricdata.Metrics{}
+}
+
+func findSum(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Sum[int64] {
+	t.Helper()
+
+	sum, ok := findMetric(t, rm, name).Data.(metricdata.Sum[int64])
+	if !ok {
+		t.Fatalf("metric %q is not an int64 sum", name)
+	}
+	return sum
+}
+
+func findHistogram(t *testing.T, rm metricdata.ResourceMetrics, name string) metricdata.Histogram[float64] {
+	t.Helper()
+
+	hist, ok := findMetric(t, rm, name).Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("metric %q is not a float64 histogram", name)
+	}
+	return hist
+}
+
+func assertAttr(t *testing.T, set attribute.Set, key, want string) {
+	t.Helper()
+
+	v, ok := set.Value(attribute.Key(key))
+	if !ok {
+		t.Errorf("attribute %q missing", key)
+		return
+	}
+	if v.AsString() != want {
+		t.Errorf("attribute %q = %q, want %q", key, v.AsString(), want)
+	}
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	procedure string
+	isClient  bool
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return connect.Spec{Procedure: r.procedure, IsClient: r.isClient}
+}
+
+type mockResponse struct {
+	connect.AnyResponse
+}
+
+type mockStreamingConn struct {
+	connect.StreamingHandlerConn
+	procedure string
+}
+
+func (c *mockStreamingConn) Spec() connect.Spec {
+	return connect.Spec{Procedure: c.procedure}
+}
+
+func (c *mockStreamingConn) Receive(_ any) error {
+	return nil
+}
+
+func (c *mockStreamingConn) Send(_ any) error {
+	return nil
+}
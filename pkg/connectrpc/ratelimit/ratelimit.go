@@ -0,0 +1,311 @@
+// Package ratelimit provides token-bucket rate limiting for Connect RPC handlers.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// ErrRateLimited is returned when a request exceeds a configured rate limit.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// RetryAfterHeader is the response metadata key carrying the number of
+// seconds a client should wait before retrying.
+const RetryAfterHeader = "Retry-After"
+
+// sweepInterval controls how often idle buckets are evicted.
+const sweepInterval = time.Minute
+
+// Limit defines a token bucket.
+type Limit struct {
+	// Rate is the number of requests allowed per second.
+	// Zero or negative disables the limit.
+	Rate float64 `koanf:"rate"`
+
+	// Burst is the maximum number of requests allowed at once.
+	// Defaults to ceil(Rate), at least 1.
+	Burst int `koanf:"burst"`
+}
+
+// ProcedureLimit overrides the per-procedure limit for a single procedure.
+type ProcedureLimit struct {
+	// Procedure is the full Connect procedure name (e.g., "/pkg.Service/Method").
+	Procedure string `koanf:"procedure"`
+
+	// Rate is the number of requests allowed per second.
+	// Zero or negative disables the limit for this procedure.
+	Rate float64 `koanf:"rate"`
+
+	// Burst is the maximum number of requests allowed at once.
+	Burst int `koanf:"burst"`
+}
+
+// Config holds configuration for the rate limit interceptor.
+// Each limit is tracked independently; a request must pass all of them.
+type Config struct {
+	// Procedure limits requests per procedure across all callers.
+	Procedure Limit `koanf:"procedure"`
+
+	// Procedures overrides the Procedure limit for specific procedures.
+	Procedures []ProcedureLimit `koanf:"procedures"`
+
+	// User limits requests per user ID (from ctxutil.UserID).
+	// Requests without a user ID are not limited by this bucket.
+	User Limit `koanf:"user"`
+
+	// Tenant limits requests per tenant ID (from ctxutil.TenantID).
+	// Requests without a tenant ID are not limited by this bucket.
+	Tenant Limit `koanf:"tenant"`
+
+	// IP limits requests per client IP address.
+	IP Limit `koanf:"ip"`
+
+	// IPHeader is the HTTP header to read the client IP from
+	// (e.g., "X-Forwarded-For"). Empty means the peer address of the
+	// connection is used.
+	IPHeader string `koanf:"ip_header"`
+
+	// TrustedProxies is the number of reverse proxies in front of the service
+	// that append the address they received a request from to IPHeader.
+	// The client IP is the entry TrustedProxies positions from the right, so
+	// entries sent by the client itself cannot evade the IP limit. If the
+	// header has fewer entries, the leftmost one is used.
+	// Values below 1 use the rightmost entry.
+	TrustedProxies int `koanf:"trusted_proxies"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+// Per-procedure limits are disabled by default.
+func DefaultConfig() Config {
+	return Config{
+		User:   Limit{Rate: 50, Burst: 100},
+		Tenant: Limit{Rate: 200, Burst: 400},
+		IP:     Limit{Rate: 50, Burst: 100},
+	}
+}
+
+// NewInterceptor creates a Connect RPC interceptor that enforces rate limits.
+// Requests exceeding a limit are rejected with connect.CodeResourceExhausted
+// and a Retry-After metadata value in seconds.
+//
+// Place it after jwtauth so that user and tenant limits can read claims
+// from the context.
+func NewInterceptor(cfg Config) connect.Interceptor {
+	overrides := make(map[string]Limit, len(cfg.Procedures))
+	for _, p := range cfg.Procedures {
+		overrides[p.Procedure] = Limit{Rate: p.Rate, Burst: p.Burst}
+	}
+	return &interceptor{
+		procedure: newLimiter(cfg.Procedure, overrides),
+		user:      newLimiter(cfg.User, nil),
+		tenant:    newLimiter(cfg.Tenant, nil),
+		ip:        newLimiter(cfg.IP, nil),
+		ipHeader:  cfg.IPHeader,
+		proxies:   max(cfg.TrustedProxies, 1),
+		now:       time.Now,
+	}
+}
+
+type interceptor struct {
+	procedure *limiter
+	user      *limiter
+	tenant    *limiter
+	ip        *limiter
+	ipHeader  string
+	proxies   int
+	now       func() time.Time
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		if err := i.allow(ctx, req.Spec().Procedure, i.clientIP(req.Header(), req.Peer())); err != nil {
+			return nil, err
+		}
+		return next(ctx, req)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		if err := i.allow(ctx, conn.Spec().Procedure, i.clientIP(conn.RequestHeader(), conn.Peer())); err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+// check pairs a limiter with the bucket key for the current request.
+type check struct {
+	l   *limiter
+	key string
+}
+
+// allow takes a token from every applicable bucket. If any bucket is empty,
+// tokens already taken are returned and a ResourceExhausted error is produced.
+func (i *interceptor) allow(ctx context.Context, procedure, ip string) error {
+	checks := []check{
+		{i.ip, ip},
+		{i.procedure, procedure},
+	}
+	if userID, ok := ctxutil.UserID(ctx); ok {
+		checks = append(checks, check{i.user, userID})
+	}
+	if tenantID, ok := ctxutil.TenantID(ctx); ok {
+		checks = append(checks, check{i.tenant, tenantID})
+	}
+
+	now := i.now()
+	for n, c := range checks {
+		if c.key == "" {
+			continue
+		}
+		ok, wait := c.l.take(c.key, now)
+		if ok {
+			continue
+		}
+		for _, prev := range checks[:n] {
+			if prev.key != "" {
+				prev.l.refund(prev.key)
+			}
+		}
+		return newRateLimitError(wait)
+	}
+	return nil
+}
+
+// clientIP returns the client IP from the configured header or the peer address.
+// Header entries are counted from the right, where trusted proxies append them.
+func (i *interceptor) clientIP(headers http.Header, peer connect.Peer) string {
+	if i.ipHeader != "" {
+		if values := headers.Values(i.ipHeader); len(values) > 0 {
+			entries := strings.Split(strings.Join(values, ","), ",")
+			return strings.TrimSpace(entries[max(len(entries)-i.proxies, 0)])
+		}
+	}
+	host, _, err := net.SplitHostPort(peer.Addr)
+	if err != nil {
+		return peer.Addr
+	}
+	return host
+}
+
+func newRateLimitError(wait time.Duration) *connect.Error {
+	err := connect.NewError(connect.CodeResourceExhausted, ErrRateLimited)
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	err.Meta().Set(RetryAfterHeader, strconv.Itoa(seconds))
+	return err
+}
+
+// limiter tracks one token bucket per key.
+type limiter struct {
+	def       Limit
+	overrides map[string]Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(def Limit, overrides map[string]Limit) *limiter {
+	return &limiter{
+		def:       def,
+		overrides: overrides,
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// take consumes a token for key. It returns false and the time until the
+// next token becomes available if the bucket is empty.
+func (l *limiter) take(key string, now time.Time) (bool, time.Duration) {
+	limit := l.def
+	if o, ok := l.overrides[key]; ok {
+		limit = o
+	}
+	if limit.Rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		burst := float64(limit.Burst)
+		if burst <= 0 {
+			burst = math.Max(1, math.Ceil(limit.Rate))
+		}
+		b = &bucket{rate: limit.Rate, burst: burst, tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// refund returns a token previously taken for key.
+func (l *limiter) refund(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(b.burst, b.tokens+1)
+	}
+}
+
+// sweep evicts buckets that have refilled completely. Must be called with mu held.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	b.last = now
+}
@@ -0,0 +1,453 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestLimiter_Take(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name      string
+		limit     Limit
+		calls     int
+		advance   time.Duration
+		wantOK    bool
+		wantAtMin time.Duration
+	}{
+		{
+			name:   "disabled limit always allows",
+			limit:  Limit{},
+			calls:  100,
+			wantOK: true,
+		},
+		{
+			name:   "within burst",
+			limit:  Limit{Rate: 1, Burst: 3},
+			calls:  3,
+			wantOK: true,
+		},
+		{
+			name:      "exceeds burst",
+			limit:     Limit{Rate: 1, Burst: 3},
+			calls:     4,
+			wantOK:    false,
+			wantAtMin: time.Second,
+		},
+		{
+			name:    "refills over time",
+			limit:   Limit{Rate: 1, Burst: 3},
+			calls:   4,
+			advance: time.Second,
+			wantOK:  true,
+		},
+		{
+			name:   "burst defaults to rate",
+			limit:  Limit{Rate: 2},
+			calls:  2,
+			wantOK: true,
+		},
+		{
+			name:      "burst default exceeded",
+			limit:     Limit{Rate: 2},
+			calls:     3,
+			wantOK:    false,
+			wantAtMin: 500 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			l := newLimiter(tt.limit, nil)
+			for range tt.calls - 1 {
+				l.take("key", now)
+			}
+
+			ok, wait := l.take("key", now.Add(tt.advance))
+			if ok != tt.wantOK {
+				t.Errorf("take() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !tt.wantOK && wait < tt.wantAtMin {
+				t.Errorf("take() wait = %v, want >= %v", wait, tt.wantAtMin)
+			}
+		})
+	}
+}
+
+func TestLimiter_Overrides(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := newLimiter(Limit{Rate: 1, Burst: 1}, map[string]Limit{
+		"/test.Service/Unlimited": {},
+		"/test.Service/Strict":    {Rate: 1, Burst: 1},
+	})
+
+	for range 5 {
+		if ok, _ := l.take("/test.Service/Unlimited", now); !ok {
+			t.Fatal("expected disabled override to allow request")
+		}
+	}
+
+	if ok, _ := l.take("/test.Service/Strict", now); !ok {
+		t.Fatal("expected first request to be allowed")
+	}
+	if ok, _ := l.take("/test.Service/Strict", now); ok {
+		t.Fatal("expected second request to be rejected")
+	}
+}
+
+func TestLimiter_SweepEvictsIdleBuckets(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	l := newLimiter(Limit{Rate: 10, Burst: 10}, nil)
+
+	l.take("a", now)
+	l.take("b", now)
+	if len(l.buckets) != 2 {
+		t.Fatalf("buckets = %d, want 2", len(l.buckets))
+	}
+
+	l.take("c", now.Add(2*sweepInterval))
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets after sweep = %d, want 1", len(l.buckets))
+	}
+}
+
+func TestInterceptor_Allow(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		claims  *ctxutil.Claims
+		ip      string
+		calls   int
+		wantErr bool
+	}{
+		{
+			name:  "no limits configured",
+			cfg:   Config{},
+			calls: 10,
+		},
+		{
+			name:    "ip limit exceeded",
+			cfg:     Config{IP: Limit{Rate: 1, Burst: 2}},
+			ip:      "10.0.0.1",
+			calls:   3,
+			wantErr: true,
+		},
+		{
+			name:    "procedure limit exceeded",
+			cfg:     Config{Procedure: Limit{Rate: 1, Burst: 2}},
+			calls:   3,
+			wantErr: true,
+		},
+		{
+			name:    "user limit exceeded",
+			cfg:     Config{User: Limit{Rate: 1, Burst: 2}},
+			claims:  &ctxutil.Claims{UserID: "user-1"},
+			calls:   3,
+			wantErr: true,
+		},
+		{
+			name:  "user limit skipped without claims",
+			cfg:   Config{User: Limit{Rate: 1, Burst: 2}},
+			calls: 3,
+		},
+		{
+			name:    "tenant limit exceeded",
+			cfg:     Config{Tenant: Limit{Rate: 1, Burst: 2}},
+			claims:  &ctxutil.Claims{UserID: "user-1", TenantID: "tenant-1"},
+			calls:   3,
+			wantErr: true,
+		},
+		{
+			name:   "tenant limit skipped with empty tenant",
+			cfg:    Config{Tenant: Limit{Rate: 1, Burst: 2}},
+			claims: &ctxutil.Claims{UserID: "user-1"},
+			calls:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i := NewInterceptor(tt.cfg).(*interceptor)
+			fixed := time.Now()
+			i.now = func() time.Time { return fixed }
+
+			ctx := context.Background()
+			if tt.claims != nil {
+				ctx = ctxutil.WithClaims(ctx, *tt.claims)
+			}
+
+			var err error
+			for range tt.calls {
+				err = i.allow(ctx, "/test.Service/Method", tt.ip)
+			}
+
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("allow() error = %v", err)
+				}
+				return
+			}
+
+			var connectErr *connect.Error
+			if !errors.As(err, &connectErr) {
+				t.Fatalf("expected connect.Error, got %T", err)
+			}
+			if connectErr.Code() != connect.CodeResourceExhausted {
+				t.Errorf("code = %v, want %v", connectErr.Code(), connect.CodeResourceExhausted)
+			}
+			if !errors.Is(connectErr.Unwrap(), ErrRateLimited) {
+				t.Errorf("unwrapped error = %v, want %v", connectErr.Unwrap(), ErrRateLimited)
+			}
+			if got := connectErr.Meta().Get(RetryAfterHeader); got != "1" {
+				t.Errorf("Retry-After = %q, want %q", got, "1")
+			}
+		})
+	}
+}
+
+func TestInterceptor_AllowRefundsOnReject(t *testing.T) {
+	t.Parallel()
+
+	i := NewInterceptor(Config{
+		IP:   Limit{Rate: 1, Burst: 5},
+		User: Limit{Rate: 1, Burst: 1},
+	}).(*interceptor)
+	fixed := time.Now()
+	i.now = func() time.Time { return fixed }
+
+	ctx := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: "user-1"})
+	if err := i.allow(ctx, "/test.Service/Method", "10.0.0.1"); err != nil {
+		t.Fatalf("first allow() error = %v", err)
+	}
+	for range 3 {
+		if err := i.allow(ctx, "/test.Service/Method", "10.0.0.1"); err == nil {
+			t.Fatal("expected user limit to reject request")
+		}
+	}
+
+	// The IP bucket must still hold the tokens of the rejected requests.
+	for _, userID := range []string{"user-2", "user-3", "user-4", "user-5"} {
+		other := ctxutil.WithClaims(context.Background(), ctxutil.Claims{UserID: userID})
+		if err := i.allow(other, "/test.Service/Method", "10.0.0.1"); err != nil {
+			t.Fatalf("allow() for other user error = %v", err)
+		}
+	}
+}
+
+func TestInterceptor_ClientIP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		ipHeader string
+		proxies  int
+		headers  http.Header
+		peerAddr string
+		want     string
+	}{
+		{
+			name:     "peer address with port",
+			peerAddr: "192.168.1.10:54321",
+			want:     "192.168.1.10",
+		},
+		{
+			name:     "peer address without port",
+			peerAddr: "192.168.1.10",
+			want:     "192.168.1.10",
+		},
+		{
+			name:     "forwarded header rightmost address",
+			ipHeader: "X-Forwarded-For",
+			headers:  http.Header{"X-Forwarded-For": {"203.0.113.5"}},
+			peerAddr: "10.0.0.1:443",
+			want:     "203.0.113.5",
+		},
+		{
+			name:     "spoofed forwarded entries are ignored",
+			ipHeader: "X-Forwarded-For",
+			headers:  http.Header{"X-Forwarded-For": {"198.51.100.77, 203.0.113.5"}},
+			peerAddr: "10.0.0.1:443",
+			want:     "203.0.113.5",
+		},
+		{
+			name:     "two trusted proxies",
+			ipHeader: "X-Forwarded-For",
+			proxies:  2,
+			headers:  http.Header{"X-Forwarded-For": {"198.51.100.77, 203.0.113.5", "10.0.0.2"}},
+			peerAddr: "10.0.0.1:443",
+			want:     "203.0.113.5",
+		},
+		{
+			name:     "fewer entries than trusted proxies",
+			ipHeader: "X-Forwarded-For",
+			proxies:  3,
+			headers:  http.Header{"X-Forwarded-For": {"203.0.113.5, 10.0.0.2"}},
+			peerAddr: "10.0.0.1:443",
+			want:     "203.0.113.5",
+		},
+		{
+			name:     "forwarded header missing falls back to peer",
+			ipHeader: "X-Forwarded-For",
+			headers:  http.Header{},
+			peerAddr: "10.0.0.1:443",
+			want:     "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			i := NewInterceptor(Config{IPHeader: tt.ipHeader, TrustedProxies: tt.proxies}).(*interceptor)
+			got := i.clientIP(tt.headers, connect.Peer{Addr: tt.peerAddr})
+			if got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	i := NewInterceptor(Config{IP: Limit{Rate: 1, Burst: 1}})
+	calls := 0
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		calls++
+		return &mockResponse{}, nil
+	})
+
+	req := &mockRequest{procedure: "/test.Service/Method", peerAddr: "10.0.0.1:1234"}
+	if _, err := wrapped(context.Background(), req); err != nil {
+		t.Fatalf("first call error = %v", err)
+	}
+	if _, err := wrapped(context.Background(), req); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("second call code = %v, want %v", connect.CodeOf(err), connect.CodeResourceExhausted)
+	}
+	if calls != 1 {
+		t.Errorf("handler calls = %d, want 1", calls)
+	}
+}
+
+func TestInterceptor_WrapUnary_SpoofedForwardedFor(t *testing.T) {
+	t.Parallel()
+
+	i := NewInterceptor(Config{IP: Limit{Rate: 1, Burst: 1}, IPHeader: "X-Forwarded-For"})
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+
+	// The client sends a different address on every call; the proxy appends
+	// the real one.
+	for n, spoofed := range []string{"198.51.100.1", "198.51.100.2"} {
+		req := &mockRequest{
+			procedure: "/test.Service/Method",
+			peerAddr:  "10.0.0.1:1234",
+			header:    http.Header{"X-Forwarded-For": {spoofed + ", 203.0.113.5"}},
+		}
+		_, err := wrapped(context.Background(), req)
+		if n == 0 && err != nil {
+			t.Fatalf("first call error = %v", err)
+		}
+		if n == 1 && connect.CodeOf(err) != connect.CodeResourceExhausted {
+			t.Errorf("second call code = %v, want %v", connect.CodeOf(err), connect.CodeResourceExhausted)
+		}
+	}
+}
+
+func TestInterceptor_WrapUnary_SkipsClient(t *testing.T) {
+	t.Parallel()
+
+	i := NewInterceptor(Config{Procedure: Limit{Rate: 1, Burst: 1}})
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		return &mockResponse{}, nil
+	})
+
+	req := &mockRequest{procedure: "/test.Service/Method", isClient: true}
+	for range 3 {
+		if _, err := wrapped(context.Background(), req); err != nil {
+			t.Fatalf("client call error = %v", err)
+		}
+	}
+}
+
+func TestInterceptor_WrapStreamingHandler(t *testing.T) {
+	t.Parallel()
+
+	i := NewInterceptor(Config{Procedure: Limit{Rate: 1, Burst: 1}})
+	wrapped := i.WrapStreamingHandler(func(_ context.Context, _ connect.StreamingHandlerConn) error {
+		return nil
+	})
+
+	conn := &mockStreamingConn{procedure: "/test.Service/Stream"}
+	if err := wrapped(context.Background(), conn); err != nil {
+		t.Fatalf("first stream error = %v", err)
+	}
+	if err := wrapped(context.Background(), conn); connect.CodeOf(err) != connect.CodeResourceExhausted {
+		t.Errorf("second stream code = %v, want %v", connect.CodeOf(err), connect.CodeResourceExhausted)
+	}
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	procedure string
+	peerAddr  string
+	header    http.Header
+	isClient  bool
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return connect.Spec{Procedure: r.procedure, IsClient: r.isClient}
+}
+
+func (r *mockRequest) Header() http.Header {
+	if r.header == nil {
+		return http.Header{}
+	}
+	return r.header
+}
+
+func (r *mockRequest) Peer() connect.Peer {
+	return connect.Peer{Addr: r.peerAddr}
+}
+
+type mockResponse struct {
+	connect.AnyResponse
+}
+
+type mockStreamingConn struct {
+	connect.StreamingHandlerConn
+	procedure string
+}
+
+func (c *mockStreamingConn) Spec() connect.Spec {
+	return connect.Spec{Procedure: c.procedure}
+}
+
+func (c *mockStreamingConn) RequestHeader() http.Header {
+	return http.Header{}
+}
+
+func (c *mockStreamingConn) Peer() connect.Peer {
+	return connect.Peer{}
+}
@@ -3,29 +3,47 @@ package recovery
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"log/slog"
-	"runtime"
 
 	"connectrpc.com/connect"
 
-	"github.com/deepworx/go-utils/pkg/ctxutil"
+	"github.com/deepworx/go-utils/pkg/connectrpc/errors"
+	"github.com/deepworx/go-utils/pkg/connectrpc/report"
 )
 
 // NewInterceptor creates a Connect RPC interceptor that recovers from panics.
-// It catches panics in handlers, logs them with stack traces, and returns
-// a connect.CodeInternal error to the client.
-func NewInterceptor() connect.Interceptor {
-	return &interceptor{}
+// It catches panics in handlers, logs them with stack traces, reports them
+// to the reporter set with WithReporter, and returns a connect.CodeInternal
+// error to the client. The error and the log record carry the same request
+// and trace IDs (see errors.NewInternalError).
+func NewInterceptor(opts ...Option) connect.Interceptor {
+	i := &interceptor{}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// Option configures the interceptor.
+type Option func(*interceptor)
+
+// WithReporter reports every recovered panic.
+func WithReporter(reporter report.Reporter) Option {
+	return func(i *interceptor) {
+		i.reporter = reporter
+	}
 }
 
-type interceptor struct{}
+type interceptor struct {
+	reporter report.Reporter
+}
 
 func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
 	return func(ctx context.Context, req connect.AnyRequest) (resp connect.AnyResponse, err error) {
 		defer func() {
 			if r := recover(); r != nil {
-				err = recoverPanic(ctx, req.Spec().Procedure, r)
+				err = recoverPanic(ctx, req.Spec().Procedure, r, i.reporter)
 			}
 		}()
 		return next(ctx, req)
@@ -40,30 +58,32 @@ func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) co
 	return func(ctx context.Context, conn connect.StreamingHandlerConn) (err error) {
 		defer func() {
 			if r := recover(); r != nil {
-				err = recoverPanic(ctx, conn.Spec().Procedure, r)
+				err = recoverPanic(ctx, conn.Spec().Procedure, r, i.reporter)
 			}
 		}()
 		return next(ctx, conn)
 	}
 }
 
-func recoverPanic(ctx context.Context, procedure string, r any) *connect.Error {
-	const stackSize = 4096
-	stack := make([]byte, stackSize)
-	n := runtime.Stack(stack, false)
-	stackStr := string(stack[:n])
+func recoverPanic(ctx context.Context, procedure string, r any, reporter report.Reporter) *connect.Error {
+	panicErr := fmt.Errorf("panic: %v", r)
+	if err, ok := r.(error); ok {
+		panicErr = fmt.Errorf("panic: %w", err)
+	}
+	rep := report.New(ctx, procedure, panicErr)
+	rep.Panic = r
 
-	attrs := []any{
+	attrs := append([]any{
 		slog.String("procedure", procedure),
 		slog.Any("panic", r),
-		slog.String("stack", stackStr),
-	}
-
-	if reqID, ok := ctxutil.RequestID(ctx); ok {
-		attrs = append(attrs, slog.String("request_id", reqID))
-	}
+		slog.String("stack", string(rep.Stack)),
+	}, errors.ReferenceAttrs(ctx)...)
 
 	slog.ErrorContext(ctx, "panic recovered", attrs...)
 
-	return connect.NewError(connect.CodeInternal, errors.New("internal error"))
+	if reporter != nil {
+		reporter.Report(ctx, rep)
+	}
+
+	return errors.NewInternalError(ctx)
 }
@@ -10,6 +10,7 @@ import (
 
 	"connectrpc.com/connect"
 
+	"github.com/deepworx/go-utils/pkg/connectrpc/report"
 	"github.com/deepworx/go-utils/pkg/ctxutil"
 )
 
@@ -45,32 +46,36 @@ func (h *mockHandler) getRecords() []slog.Record {
 
 func TestRecoverPanic(t *testing.T) {
 	tests := []struct {
-		name       string
-		panicValue any
-		procedure  string
-		requestID  string
-		wantPanic  string
+		name        string
+		panicValue  any
+		procedure   string
+		requestID   string
+		wantPanic   string
+		wantMessage string
 	}{
 		{
-			name:       "panic with string",
-			panicValue: "something went wrong",
-			procedure:  "/test.Service/Method",
-			requestID:  "req-123",
-			wantPanic:  "something went wrong",
+			name:        "panic with string",
+			panicValue:  "something went wrong",
+			procedure:   "/test.Service/Method",
+			requestID:   "req-123",
+			wantPanic:   "something went wrong",
+			wantMessage: "internal error (request_id: req-123)",
 		},
 		{
-			name:       "panic with error",
-			panicValue: errors.New("error panic"),
-			procedure:  "/test.Service/Error",
-			requestID:  "req-456",
-			wantPanic:  "error panic",
+			name:        "panic with error",
+			panicValue:  errors.New("error panic"),
+			procedure:   "/test.Service/Error",
+			requestID:   "req-456",
+			wantPanic:   "error panic",
+			wantMessage: "internal error (request_id: req-456)",
 		},
 		{
-			name:       "panic with int",
-			panicValue: 42,
-			procedure:  "/test.Service/Int",
-			requestID:  "",
-			wantPanic:  "42",
+			name:        "panic with int",
+			panicValue:  42,
+			procedure:   "/test.Service/Int",
+			requestID:   "",
+			wantPanic:   "42",
+			wantMessage: "internal error",
 		},
 	}
 
@@ -86,7 +91,7 @@ func TestRecoverPanic(t *testing.T) {
 				ctx = ctxutil.WithRequestID(ctx, tt.requestID)
 			}
 
-			err := recoverPanic(ctx, tt.procedure, tt.panicValue)
+			err := recoverPanic(ctx, tt.procedure, tt.panicValue, nil)
 
 			if err == nil {
 				t.Fatal("expected error, got nil")
@@ -94,8 +99,8 @@ func TestRecoverPanic(t *testing.T) {
 			if err.Code() != connect.CodeInternal {
 				t.Errorf("code = %v, want %v", err.Code(), connect.CodeInternal)
 			}
-			if err.Message() != "internal error" {
-				t.Errorf("message = %q, want %q", err.Message(), "internal error")
+			if err.Message() != tt.wantMessage {
+				t.Errorf("message = %q, want %q", err.Message(), tt.wantMessage)
 			}
 
 			records := mock.getRecords()
@@ -175,6 +180,39 @@ func TestInterceptor_WrapUnary_Panic(t *testing.T) {
 	}
 }
 
+func TestInterceptor_Reporter(t *testing.T) {
+	mock := &mockHandler{}
+	oldLogger := slog.Default()
+	slog.SetDefault(slog.New(mock))
+	t.Cleanup(func() { slog.SetDefault(oldLogger) })
+
+	var reports []report.Report
+	interceptor := NewInterceptor(WithReporter(report.ReporterFunc(func(_ context.Context, r report.Report) {
+		reports = append(reports, r)
+	})))
+	cause := errors.New("nil map")
+	wrapped := interceptor.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		panic(cause)
+	})
+
+	ctx := ctxutil.WithRequestID(context.Background(), "req-1")
+	_, _ = wrapped(ctx, &mockRequest{procedure: "/test.Service/Unary"})
+
+	if len(reports) != 1 {
+		t.Fatalf("reported %d panics, want 1", len(reports))
+	}
+	r := reports[0]
+	if r.Panic != cause || !errors.Is(r.Err, cause) || r.Err.Error() != "panic: nil map" {
+		t.Errorf("report = {Panic: %v, Err: %v}, want panic wrapping %v", r.Panic, r.Err, cause)
+	}
+	if r.Procedure != "/test.Service/Unary" || r.RequestID != "req-1" {
+		t.Errorf("report = {Procedure: %q, RequestID: %q}", r.Procedure, r.RequestID)
+	}
+	if !strings.Contains(string(r.Stack), "TestInterceptor_Reporter") {
+		t.Error("stack trace should contain the panicking function")
+	}
+}
+
 func TestInterceptor_WrapUnary_NoPanic(t *testing.T) {
 	mock := &mockHandler{}
 	oldLogger := slog.Default()
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// defaultDedupWindow is used by NewDedupReporter for non-positive windows.
+const defaultDedupWindow = time.Minute
+
+// numbers matches digit runs, which commonly hold IDs, in error messages.
+var numbers = regexp.MustCompile(`[0-9]+`)
+
+// DedupReporter passes on the first report of each fingerprint per window
+// and drops identical reports until the window has passed. The next report
+// passed on carries the number of dropped reports in Suppressed.
+type DedupReporter struct {
+	next   Reporter
+	window time.Duration
+	now    func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]*dedupEntry
+	nextPrune time.Time
+}
+
+// dedupEntry tracks one fingerprint.
+type dedupEntry struct {
+	until      time.Time
+	suppressed int
+}
+
+// NewDedupReporter creates a reporter that deduplicates reports for next.
+// window defaults to one minute if not positive.
+func NewDedupReporter(next Reporter, window time.Duration) *DedupReporter {
+	if window <= 0 {
+		window = defaultDedupWindow
+	}
+	return &DedupReporter{
+		next:   next,
+		window: window,
+		now:    time.Now,
+		seen:   make(map[string]*dedupEntry),
+	}
+}
+
+// Report passes r to the next reporter unless an identical report was
+// passed on within the window.
+func (d *DedupReporter) Report(ctx context.Context, r Report) {
+	r.Fingerprint = Fingerprint(r)
+	now := d.now()
+
+	d.mu.Lock()
+	entry, ok := d.seen[r.Fingerprint]
+	if ok && now.Before(entry.until) {
+		entry.suppressed++
+		d.mu.Unlock()
+		return
+	}
+	if ok {
+		r.Suppressed = entry.suppressed
+	}
+	d.seen[r.Fingerprint] = &dedupEntry{until: now.Add(d.window)}
+	d.prune(now)
+	d.mu.Unlock()
+
+	d.next.Report(ctx, r)
+}
+
+// prune drops entries whose window has passed and that dropped no reports,
+// at most once per window. Suppressed counts are kept for one more window
+// for the next report to carry. Callers must hold d.mu.
+func (d *DedupReporter) prune(now time.Time) {
+	if now.Before(d.nextPrune) {
+		return
+	}
+	d.nextPrune = now.Add(d.window)
+	for fp, entry := range d.seen {
+		if !now.Before(entry.until) && (entry.suppressed == 0 || !now.Before(entry.until.Add(d.window))) {
+			delete(d.seen, fp)
+		}
+	}
+}
+
+// Fingerprint identifies reports of the same problem: the procedure, the
+// error or panic value type, the message with numbers removed, and the
+// functions on the stack. Request IDs, goroutine IDs and memory addresses
+// do not affect it.
+func Fingerprint(r Report) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", r.Procedure)
+	if r.Panic != nil {
+		fmt.Fprintf(h, "%T\x00", r.Panic)
+	} else {
+		fmt.Fprintf(h, "%T\x00", r.Err)
+	}
+	if r.Err != nil {
+		h.Write(numbers.ReplaceAll([]byte(r.Err.Error()), []byte("#")))
+	}
+	h.Write([]byte{0})
+	for _, fn := range stackFunctions(r.Stack) {
+		h.Write(fn)
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// stackFunctions returns the function names of a runtime.Stack trace,
+// without the goroutine header, arguments, file positions and creators.
+func stackFunctions(stack []byte) [][]byte {
+	var fns [][]byte
+	for i, line := range bytes.Split(stack, []byte{'\n'}) {
+		if i == 0 || len(line) == 0 || line[0] == '\t' || bytes.HasPrefix(line, []byte("created by ")) {
+			continue
+		}
+		if idx := bytes.LastIndexByte(line, '('); idx > 0 {
+			line = line[:idx]
+		}
+		fns = append(fns, line)
+	}
+	return fns
+}
+
+// compile-time check
+var _ Reporter = (*DedupReporter)(nil)
@@ -0,0 +1,96 @@
+// Package report provides error reporting for Connect RPC services.
+//
+// The errors and recovery interceptors replace unexpected errors and panics
+// with a sanitized "internal error" before it reaches the client. A Reporter
+// receives the original error together with its request context, so it can
+// be recorded in traces or forwarded to an error tracking service.
+package report
+
+import (
+	"context"
+	"runtime"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+// stackSize bounds the captured stack trace.
+const stackSize = 4096
+
+// Report describes a sanitized error or a recovered panic.
+type Report struct {
+	// Err is the original error. For panics it describes the panic value
+	// and wraps it if the value is an error.
+	Err error
+
+	// Panic is the recovered value; nil for errors.
+	Panic any
+
+	// Procedure is the Connect procedure, e.g. "/acme.user.v1.UserService/GetUser".
+	Procedure string
+
+	// RequestID is the request ID from ctxutil.RequestID; empty if none.
+	RequestID string
+
+	// Claims are the caller's claims from ctxutil.GetClaims; nil if unauthenticated.
+	Claims *ctxutil.Claims
+
+	// Stack is the goroutine stack trace, truncated to 4 KiB. For panics it
+	// includes the panicking frames; for errors it is the stack where the
+	// error was sanitized.
+	Stack []byte
+
+	// SpanContext is the span context of the request; invalid if not traced.
+	SpanContext trace.SpanContext
+
+	// Fingerprint groups identical reports. Set by DedupReporter.
+	Fingerprint string
+
+	// Suppressed is the number of identical reports dropped by DedupReporter
+	// since the last one passed on.
+	Suppressed int
+}
+
+// Reporter receives reports of sanitized errors and recovered panics.
+// Report is called synchronously on the request path; implementations must
+// be safe for concurrent use and should hand slow work off to a goroutine.
+type Reporter interface {
+	Report(ctx context.Context, r Report)
+}
+
+// ReporterFunc adapts a function to a Reporter.
+type ReporterFunc func(ctx context.Context, r Report)
+
+// Report calls f(ctx, r).
+func (f ReporterFunc) Report(ctx context.Context, r Report) {
+	f(ctx, r)
+}
+
+// New creates a Report of err for procedure with the request ID, claims and
+// span context of ctx and the stack trace of the calling goroutine.
+func New(ctx context.Context, procedure string, err error) Report {
+	stack := make([]byte, stackSize)
+	n := runtime.Stack(stack, false)
+
+	r := Report{
+		Err:         err,
+		Procedure:   procedure,
+		Stack:       stack[:n],
+		SpanContext: trace.SpanContextFromContext(ctx),
+	}
+	r.RequestID, _ = ctxutil.RequestID(ctx)
+	if claims, ok := ctxutil.GetClaims(ctx); ok {
+		r.Claims = &claims
+	}
+	return r
+}
+
+// Multi returns a Reporter that passes every report to all reporters in order.
+func Multi(reporters ...Reporter) Reporter {
+	return ReporterFunc(func(ctx context.Context, r Report) {
+		for _, reporter := range reporters {
+			reporter.Report(ctx, r)
+		}
+	})
+}
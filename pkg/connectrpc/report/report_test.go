@@ -0,0 +1,206 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/deepworx/go-utils/pkg/ctxutil"
+)
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tp := sdktrace.NewTracerProvider()
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "handler")
+	defer span.End()
+	ctx = ctxutil.WithRequestID(ctx, "req-1")
+	ctx = ctxutil.WithClaims(ctx, ctxutil.Claims{UserID: "user-1"})
+	err := errors.New("boom")
+
+	r := New(ctx, "/test.Service/Method", err)
+
+	if r.Err != err || r.Procedure != "/test.Service/Method" || r.RequestID != "req-1" {
+		t.Errorf("New() = %+v", r)
+	}
+	if r.Claims == nil || r.Claims.UserID != "user-1" {
+		t.Errorf("Claims = %+v, want user-1", r.Claims)
+	}
+	if r.SpanContext.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("TraceID = %v, want %v", r.SpanContext.TraceID(), span.SpanContext().TraceID())
+	}
+	if !strings.Contains(string(r.Stack), "TestNew") {
+		t.Errorf("Stack does not contain the caller:\n%s", r.Stack)
+	}
+
+	if r := New(context.Background(), "/test.Service/Method", err); r.Claims != nil || r.RequestID != "" || r.SpanContext.IsValid() {
+		t.Errorf("New() without request context = %+v", r)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	reporter := Multi(
+		ReporterFunc(func(_ context.Context, r Report) { got = append(got, "a:"+r.Procedure) }),
+		ReporterFunc(func(_ context.Context, r Report) { got = append(got, "b:"+r.Procedure) }),
+	)
+	reporter.Report(context.Background(), Report{Procedure: "/p"})
+
+	if strings.Join(got, ",") != "a:/p,b:/p" {
+		t.Errorf("reported %v, want both reporters in order", got)
+	}
+}
+
+func TestSpanReporter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		activeSpan bool
+		panicValue any
+		wantSpans  []string
+	}{
+		{name: "active span", activeSpan: true, wantSpans: []string{"handler"}},
+		{name: "no active span", panicValue: "boom", wantSpans: []string{"/test.Service/Method"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+			ctx := context.Background()
+			var span trace.Span
+			if tt.activeSpan {
+				ctx, span = tp.Tracer("test").Start(ctx, "handler")
+			}
+
+			r := New(ctxutil.WithRequestID(ctx, "req-1"), "/test.Service/Method", errors.New("db down"))
+			r.Panic = tt.panicValue
+			newSpanReporter(tp).Report(ctx, r)
+			if span != nil {
+				span.End()
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != len(tt.wantSpans) || spans[0].Name() != tt.wantSpans[0] {
+				t.Fatalf("ended spans = %d, want %v", len(spans), tt.wantSpans)
+			}
+			got := spans[0]
+			if got.Status().Code != codes.Error || got.Status().Description != "db down" {
+				t.Errorf("status = %+v, want error db down", got.Status())
+			}
+			if len(got.Events()) != 1 || got.Events()[0].Name != "exception" {
+				t.Fatalf("events = %+v, want one exception", got.Events())
+			}
+			attrs := attribute.NewSet(got.Events()[0].Attributes...)
+			if v, _ := attrs.Value("exception.message"); v.AsString() != "db down" {
+				t.Errorf("exception.message = %q, want db down", v.AsString())
+			}
+			if v, _ := attrs.Value("request_id"); v.AsString() != "req-1" {
+				t.Errorf("request_id = %q, want req-1", v.AsString())
+			}
+			if v, _ := attrs.Value("exception.stacktrace"); !strings.Contains(v.AsString(), "TestSpanReporter") {
+				t.Error("exception.stacktrace does not contain the caller")
+			}
+			if _, ok := attrs.Value("exception.panic"); ok != (tt.panicValue != nil) {
+				t.Errorf("exception.panic set = %v, want %v", ok, tt.panicValue != nil)
+			}
+		})
+	}
+}
+
+func TestDedupReporter(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var forwarded []Report
+	next := ReporterFunc(func(_ context.Context, r Report) {
+		mu.Lock()
+		defer mu.Unlock()
+		forwarded = append(forwarded, r)
+	})
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := NewDedupReporter(next, time.Minute)
+	d.now = func() time.Time { return now }
+
+	report := func(procedure string, id int) {
+		d.Report(context.Background(), Report{Procedure: procedure, Err: fmt.Errorf("get user %d: timeout", id)})
+	}
+
+	report("/users.v1.UserService/GetUser", 1)
+	report("/users.v1.UserService/GetUser", 2)
+	report("/users.v1.UserService/GetUser", 3)
+	report("/users.v1.UserService/ListUsers", 4)
+	if len(forwarded) != 2 {
+		t.Fatalf("forwarded %d reports within the window, want 2", len(forwarded))
+	}
+	if forwarded[0].Fingerprint == "" || forwarded[0].Fingerprint == forwarded[1].Fingerprint {
+		t.Errorf("fingerprints = %q, %q, want distinct", forwarded[0].Fingerprint, forwarded[1].Fingerprint)
+	}
+
+	now = now.Add(time.Minute)
+	report("/users.v1.UserService/GetUser", 5)
+	if len(forwarded) != 3 || forwarded[2].Suppressed != 2 {
+		t.Fatalf("after window: forwarded %d, last Suppressed = %d, want 3 and 2", len(forwarded), forwarded[len(forwarded)-1].Suppressed)
+	}
+	if forwarded[2].Fingerprint != forwarded[0].Fingerprint {
+		t.Error("fingerprint changed across windows")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	stack := func(goroutine int, addr string) []byte {
+		return fmt.Appendf(nil, "goroutine %d [running]:\nmain.handler(%s)\n\t/src/main.go:10 +0x1d\ncreated by net/http.(*Server).Serve in goroutine %d\n", goroutine, addr, goroutine-1)
+	}
+	base := Report{Procedure: "/p", Err: errors.New("user 42 not loaded"), Stack: stack(7, "0xc000010000")}
+
+	tests := []struct {
+		name     string
+		other    Report
+		wantSame bool
+	}{
+		{
+			name:     "different ids, goroutines and addresses",
+			other:    Report{Procedure: "/p", Err: errors.New("user 1337 not loaded"), Stack: stack(99, "0xc000abcdef")},
+			wantSame: true,
+		},
+		{name: "different procedure", other: Report{Procedure: "/q", Err: base.Err, Stack: base.Stack}},
+		{name: "different message", other: Report{Procedure: "/p", Err: errors.New("user 42 deleted"), Stack: base.Stack}},
+		{name: "panic", other: Report{Procedure: "/p", Err: base.Err, Panic: "x", Stack: base.Stack}},
+		{
+			name:  "different stack",
+			other: Report{Procedure: "/p", Err: base.Err, Stack: []byte("goroutine 7 [running]:\nmain.other()\n")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if same := Fingerprint(base) == Fingerprint(tt.other); same != tt.wantSame {
+				t.Errorf("same fingerprint = %v, want %v", same, tt.wantSame)
+			}
+		})
+	}
+}
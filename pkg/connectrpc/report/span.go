@@ -0,0 +1,55 @@
+package report
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/deepworx/go-utils/pkg/connectrpc/report"
+
+// SpanReporter records reports as exception events on the active span.
+type SpanReporter struct {
+	tracer trace.Tracer
+}
+
+// NewSpanReporter creates a reporter using the global tracer provider.
+func NewSpanReporter() *SpanReporter {
+	return newSpanReporter(otel.GetTracerProvider())
+}
+
+func newSpanReporter(tp trace.TracerProvider) *SpanReporter {
+	return &SpanReporter{tracer: tp.Tracer(tracerName)}
+}
+
+// Report adds an "exception" event with the error, its stack trace and the
+// request ID to the span in ctx and marks the span as failed.
+//
+// Panics are usually recovered outside the server span; if ctx has no
+// recording span, a span named after the procedure is started for the report.
+func (s *SpanReporter) Report(ctx context.Context, r Report) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		_, span = s.tracer.Start(ctx, r.Procedure)
+		defer span.End()
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("exception.stacktrace", string(r.Stack)),
+	}
+	if r.RequestID != "" {
+		attrs = append(attrs, attribute.String("request_id", r.RequestID))
+	}
+	if r.Panic != nil {
+		attrs = append(attrs, attribute.Bool("exception.panic", true))
+	}
+
+	span.RecordError(r.Err, trace.WithAttributes(attrs...))
+	span.SetStatus(codes.Error, r.Err.Error())
+}
+
+// compile-time check
+var _ Reporter = (*SpanReporter)(nil)
@@ -69,6 +69,62 @@ func (i *interceptor) ensureRequestID(ctx context.Context, headers http.Header)
 	return ctxutil.WithRequestID(ctx, id)
 }
 
+// NewClientInterceptor creates a Connect RPC client interceptor that forwards
+// the request ID from ctxutil.RequestID in the configured header, so that
+// downstream services log the same ID. Calls made without a request ID in the
+// context get a newly generated one, which is also stored in the context.
+// A header set explicitly on the request takes precedence.
+func NewClientInterceptor(cfg Config) connect.Interceptor {
+	headerName := cfg.HeaderName
+	if headerName == "" {
+		headerName = "X-Request-ID"
+	}
+	return &clientInterceptor{headerName: headerName}
+}
+
+type clientInterceptor struct {
+	headerName string
+}
+
+func (i *clientInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if !req.Spec().IsClient {
+			return next(ctx, req)
+		}
+		ctx = i.forwardRequestID(ctx, req.Header())
+		return next(ctx, req)
+	}
+}
+
+func (i *clientInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		headers := http.Header{}
+		ctx = i.forwardRequestID(ctx, headers)
+		conn := next(ctx, spec)
+		conn.RequestHeader().Set(i.headerName, headers.Get(i.headerName))
+		return conn
+	}
+}
+
+func (i *clientInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// forwardRequestID sets the request ID header from ctx, generating an ID if ctx has none.
+func (i *clientInterceptor) forwardRequestID(ctx context.Context, headers http.Header) context.Context {
+	if id := headers.Get(i.headerName); id != "" {
+		return ctxutil.WithRequestID(ctx, id)
+	}
+
+	id, ok := ctxutil.RequestID(ctx)
+	if !ok {
+		id = generateID()
+		ctx = ctxutil.WithRequestID(ctx, id)
+	}
+	headers.Set(i.headerName, id)
+	return ctx
+}
+
 func generateID() string {
 	id := uuid.New()
 	return hex.EncodeToString(id[:])
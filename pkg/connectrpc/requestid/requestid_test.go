@@ -186,14 +186,93 @@ func TestInterceptor_WrapStreamingClient_PassThrough(t *testing.T) {
 	}
 }
 
+func TestClientInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		ctxID      string
+		headerID   string
+		isClient   bool
+		wantHeader string
+	}{
+		{name: "forwards context ID", ctxID: "ctx-id", isClient: true, wantHeader: "ctx-id"},
+		{name: "explicit header wins", ctxID: "ctx-id", headerID: "explicit-id", isClient: true, wantHeader: "explicit-id"},
+		{name: "generates missing ID", isClient: true},
+		{name: "handler passes through", ctxID: "ctx-id", isClient: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tt.ctxID != "" {
+				ctx = ctxutil.WithRequestID(ctx, tt.ctxID)
+			}
+			headers := http.Header{}
+			if tt.headerID != "" {
+				headers.Set("X-Correlation-ID", tt.headerID)
+			}
+
+			var capturedID string
+			wrapped := NewClientInterceptor(Config{HeaderName: "X-Correlation-ID"}).WrapUnary(
+				func(ctx context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+					capturedID, _ = ctxutil.RequestID(ctx)
+					return &mockResponse{}, nil
+				})
+
+			req := &mockRequest{procedure: "/test.Service/Method", headers: headers, isClient: tt.isClient}
+			if _, err := wrapped(ctx, req); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got := headers.Get("X-Correlation-ID")
+			switch {
+			case !tt.isClient:
+				if got != "" {
+					t.Errorf("header = %q, want none on handler", got)
+				}
+			case tt.wantHeader == "":
+				if len(got) != 32 {
+					t.Errorf("generated header length = %d, want 32", len(got))
+				}
+			case got != tt.wantHeader:
+				t.Errorf("header = %q, want %q", got, tt.wantHeader)
+			}
+			if tt.isClient && capturedID != got {
+				t.Errorf("context ID = %q, want header value %q", capturedID, got)
+			}
+		})
+	}
+}
+
+func TestClientInterceptor_WrapStreamingClient(t *testing.T) {
+	t.Parallel()
+
+	conn := &mockClientConn{headers: http.Header{}}
+	wrapped := NewClientInterceptor(Config{}).WrapStreamingClient(
+		func(_ context.Context, _ connect.Spec) connect.StreamingClientConn {
+			return conn
+		})
+
+	ctx := ctxutil.WithRequestID(context.Background(), "stream-id")
+	wrapped(ctx, connect.Spec{IsClient: true})
+
+	if got := conn.headers.Get("X-Request-ID"); got != "stream-id" {
+		t.Errorf("header = %q, want %q", got, "stream-id")
+	}
+}
+
 type mockRequest struct {
 	connect.AnyRequest
 	procedure string
 	headers   http.Header
+	isClient  bool
 }
 
 func (r *mockRequest) Spec() connect.Spec {
-	return connect.Spec{Procedure: r.procedure}
+	return connect.Spec{Procedure: r.procedure, IsClient: r.isClient}
 }
 
 func (r *mockRequest) Header() http.Header {
@@ -217,3 +296,12 @@ func (c *mockStreamingConn) Spec() connect.Spec {
 func (c *mockStreamingConn) RequestHeader() http.Header {
 	return c.headers
 }
+
+type mockClientConn struct {
+	connect.StreamingClientConn
+	headers http.Header
+}
+
+func (c *mockClientConn) RequestHeader() http.Header {
+	return c.headers
+}
@@ -0,0 +1,415 @@
+// Package retry provides a retrying Connect RPC client interceptor.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/ratelimit"
+)
+
+const meterName = "github.com/deepworx/go-utils/pkg/connectrpc/retry"
+
+// Sentinel errors for invalid configuration.
+var (
+	// ErrInvalidMaxAttempts is returned when MaxAttempts is less than 1.
+	ErrInvalidMaxAttempts = errors.New("max_attempts must be at least 1")
+
+	// ErrInvalidBackoff is returned when InitialBackoff or MaxBackoff is negative,
+	// or MaxBackoff is smaller than InitialBackoff.
+	ErrInvalidBackoff = errors.New("backoff must be non-negative and max_backoff >= initial_backoff")
+
+	// ErrInvalidMultiplier is returned when Multiplier is less than 1.
+	ErrInvalidMultiplier = errors.New("multiplier must be at least 1")
+
+	// ErrInvalidJitter is returned when Jitter is outside [0, 1].
+	ErrInvalidJitter = errors.New("jitter must be between 0 and 1")
+
+	// ErrInvalidBudget is returned when the budget tokens or ratio are negative.
+	ErrInvalidBudget = errors.New("budget tokens and ratio must be non-negative")
+)
+
+// Reasons for not retrying a failed attempt, recorded on span events.
+const (
+	reasonExhausted = "attempts_exhausted"
+	reasonThrottled = "budget_exhausted"
+	reasonDeadline  = "deadline"
+	reasonCanceled  = "canceled"
+)
+
+// Budget throttles retries across all procedures of a client, like gRPC retry
+// throttling. The budget starts full at Tokens; every retryable failure costs
+// one token and every success refunds Ratio tokens. Retries are skipped while
+// the budget is at or below half of Tokens, so a failing backend is not hit
+// with a multiple of its normal load.
+type Budget struct {
+	// Tokens is the budget size. Zero disables the budget.
+	// Default: 10
+	Tokens float64 `koanf:"tokens"`
+
+	// Ratio is the number of tokens refunded per successful call.
+	// Default: 0.1
+	Ratio float64 `koanf:"ratio"`
+}
+
+// Config holds configuration for the retry interceptor.
+type Config struct {
+	// MaxAttempts is the maximum number of attempts per call, including the first.
+	// Default: 3
+	MaxAttempts int `koanf:"max_attempts"`
+
+	// InitialBackoff is the delay before the first retry.
+	// Default: 100ms
+	InitialBackoff time.Duration `koanf:"initial_backoff"`
+
+	// MaxBackoff caps the delay between attempts.
+	// Default: 5s
+	MaxBackoff time.Duration `koanf:"max_backoff"`
+
+	// Multiplier grows the delay after each retry.
+	// Default: 2
+	Multiplier float64 `koanf:"multiplier"`
+
+	// Jitter is the fraction of each delay that is randomized, in [0, 1].
+	// A delay d is drawn uniformly from [d*(1-Jitter), d].
+	// Default: 0.2
+	Jitter float64 `koanf:"jitter"`
+
+	// Codes lists additional retryable codes (e.g., "aborted").
+	// Unavailable and ResourceExhausted are always retried.
+	Codes []connect.Code `koanf:"codes"`
+
+	// Budget limits retries across all calls of the client.
+	Budget Budget `koanf:"budget"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+func DefaultConfig() Config {
+	return Config{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		Budget: Budget{
+			Tokens: 10,
+			Ratio:  0.1,
+		},
+	}
+}
+
+// Validate checks that all fields are within range.
+// Returns nil if configuration is valid.
+func (c Config) Validate() error {
+	if c.MaxAttempts < 1 {
+		return ErrInvalidMaxAttempts
+	}
+	if c.InitialBackoff < 0 || c.MaxBackoff < c.InitialBackoff {
+		return ErrInvalidBackoff
+	}
+	if c.Multiplier < 1 {
+		return ErrInvalidMultiplier
+	}
+	if c.Jitter < 0 || c.Jitter > 1 {
+		return ErrInvalidJitter
+	}
+	if c.Budget.Tokens < 0 || c.Budget.Ratio < 0 {
+		return ErrInvalidBudget
+	}
+	return nil
+}
+
+// NewInterceptor creates a Connect RPC client interceptor that retries failed
+// unary calls to idempotent procedures, i.e. procedures whose connect.Spec has
+// IdempotencyNoSideEffects or IdempotencyIdempotent. Calls are retried on
+// CodeUnavailable, CodeResourceExhausted, and Config.Codes, with exponential
+// backoff and jitter. A Retry-After value in the error metadata (as sent by
+// the ratelimit interceptor) extends the delay. No retry is attempted if the
+// delay would outlast the context deadline; the last error is returned instead.
+//
+// Each failed attempt adds a span event to the current span, so place the
+// interceptor after otelconnect. Attempts are recorded through the global
+// OpenTelemetry MeterProvider:
+//   - rpc.client.retry.attempts: attempts by procedure, status, and whether it was a retry
+//   - rpc.client.retry.throttled: retries skipped because the budget was exhausted
+//
+// Every attempt starts from the caller's request headers, so headers set by
+// inner interceptors, such as tokensource, are set afresh for each attempt.
+// Streaming calls are not retried.
+// Returns error if cfg is invalid or metric instruments cannot be created.
+func NewInterceptor(cfg Config) (connect.Interceptor, error) {
+	return newInterceptor(cfg, otel.GetMeterProvider())
+}
+
+func newInterceptor(cfg Config, mp metric.MeterProvider) (*interceptor, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create retry interceptor: %w", err)
+	}
+
+	meter := mp.Meter(meterName)
+
+	attempts, err := meter.Int64Counter(
+		"rpc.client.retry.attempts",
+		metric.WithDescription("Number of attempts of retryable RPC calls"),
+		metric.WithUnit("{attempt}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create attempts metric: %w", err)
+	}
+
+	throttled, err := meter.Int64Counter(
+		"rpc.client.retry.throttled",
+		metric.WithDescription("Number of retries skipped because the retry budget was exhausted"),
+		metric.WithUnit("{retry}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create throttled metric: %w", err)
+	}
+
+	codes := []connect.Code{connect.CodeUnavailable, connect.CodeResourceExhausted}
+	for _, code := range cfg.Codes {
+		if !slices.Contains(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+
+	i := &interceptor{
+		maxAttempts:    cfg.MaxAttempts,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+		multiplier:     cfg.Multiplier,
+		jitter:         cfg.Jitter,
+		codes:          codes,
+		attempts:       attempts,
+		throttled:      throttled,
+		sleep:          sleep,
+		random:         rand.Float64,
+	}
+	if cfg.Budget.Tokens > 0 {
+		i.budget = &budget{
+			tokens:    cfg.Budget.Tokens,
+			maxTokens: cfg.Budget.Tokens,
+			ratio:     cfg.Budget.Ratio,
+		}
+	}
+	return i, nil
+}
+
+type interceptor struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	multiplier     float64
+	jitter         float64
+	codes          []connect.Code
+	budget         *budget
+
+	attempts  metric.Int64Counter
+	throttled metric.Int64Counter
+
+	sleep  func(ctx context.Context, d time.Duration) error
+	random func() float64
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		spec := req.Spec()
+		if !spec.IsClient || !idempotent(spec.IdempotencyLevel) || i.maxAttempts == 1 {
+			return next(ctx, req)
+		}
+		return i.call(ctx, next, req)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// call runs attempts until one succeeds, fails with a non-retryable error,
+// or no further retry is allowed.
+func (i *interceptor) call(ctx context.Context, next connect.UnaryFunc, req connect.AnyRequest) (connect.AnyResponse, error) {
+	procedure := attribute.String("procedure", req.Spec().Procedure)
+	span := trace.SpanFromContext(ctx)
+	header := req.Header().Clone()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			resetHeader(req.Header(), header)
+		}
+		resp, err := next(ctx, req)
+		i.attempts.Add(ctx, 1, metric.WithAttributes(
+			procedure,
+			attribute.String("status", getStatus(err)),
+			attribute.Bool("retry", attempt > 1),
+		))
+
+		if err == nil {
+			i.budget.success()
+			return resp, nil
+		}
+		code := connect.CodeOf(err)
+		if !slices.Contains(i.codes, code) {
+			return resp, err
+		}
+		i.budget.failure()
+
+		delay := i.backoff(attempt, err)
+		reason := i.stop(ctx, attempt, delay)
+		if reason == reasonThrottled {
+			i.throttled.Add(ctx, 1, metric.WithAttributes(procedure))
+		}
+
+		span.AddEvent("rpc.retry.attempt", trace.WithAttributes(
+			attribute.Int("rpc.retry.attempt", attempt),
+			attribute.String("rpc.retry.code", code.String()),
+			attribute.Int64("rpc.retry.backoff_ms", delay.Milliseconds()),
+			attribute.String("rpc.retry.outcome", outcome(reason)),
+		))
+		if reason != "" {
+			return resp, err
+		}
+
+		if i.sleep(ctx, delay) != nil {
+			span.AddEvent("rpc.retry.attempt", trace.WithAttributes(
+				attribute.Int("rpc.retry.attempt", attempt),
+				attribute.String("rpc.retry.outcome", reasonCanceled),
+			))
+			return resp, err
+		}
+	}
+}
+
+// resetHeader restores the caller's request headers, so that every attempt
+// starts from them rather than from what inner interceptors set on the
+// previous attempt.
+func resetHeader(h, original http.Header) {
+	clear(h)
+	for k, v := range original {
+		h[k] = slices.Clone(v)
+	}
+}
+
+// stop returns why no further attempt should follow attempt, or "" to retry after delay.
+func (i *interceptor) stop(ctx context.Context, attempt int, delay time.Duration) string {
+	if attempt >= i.maxAttempts {
+		return reasonExhausted
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= delay {
+		return reasonDeadline
+	}
+	if ctx.Err() != nil {
+		return reasonCanceled
+	}
+	if !i.budget.allow() {
+		return reasonThrottled
+	}
+	return ""
+}
+
+// backoff returns the delay before the retry following attempt: exponential
+// backoff with jitter, extended to the server's Retry-After if it is longer.
+func (i *interceptor) backoff(attempt int, err error) time.Duration {
+	d := float64(i.initialBackoff) * math.Pow(i.multiplier, float64(attempt-1))
+	d = min(d, float64(i.maxBackoff))
+	d -= d * i.jitter * i.random()
+	delay := time.Duration(d)
+
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		if secs, err := strconv.Atoi(connectErr.Meta().Get(ratelimit.RetryAfterHeader)); err == nil && secs > 0 {
+			delay = max(delay, time.Duration(secs)*time.Second)
+		}
+	}
+	return delay
+}
+
+// idempotent reports whether calls at level can safely be repeated.
+func idempotent(level connect.IdempotencyLevel) bool {
+	return level == connect.IdempotencyNoSideEffects || level == connect.IdempotencyIdempotent
+}
+
+// outcome names the span event outcome for a stop reason.
+func outcome(reason string) string {
+	if reason == "" {
+		return "retry"
+	}
+	return reason
+}
+
+func getStatus(err error) string {
+	if err == nil {
+		return "ok"
+	}
+	var connectErr *connect.Error
+	if errors.As(err, &connectErr) {
+		return connectErr.Code().String()
+	}
+	return "unknown"
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// budget is a retry token bucket shared by all calls of an interceptor.
+// A nil budget allows every retry.
+type budget struct {
+	mu        sync.Mutex
+	tokens    float64
+	maxTokens float64
+	ratio     float64
+}
+
+func (b *budget) success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = min(b.maxTokens, b.tokens+b.ratio)
+}
+
+func (b *budget) failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = max(0, b.tokens-1)
+}
+
+func (b *budget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens > b.maxTokens/2
+}
@@ -0,0 +1,465 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/ratelimit"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr error
+	}{
+		{name: "default config", modify: func(*Config) {}},
+		{name: "zero attempts", modify: func(c *Config) { c.MaxAttempts = 0 }, wantErr: ErrInvalidMaxAttempts},
+		{name: "negative backoff", modify: func(c *Config) { c.InitialBackoff = -time.Second }, wantErr: ErrInvalidBackoff},
+		{name: "max below initial", modify: func(c *Config) { c.MaxBackoff = time.Millisecond }, wantErr: ErrInvalidBackoff},
+		{name: "multiplier below 1", modify: func(c *Config) { c.Multiplier = 0.5 }, wantErr: ErrInvalidMultiplier},
+		{name: "jitter above 1", modify: func(c *Config) { c.Jitter = 1.5 }, wantErr: ErrInvalidJitter},
+		{name: "negative budget", modify: func(c *Config) { c.Budget.Ratio = -1 }, wantErr: ErrInvalidBudget},
+		{name: "budget disabled", modify: func(c *Config) { c.Budget = Budget{} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			if err := cfg.Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewInterceptor_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewInterceptor(Config{})
+	if !errors.Is(err, ErrInvalidMaxAttempts) {
+		t.Errorf("NewInterceptor() error = %v, want %v", err, ErrInvalidMaxAttempts)
+	}
+}
+
+func TestInterceptor_WrapUnary(t *testing.T) {
+	t.Parallel()
+
+	unavailable := connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+
+	tests := []struct {
+		name      string
+		spec      connect.Spec
+		codes     []connect.Code
+		errs      []error
+		wantCalls int
+		wantCode  connect.Code
+		wantErr   bool
+	}{
+		{
+			name:      "succeeds after retry",
+			spec:      clientSpec(connect.IdempotencyNoSideEffects),
+			errs:      []error{unavailable, nil},
+			wantCalls: 2,
+		},
+		{
+			name:      "idempotent procedure exhausts attempts",
+			spec:      clientSpec(connect.IdempotencyIdempotent),
+			errs:      []error{unavailable, unavailable, unavailable, nil},
+			wantCalls: 3,
+			wantCode:  connect.CodeUnavailable,
+			wantErr:   true,
+		},
+		{
+			name:      "resource exhausted is retried",
+			spec:      clientSpec(connect.IdempotencyIdempotent),
+			errs:      []error{connect.NewError(connect.CodeResourceExhausted, errors.New("slow down")), nil},
+			wantCalls: 2,
+		},
+		{
+			name:      "unknown idempotency is not retried",
+			spec:      clientSpec(connect.IdempotencyUnknown),
+			errs:      []error{unavailable, nil},
+			wantCalls: 1,
+			wantCode:  connect.CodeUnavailable,
+			wantErr:   true,
+		},
+		{
+			name:      "non-retryable code",
+			spec:      clientSpec(connect.IdempotencyNoSideEffects),
+			errs:      []error{connect.NewError(connect.CodeInvalidArgument, errors.New("bad")), nil},
+			wantCalls: 1,
+			wantCode:  connect.CodeInvalidArgument,
+			wantErr:   true,
+		},
+		{
+			name:      "configured code is retried",
+			spec:      clientSpec(connect.IdempotencyNoSideEffects),
+			codes:     []connect.Code{connect.CodeAborted},
+			errs:      []error{connect.NewError(connect.CodeAborted, errors.New("conflict")), nil},
+			wantCalls: 2,
+		},
+		{
+			name:      "handler passes through",
+			spec:      connect.Spec{Procedure: "/test.Service/Get", IdempotencyLevel: connect.IdempotencyNoSideEffects},
+			errs:      []error{unavailable, nil},
+			wantCalls: 1,
+			wantCode:  connect.CodeUnavailable,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultConfig()
+			cfg.Codes = tt.codes
+			i := newTestInterceptor(t, cfg)
+
+			calls := 0
+			wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				err := tt.errs[calls]
+				calls++
+				if err != nil {
+					return nil, err
+				}
+				return &mockResponse{}, nil
+			})
+
+			_, err := wrapped(context.Background(), &mockRequest{spec: tt.spec})
+
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && connect.CodeOf(err) != tt.wantCode {
+				t.Errorf("code = %v, want %v", connect.CodeOf(err), tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestInterceptor_ResetsHeaderPerAttempt(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, DefaultConfig())
+
+	// Each attempt sees the caller's headers, not those the previous attempt added.
+	var got []string
+	wrapped := i.WrapUnary(func(_ context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		got = append(got, req.Header().Get("Authorization"))
+		req.Header().Set("Authorization", "Bearer attempt")
+		if len(got) == 1 {
+			return nil, connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+		}
+		return &mockResponse{}, nil
+	})
+
+	req := &mockRequest{spec: clientSpec(connect.IdempotencyIdempotent), header: http.Header{"X-Caller": {"a"}}}
+	if _, err := wrapped(context.Background(), req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"", ""}; !slices.Equal(got, want) {
+		t.Errorf("Authorization per attempt = %q, want %q", got, want)
+	}
+	if v := req.header.Get("X-Caller"); v != "a" {
+		t.Errorf("X-Caller = %q, want %q", v, "a")
+	}
+}
+
+func TestInterceptor_Backoff(t *testing.T) {
+	t.Parallel()
+
+	unavailable := connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+	limited := connect.NewError(connect.CodeResourceExhausted, errors.New("rate limited"))
+	limited.Meta().Set(ratelimit.RetryAfterHeader, "2")
+
+	tests := []struct {
+		name   string
+		random float64
+		err    error
+		want   []time.Duration
+	}{
+		{
+			name: "exponential capped at max",
+			err:  unavailable,
+			want: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond},
+		},
+		{
+			name:   "full jitter fraction",
+			random: 1,
+			err:    unavailable,
+			want:   []time.Duration{80 * time.Millisecond, 160 * time.Millisecond, 320 * time.Millisecond, 400 * time.Millisecond},
+		},
+		{
+			name: "retry after extends delay",
+			err:  limited,
+			want: []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second, 2 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultConfig()
+			cfg.MaxAttempts = 5
+			cfg.MaxBackoff = 500 * time.Millisecond
+			cfg.Budget = Budget{}
+			i := newTestInterceptor(t, cfg)
+			i.random = func() float64 { return tt.random }
+
+			var delays []time.Duration
+			i.sleep = func(_ context.Context, d time.Duration) error {
+				delays = append(delays, d)
+				return nil
+			}
+
+			wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+				return nil, tt.err
+			})
+			_, _ = wrapped(context.Background(), &mockRequest{spec: clientSpec(connect.IdempotencyIdempotent)})
+
+			if len(delays) != len(tt.want) {
+				t.Fatalf("delays = %v, want %v", delays, tt.want)
+			}
+			for n := range delays {
+				if delays[n] != tt.want[n] {
+					t.Errorf("delay[%d] = %v, want %v", n, delays[n], tt.want[n])
+				}
+			}
+		})
+	}
+}
+
+func TestInterceptor_Deadline(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, DefaultConfig())
+
+	calls := 0
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		calls++
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+	})
+
+	// The first backoff (100ms) does not fit into the remaining deadline.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := wrapped(ctx, &mockRequest{spec: clientSpec(connect.IdempotencyIdempotent)})
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Errorf("code = %v, want %v", connect.CodeOf(err), connect.CodeUnavailable)
+	}
+}
+
+func TestInterceptor_Canceled(t *testing.T) {
+	t.Parallel()
+
+	i := newTestInterceptor(t, DefaultConfig())
+	i.sleep = sleep
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		calls++
+		cancel()
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+	})
+
+	_, err := wrapped(ctx, &mockRequest{spec: clientSpec(connect.IdempotencyIdempotent)})
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Errorf("code = %v, want %v", connect.CodeOf(err), connect.CodeUnavailable)
+	}
+}
+
+func TestInterceptor_Budget(t *testing.T) {
+	t.Parallel()
+
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+
+	cfg := DefaultConfig()
+	cfg.MaxAttempts = 10
+	cfg.Budget = Budget{Tokens: 4, Ratio: 1}
+	i, err := newInterceptor(cfg, mp)
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+	i.sleep = func(context.Context, time.Duration) error { return nil }
+
+	fail := true
+	calls := 0
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		calls++
+		if fail {
+			return nil, connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+		}
+		return &mockResponse{}, nil
+	})
+	req := &mockRequest{spec: clientSpec(connect.IdempotencyIdempotent)}
+
+	// Tokens 4 -> 3 -> 2: the second failure leaves the budget at half, so no
+	// further retries happen.
+	_, _ = wrapped(context.Background(), req)
+	if calls != 2 {
+		t.Errorf("calls with budget = %d, want 2", calls)
+	}
+
+	// Budget stays exhausted: one attempt only.
+	calls = 0
+	_, _ = wrapped(context.Background(), req)
+	if calls != 1 {
+		t.Errorf("calls with exhausted budget = %d, want 1", calls)
+	}
+
+	// Successes refill the budget.
+	fail = false
+	for range 3 {
+		_, _ = wrapped(context.Background(), req)
+	}
+	fail = true
+	calls = 0
+	_, _ = wrapped(context.Background(), req)
+	if calls < 2 {
+		t.Errorf("calls after refill = %d, want at least 2", calls)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	procedure := attribute.String("procedure", req.spec.Procedure)
+	if got := sumValue(rm, "rpc.client.retry.throttled", attribute.NewSet(procedure)); got < 2 {
+		t.Errorf("throttled = %d, want at least 2", got)
+	}
+	retried := attribute.NewSet(procedure, attribute.String("status", "unavailable"), attribute.Bool("retry", true))
+	if got := sumValue(rm, "rpc.client.retry.attempts", retried); got < 2 {
+		t.Errorf("retry attempts = %d, want at least 2", got)
+	}
+	succeeded := attribute.NewSet(procedure, attribute.String("status", "ok"), attribute.Bool("retry", false))
+	if got := sumValue(rm, "rpc.client.retry.attempts", succeeded); got != 3 {
+		t.Errorf("successful first attempts = %d, want 3", got)
+	}
+}
+
+func TestInterceptor_SpanEvents(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	i := newTestInterceptor(t, DefaultConfig())
+	wrapped := i.WrapUnary(func(_ context.Context, _ connect.AnyRequest) (connect.AnyResponse, error) {
+		return nil, connect.NewError(connect.CodeUnavailable, errors.New("backend down"))
+	})
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "call")
+	_, _ = wrapped(ctx, &mockRequest{spec: clientSpec(connect.IdempotencyIdempotent)})
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	events := spans[0].Events()
+	wantOutcomes := []string{"retry", "retry", reasonExhausted}
+	if len(events) != len(wantOutcomes) {
+		t.Fatalf("events = %d, want %d", len(events), len(wantOutcomes))
+	}
+	for n, event := range events {
+		if event.Name != "rpc.retry.attempt" {
+			t.Errorf("event[%d] name = %q, want %q", n, event.Name, "rpc.retry.attempt")
+		}
+		attrs := attribute.NewSet(event.Attributes...)
+		if v, _ := attrs.Value("rpc.retry.attempt"); v.AsInt64() != int64(n+1) {
+			t.Errorf("event[%d] attempt = %d, want %d", n, v.AsInt64(), n+1)
+		}
+		if v, _ := attrs.Value("rpc.retry.outcome"); v.AsString() != wantOutcomes[n] {
+			t.Errorf("event[%d] outcome = %q, want %q", n, v.AsString(), wantOutcomes[n])
+		}
+	}
+}
+
+func newTestInterceptor(t *testing.T, cfg Config) *interceptor {
+	t.Helper()
+
+	i, err := newInterceptor(cfg, noop.NewMeterProvider())
+	if err != nil {
+		t.Fatalf("newInterceptor() error = %v", err)
+	}
+	i.sleep = func(context.Context, time.Duration) error { return nil }
+	i.random = func() float64 { return 0 }
+	return i
+}
+
+func clientSpec(level connect.IdempotencyLevel) connect.Spec {
+	return connect.Spec{Procedure: "/test.Service/Get", IsClient: true, IdempotencyLevel: level}
+}
+
+func sumValue(rm metricdata.ResourceMetrics, name string, attrs attribute.Set) int64 {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				return 0
+			}
+			for _, dp := range sum.DataPoints {
+				if dp.Attributes.Equals(&attrs) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	spec   connect.Spec
+	header http.Header
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return r.spec
+}
+
+func (r *mockRequest) Header() http.Header {
+	return r.header
+}
+
+type mockResponse struct {
+	connect.AnyResponse
+}
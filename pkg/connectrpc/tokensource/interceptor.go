@@ -0,0 +1,87 @@
+package tokensource
+
+import (
+	"context"
+	"errors"
+
+	"connectrpc.com/connect"
+)
+
+// NewInterceptor creates a Connect RPC client interceptor that sets the
+// Authorization header of outbound calls to a token from source.
+// Calls that already carry an Authorization header set by the caller, e.g. to
+// forward the caller's token, are left unchanged. Place it after the retry
+// interceptor, which gives every attempt the caller's original headers, so
+// that each attempt gets a current token. If no token can be obtained, the
+// call fails with CodeUnavailable wrapping ErrTokenFetch.
+// Handlers pass through unchanged.
+func NewInterceptor(source *TokenSource) connect.Interceptor {
+	return &interceptor{source: source}
+}
+
+type interceptor struct {
+	source *TokenSource
+}
+
+func (i *interceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return func(ctx context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		if !req.Spec().IsClient || req.Header().Get("Authorization") != "" {
+			return next(ctx, req)
+		}
+		value, err := i.authorization(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header().Set("Authorization", value)
+		return next(ctx, req)
+	}
+}
+
+func (i *interceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return func(ctx context.Context, spec connect.Spec) connect.StreamingClientConn {
+		conn := next(ctx, spec)
+		if conn.RequestHeader().Get("Authorization") != "" {
+			return conn
+		}
+		value, err := i.authorization(ctx)
+		if err != nil {
+			return &failedConn{StreamingClientConn: conn, err: err}
+		}
+		conn.RequestHeader().Set("Authorization", value)
+		return conn
+	}
+}
+
+func (i *interceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return next
+}
+
+// authorization returns the Authorization header value for a fresh token.
+func (i *interceptor) authorization(ctx context.Context) (string, error) {
+	tok, err := i.source.Token(ctx)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return "", connect.NewError(connect.CodeCanceled, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "", connect.NewError(connect.CodeDeadlineExceeded, err)
+	case err != nil:
+		return "", connect.NewError(connect.CodeUnavailable, err)
+	}
+
+	return tok.TokenType + " " + tok.AccessToken, nil
+}
+
+// failedConn fails a stream whose token could not be obtained before
+// anything is sent.
+type failedConn struct {
+	connect.StreamingClientConn
+	err error
+}
+
+func (c *failedConn) Send(any) error {
+	return c.err
+}
+
+func (c *failedConn) Receive(any) error {
+	return c.err
+}
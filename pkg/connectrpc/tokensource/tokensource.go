@@ -0,0 +1,389 @@
+// Package tokensource provides OAuth2 access tokens for outbound Connect RPC calls.
+//
+// A TokenSource obtains tokens with the client_credentials grant (RFC 6749
+// section 4.4), optionally exchanging them with OAuth 2.0 Token Exchange
+// (RFC 8693), and caches them until shortly before they expire.
+// NewInterceptor attaches them to client calls.
+package tokensource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/deepworx/go-utils/pkg/tracing"
+)
+
+// maxTokenResponseSize bounds the token endpoint response body.
+const maxTokenResponseSize = 1 << 20
+
+// defaultExpiry is the lifetime assumed for tokens issued without "expires_in".
+const defaultExpiry = 5 * time.Minute
+
+// Grant and token type identifiers.
+const (
+	grantClientCredentials = "client_credentials"
+	grantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// TokenTypeAccessToken identifies an OAuth2 access token (RFC 8693 section 3).
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+	// TokenTypeJWT identifies a JWT, e.g. a Kubernetes service account token (RFC 8693 section 3).
+	TokenTypeJWT = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// Sentinel errors.
+var (
+	// ErrTokenURLRequired is returned when TokenURL is empty.
+	ErrTokenURLRequired = errors.New("token_url is required")
+
+	// ErrClientIDRequired is returned when ClientID is empty and no subject
+	// token file authenticates the token exchange instead.
+	ErrClientIDRequired = errors.New("client_id is required")
+
+	// ErrInvalidRefreshBefore is returned when ExpiryLeeway is negative or
+	// RefreshBefore is smaller than ExpiryLeeway.
+	ErrInvalidRefreshBefore = errors.New("expiry_leeway must be non-negative and refresh_before >= expiry_leeway")
+
+	// ErrTokenFetch is returned when a token cannot be obtained.
+	ErrTokenFetch = errors.New("token fetch failed")
+)
+
+// TokenExchangeConfig configures OAuth 2.0 Token Exchange (RFC 8693).
+type TokenExchangeConfig struct {
+	// URL is the token exchange endpoint.
+	// Default: Config.TokenURL
+	URL string `koanf:"url"`
+
+	// SubjectTokenFile is a file holding the subject token, e.g. a projected
+	// Kubernetes service account token. It is read on every exchange, so
+	// rotated tokens are picked up. If empty, the client_credentials token
+	// is exchanged.
+	SubjectTokenFile string `koanf:"subject_token_file"`
+
+	// SubjectTokenType is the type of the subject token.
+	// Default: TokenTypeJWT with SubjectTokenFile, TokenTypeAccessToken otherwise.
+	SubjectTokenType string `koanf:"subject_token_type"`
+
+	// RequestedTokenType is the requested token type.
+	// Default: TokenTypeAccessToken
+	RequestedTokenType string `koanf:"requested_token_type"`
+
+	// Audience is the logical name of the target service.
+	Audience string `koanf:"audience"`
+
+	// Resource is the URI of the target service.
+	Resource string `koanf:"resource"`
+
+	// Scopes are the scopes requested for the exchanged token.
+	Scopes []string `koanf:"scopes"`
+}
+
+// Config holds configuration for a TokenSource.
+type Config struct {
+	// TokenURL is the OAuth2 token endpoint (e.g., "https://idp.example.com/oauth2/token").
+	// Required.
+	TokenURL string `koanf:"token_url"`
+
+	// ClientID and ClientSecret authenticate this client at the token endpoint
+	// using HTTP Basic authentication. Use a koanfutil file:// value to load
+	// the secret from disk. Required unless TokenExchange.SubjectTokenFile is set.
+	ClientID     string `koanf:"client_id"`
+	ClientSecret string `koanf:"client_secret"`
+
+	// Scopes are the scopes requested with the client_credentials grant.
+	Scopes []string `koanf:"scopes"`
+
+	// Audience is sent as the "audience" parameter of the client_credentials
+	// grant, for providers that scope tokens by audience.
+	Audience string `koanf:"audience"`
+
+	// TokenExchange enables token exchange. Nil uses client_credentials tokens directly.
+	TokenExchange *TokenExchangeConfig `koanf:"token_exchange"`
+
+	// ExpiryLeeway is how long before its expiry a token is no longer used.
+	// Default: 10s
+	ExpiryLeeway time.Duration `koanf:"expiry_leeway"`
+
+	// RefreshBefore is how long before its expiry a token is refreshed in
+	// the background while still being used.
+	// Default: 1m
+	RefreshBefore time.Duration `koanf:"refresh_before"`
+
+	// HTTPTimeout is the timeout for token requests.
+	// Default: 10s
+	HTTPTimeout time.Duration `koanf:"http_timeout"`
+}
+
+// DefaultConfig returns a Config with sensible default values.
+// TokenURL, ClientID, and ClientSecret must be set by the caller.
+func DefaultConfig() Config {
+	return Config{
+		ExpiryLeeway:  10 * time.Second,
+		RefreshBefore: time.Minute,
+		HTTPTimeout:   10 * time.Second,
+	}
+}
+
+// Validate checks that all required fields are set.
+// Returns nil if configuration is valid.
+func (c Config) Validate() error {
+	if c.TokenURL == "" {
+		return ErrTokenURLRequired
+	}
+	if c.ClientID == "" && (c.TokenExchange == nil || c.TokenExchange.SubjectTokenFile == "") {
+		return ErrClientIDRequired
+	}
+	if c.ExpiryLeeway < 0 || c.RefreshBefore < c.ExpiryLeeway {
+		return ErrInvalidRefreshBefore
+	}
+	return nil
+}
+
+// Token is an OAuth2 access token.
+type Token struct {
+	// AccessToken is the token value.
+	AccessToken string
+
+	// TokenType is the token type, usually "Bearer".
+	TokenType string
+
+	// Expiry is when the token expires.
+	Expiry time.Time
+}
+
+// TokenSource obtains access tokens and caches them until shortly before expiry.
+// It is safe for concurrent use.
+type TokenSource struct {
+	cfg    Config
+	client *http.Client
+	now    func() time.Time
+
+	// group deduplicates concurrent fetches, including background refreshes.
+	group singleflight.Group
+
+	mu    sync.RWMutex
+	token *Token
+}
+
+// NewTokenSource creates a TokenSource. No token is fetched until the first call to Token.
+// Returns error if cfg is invalid.
+func NewTokenSource(cfg Config) (*TokenSource, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("create token source: %w", err)
+	}
+
+	httpTimeout := cfg.HTTPTimeout
+	if httpTimeout == 0 {
+		httpTimeout = 10 * time.Second
+	}
+
+	return &TokenSource{
+		cfg:    cfg,
+		client: &http.Client{Timeout: httpTimeout},
+		now:    time.Now,
+	}, nil
+}
+
+// Token returns a cached token, fetching a new one if none is valid.
+// Within RefreshBefore of expiry, the cached token is returned while a
+// new one is fetched in the background. Concurrent fetches are deduplicated.
+func (s *TokenSource) Token(ctx context.Context) (Token, error) {
+	now := s.now()
+
+	s.mu.RLock()
+	tok := s.token
+	s.mu.RUnlock()
+
+	if tok != nil && now.Before(tok.Expiry.Add(-s.cfg.ExpiryLeeway)) {
+		if !now.Before(tok.Expiry.Add(-s.cfg.RefreshBefore)) {
+			s.group.DoChan("token", func() (any, error) {
+				return s.refresh(context.WithoutCancel(ctx))
+			})
+		}
+		return *tok, nil
+	}
+
+	// The fetch outlives a canceled caller, so callers sharing it are not failed.
+	ch := s.group.DoChan("token", func() (any, error) {
+		return s.refresh(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return Token{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return Token{}, res.Err
+		}
+		return res.Val.(Token), nil
+	}
+}
+
+// refresh fetches a token and caches it.
+func (s *TokenSource) refresh(ctx context.Context) (Token, error) {
+	tok, err := s.fetch(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+
+	s.mu.Lock()
+	s.token = &tok
+	s.mu.Unlock()
+	return tok, nil
+}
+
+// fetch obtains a token with client_credentials and, if configured, exchanges it.
+func (s *TokenSource) fetch(ctx context.Context) (Token, error) {
+	ex := s.cfg.TokenExchange
+	if ex == nil {
+		return s.clientCredentials(ctx)
+	}
+
+	subject, subjectType := "", ex.SubjectTokenType
+	if ex.SubjectTokenFile != "" {
+		data, err := os.ReadFile(ex.SubjectTokenFile)
+		if err != nil {
+			return Token{}, fmt.Errorf("%w: read subject token: %v", ErrTokenFetch, err)
+		}
+		subject = strings.TrimSpace(string(data))
+		if subjectType == "" {
+			subjectType = TokenTypeJWT
+		}
+	} else {
+		tok, err := s.clientCredentials(ctx)
+		if err != nil {
+			return Token{}, err
+		}
+		subject = tok.AccessToken
+		if subjectType == "" {
+			subjectType = TokenTypeAccessToken
+		}
+	}
+
+	return s.exchange(ctx, subject, subjectType)
+}
+
+// clientCredentials requests a token with the client_credentials grant.
+func (s *TokenSource) clientCredentials(ctx context.Context) (Token, error) {
+	form := url.Values{"grant_type": {grantClientCredentials}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.Audience != "" {
+		form.Set("audience", s.cfg.Audience)
+	}
+
+	var tok Token
+	err := tracing.WithSpan(ctx, "tokensource.client_credentials", func(ctx context.Context) error {
+		var err error
+		tok, err = s.request(ctx, s.cfg.TokenURL, form)
+		return err
+	})
+	return tok, err
+}
+
+// exchange exchanges subject for a token with RFC 8693 token exchange.
+func (s *TokenSource) exchange(ctx context.Context, subject, subjectType string) (Token, error) {
+	ex := s.cfg.TokenExchange
+	endpoint := ex.URL
+	if endpoint == "" {
+		endpoint = s.cfg.TokenURL
+	}
+	requestedType := ex.RequestedTokenType
+	if requestedType == "" {
+		requestedType = TokenTypeAccessToken
+	}
+
+	form := url.Values{
+		"grant_type":           {grantTokenExchange},
+		"subject_token":        {subject},
+		"subject_token_type":   {subjectType},
+		"requested_token_type": {requestedType},
+	}
+	if ex.Audience != "" {
+		form.Set("audience", ex.Audience)
+	}
+	if ex.Resource != "" {
+		form.Set("resource", ex.Resource)
+	}
+	if len(ex.Scopes) > 0 {
+		form.Set("scope", strings.Join(ex.Scopes, " "))
+	}
+
+	var tok Token
+	err := tracing.WithSpan(ctx, "tokensource.token_exchange", func(ctx context.Context) error {
+		var err error
+		tok, err = s.request(ctx, endpoint, form)
+		return err
+	})
+	return tok, err
+}
+
+// tokenResponse is the token endpoint response (RFC 6749 section 5).
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// request posts form to the token endpoint and decodes the token.
+func (s *TokenSource) request(ctx context.Context, endpoint string, form url.Values) (Token, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrTokenFetch, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.cfg.ClientID != "" {
+		// RFC 6749 section 2.3.1: credentials are form-encoded before Basic encoding.
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	issuedAt := s.now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return Token{}, fmt.Errorf("%w: %v", ErrTokenFetch, err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	decodeErr := json.NewDecoder(io.LimitReader(resp.Body, maxTokenResponseSize)).Decode(&body)
+
+	if resp.StatusCode != http.StatusOK {
+		if body.Error != "" {
+			return Token{}, fmt.Errorf("%w: status %d: %s %s", ErrTokenFetch, resp.StatusCode, body.Error, body.ErrorDescription)
+		}
+		return Token{}, fmt.Errorf("%w: status %d", ErrTokenFetch, resp.StatusCode)
+	}
+	if decodeErr != nil {
+		return Token{}, fmt.Errorf("%w: decode response: %v", ErrTokenFetch, decodeErr)
+	}
+	if body.AccessToken == "" {
+		return Token{}, fmt.Errorf("%w: response has no access_token", ErrTokenFetch)
+	}
+
+	tok := Token{
+		AccessToken: body.AccessToken,
+		TokenType:   body.TokenType,
+		Expiry:      issuedAt.Add(defaultExpiry),
+	}
+	if tok.TokenType == "" || strings.EqualFold(tok.TokenType, "bearer") {
+		tok.TokenType = "Bearer"
+	}
+	if body.ExpiresIn > 0 {
+		tok.Expiry = issuedAt.Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
@@ -0,0 +1,520 @@
+package tokensource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+
+	"github.com/deepworx/go-utils/pkg/connectrpc/retry"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := func() Config {
+		cfg := DefaultConfig()
+		cfg.TokenURL = "https://idp.example.com/token"
+		cfg.ClientID = "svc"
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		cfg     func() Config
+		wantErr error
+	}{
+		{name: "valid", cfg: valid},
+		{
+			name:    "missing token url",
+			cfg:     func() Config { c := valid(); c.TokenURL = ""; return c },
+			wantErr: ErrTokenURLRequired,
+		},
+		{
+			name:    "missing client id",
+			cfg:     func() Config { c := valid(); c.ClientID = ""; return c },
+			wantErr: ErrClientIDRequired,
+		},
+		{
+			name: "subject token file without client id",
+			cfg: func() Config {
+				c := valid()
+				c.ClientID = ""
+				c.TokenExchange = &TokenExchangeConfig{SubjectTokenFile: "/var/run/secrets/token"}
+				return c
+			},
+		},
+		{
+			name:    "refresh before below leeway",
+			cfg:     func() Config { c := valid(); c.RefreshBefore = time.Second; return c },
+			wantErr: ErrInvalidRefreshBefore,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if err := tt.cfg().Validate(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewTokenSource_InvalidConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewTokenSource(Config{})
+	if !errors.Is(err, ErrTokenURLRequired) {
+		t.Errorf("NewTokenSource() error = %v, want %v", err, ErrTokenURLRequired)
+	}
+}
+
+func TestTokenSource_ClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	idp := newTestIDP(t)
+	source, clock := newTestSource(t, idp, func(c *Config) {
+		c.Scopes = []string{"orders.read", "orders.write"}
+		c.Audience = "orders"
+	})
+
+	tok, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+	if tok.AccessToken != "token-1" || tok.TokenType != "Bearer" {
+		t.Errorf("Token() = %+v, want Bearer token-1", tok)
+	}
+	if want := clock.Now().Add(time.Hour); !tok.Expiry.Equal(want) {
+		t.Errorf("Expiry = %v, want %v", tok.Expiry, want)
+	}
+
+	form := idp.lastForm()
+	if form.Get("grant_type") != "client_credentials" || form.Get("scope") != "orders.read orders.write" || form.Get("audience") != "orders" {
+		t.Errorf("form = %v", form)
+	}
+	if user, pass, _ := idp.lastBasicAuth(); user != "svc" || pass != "s3cret" {
+		t.Errorf("basic auth = %q:%q, want svc:s3cret", user, pass)
+	}
+
+	// Cached until ExpiryLeeway before expiry.
+	clock.Advance(30 * time.Minute)
+	if tok, _ := source.Token(context.Background()); tok.AccessToken != "token-1" || idp.count() != 1 {
+		t.Errorf("cached Token() = %q after %d requests, want token-1 after 1", tok.AccessToken, idp.count())
+	}
+
+	clock.Advance(30*time.Minute - 5*time.Second)
+	if tok, _ := source.Token(context.Background()); tok.AccessToken != "token-2" {
+		t.Errorf("Token() within leeway = %q, want token-2", tok.AccessToken)
+	}
+}
+
+func TestTokenSource_ProactiveRefresh(t *testing.T) {
+	t.Parallel()
+
+	idp := newTestIDP(t)
+	source, clock := newTestSource(t, idp, nil)
+
+	if _, err := source.Token(context.Background()); err != nil {
+		t.Fatalf("Token() error = %v", err)
+	}
+
+	// Inside RefreshBefore the cached token is still returned...
+	clock.Advance(time.Hour - 30*time.Second)
+	if tok, _ := source.Token(context.Background()); tok.AccessToken != "token-1" {
+		t.Errorf("Token() in refresh window = %q, want token-1", tok.AccessToken)
+	}
+
+	// ...while a new one is fetched in the background.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if tok, _ := source.Token(context.Background()); tok.AccessToken == "token-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background refresh did not replace the token")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTokenSource_Singleflight(t *testing.T) {
+	t.Parallel()
+
+	idp := newTestIDP(t)
+	release := make(chan struct{})
+	idp.block = release
+	source, _ := newTestSource(t, idp, nil)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := source.Token(context.Background()); err != nil {
+				t.Errorf("Token() error = %v", err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := idp.count(); got != 1 {
+		t.Errorf("token requests = %d, want 1", got)
+	}
+}
+
+func TestTokenSource_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantMsg string
+	}{
+		{
+			name:    "oauth error",
+			status:  http.StatusUnauthorized,
+			body:    `{"error":"invalid_client","error_description":"bad secret"}`,
+			wantMsg: "invalid_client bad secret",
+		},
+		{name: "server error", status: http.StatusBadGateway, body: "bad gateway", wantMsg: "status 502"},
+		{name: "missing token", status: http.StatusOK, body: `{"token_type":"Bearer"}`, wantMsg: "no access_token"},
+		{name: "invalid json", status: http.StatusOK, body: "{", wantMsg: "decode response"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(srv.Close)
+
+			source, err := NewTokenSource(Config{TokenURL: srv.URL, ClientID: "svc"})
+			if err != nil {
+				t.Fatalf("NewTokenSource() error = %v", err)
+			}
+			_, err = source.Token(context.Background())
+			if !errors.Is(err, ErrTokenFetch) || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Errorf("Token() error = %v, want %v containing %q", err, ErrTokenFetch, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestTokenSource_TokenExchange(t *testing.T) {
+	t.Parallel()
+
+	t.Run("client credentials subject", func(t *testing.T) {
+		t.Parallel()
+
+		idp := newTestIDP(t)
+		source, _ := newTestSource(t, idp, func(c *Config) {
+			c.TokenExchange = &TokenExchangeConfig{Audience: "orders", Scopes: []string{"orders.read"}}
+		})
+
+		tok, err := source.Token(context.Background())
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if tok.AccessToken != "token-2" || idp.count() != 2 {
+			t.Errorf("Token() = %q after %d requests, want token-2 after 2", tok.AccessToken, idp.count())
+		}
+
+		form := idp.lastForm()
+		want := url.Values{
+			"grant_type":           {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":        {"token-1"},
+			"subject_token_type":   {TokenTypeAccessToken},
+			"requested_token_type": {TokenTypeAccessToken},
+			"audience":             {"orders"},
+			"scope":                {"orders.read"},
+		}
+		for key := range want {
+			if form.Get(key) != want.Get(key) {
+				t.Errorf("form %s = %q, want %q", key, form.Get(key), want.Get(key))
+			}
+		}
+	})
+
+	t.Run("subject token file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "token")
+		if err := os.WriteFile(path, []byte("k8s-token\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		idp := newTestIDP(t)
+		source, _ := newTestSource(t, idp, func(c *Config) {
+			c.ClientID = ""
+			c.TokenExchange = &TokenExchangeConfig{SubjectTokenFile: path, Resource: "https://orders.internal"}
+		})
+
+		if _, err := source.Token(context.Background()); err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		form := idp.lastForm()
+		if idp.count() != 1 || form.Get("subject_token") != "k8s-token" ||
+			form.Get("subject_token_type") != TokenTypeJWT || form.Get("resource") != "https://orders.internal" {
+			t.Errorf("form = %v after %d requests", form, idp.count())
+		}
+		if _, _, ok := idp.lastBasicAuth(); ok {
+			t.Error("basic auth sent without client ID")
+		}
+	})
+}
+
+func TestInterceptor(t *testing.T) {
+	t.Parallel()
+
+	idp := newTestIDP(t)
+	source, _ := newTestSource(t, idp, nil)
+	i := NewInterceptor(source)
+
+	tests := []struct {
+		name     string
+		isClient bool
+		header   string
+		want     string
+	}{
+		{name: "sets token", isClient: true, want: "Bearer token-1"},
+		{name: "keeps explicit header", isClient: true, header: "Bearer user", want: "Bearer user"},
+		{name: "handler passes through"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := &mockRequest{spec: connect.Spec{IsClient: tt.isClient}, header: http.Header{}}
+			if tt.header != "" {
+				req.header.Set("Authorization", tt.header)
+			}
+			var got string
+			_, err := i.WrapUnary(func(_ context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+				got = req.Header().Get("Authorization")
+				return nil, nil
+			})(context.Background(), req)
+			if err != nil {
+				t.Fatalf("call error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptor_FetchFailure(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(srv.Close)
+
+	source, err := NewTokenSource(Config{TokenURL: srv.URL, ClientID: "svc"})
+	if err != nil {
+		t.Fatalf("NewTokenSource() error = %v", err)
+	}
+	i := NewInterceptor(source)
+
+	called := false
+	_, err = i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		called = true
+		return nil, nil
+	})(context.Background(), &mockRequest{spec: connect.Spec{IsClient: true}, header: http.Header{}})
+	if called || connect.CodeOf(err) != connect.CodeUnavailable || !errors.Is(err, ErrTokenFetch) {
+		t.Errorf("unary error = %v (called %v), want unavailable wrapping %v", err, called, ErrTokenFetch)
+	}
+
+	conn := i.WrapStreamingClient(func(context.Context, connect.Spec) connect.StreamingClientConn {
+		return &mockClientConn{header: http.Header{}}
+	})(context.Background(), connect.Spec{IsClient: true})
+	if err := conn.Send(nil); connect.CodeOf(err) != connect.CodeUnavailable {
+		t.Errorf("stream Send() error = %v, want unavailable", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = i.WrapUnary(func(context.Context, connect.AnyRequest) (connect.AnyResponse, error) {
+		return nil, nil
+	})(ctx, &mockRequest{spec: connect.Spec{IsClient: true}, header: http.Header{}})
+	if connect.CodeOf(err) != connect.CodeCanceled {
+		t.Errorf("canceled error = %v, want canceled", err)
+	}
+}
+
+func TestInterceptor_RetryRefreshesToken(t *testing.T) {
+	t.Parallel()
+
+	idp := newTestIDP(t)
+	source, clock := newTestSource(t, idp, nil)
+
+	retryCfg := retry.DefaultConfig()
+	retryCfg.InitialBackoff = time.Millisecond
+	retryInterceptor, err := retry.NewInterceptor(retryCfg)
+	if err != nil {
+		t.Fatalf("retry.NewInterceptor() error = %v", err)
+	}
+
+	// The first attempt outlives its token and fails; the retry must not
+	// reuse the stale header.
+	var got []string
+	call := connect.UnaryFunc(func(_ context.Context, req connect.AnyRequest) (connect.AnyResponse, error) {
+		got = append(got, req.Header().Get("Authorization"))
+		if len(got) == 1 {
+			clock.Advance(2 * time.Hour)
+			return nil, connect.NewError(connect.CodeUnavailable, errors.New("upstream unavailable"))
+		}
+		return nil, nil
+	})
+	call = retryInterceptor.WrapUnary(NewInterceptor(source).WrapUnary(call))
+
+	req := &mockRequest{
+		spec:   connect.Spec{IsClient: true, IdempotencyLevel: connect.IdempotencyNoSideEffects},
+		header: http.Header{},
+	}
+	if _, err := call(context.Background(), req); err != nil {
+		t.Fatalf("call error = %v", err)
+	}
+	if want := []string{"Bearer token-1", "Bearer token-2"}; !slices.Equal(got, want) {
+		t.Errorf("Authorization per attempt = %q, want %q", got, want)
+	}
+}
+
+// testIDP is a token endpoint issuing token-1, token-2, ... valid for an hour.
+type testIDP struct {
+	url   string
+	block chan struct{}
+
+	requests atomic.Int64
+	mu       sync.Mutex
+	form     url.Values
+	user     string
+	pass     string
+	hasAuth  bool
+}
+
+func newTestIDP(t *testing.T) *testIDP {
+	t.Helper()
+
+	idp := &testIDP{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if idp.block != nil {
+			<-idp.block
+		}
+		_ = r.ParseForm()
+		user, pass, ok := r.BasicAuth()
+
+		idp.mu.Lock()
+		idp.form, idp.user, idp.pass, idp.hasAuth = r.PostForm, user, pass, ok
+		idp.mu.Unlock()
+
+		n := idp.requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "token-" + string(rune('0'+n)),
+			"token_type":   "bearer",
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(srv.Close)
+	idp.url = srv.URL
+	return idp
+}
+
+func (p *testIDP) count() int64 {
+	return p.requests.Load()
+}
+
+func (p *testIDP) lastForm() url.Values {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.form
+}
+
+func (p *testIDP) lastBasicAuth() (string, string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.user, p.pass, p.hasAuth
+}
+
+func newTestSource(t *testing.T, idp *testIDP, modify func(*Config)) (*TokenSource, *fakeClock) {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.TokenURL = idp.url
+	cfg.ClientID = "svc"
+	cfg.ClientSecret = "s3cret"
+	if modify != nil {
+		modify(&cfg)
+	}
+	source, err := NewTokenSource(cfg)
+	if err != nil {
+		t.Fatalf("NewTokenSource() error = %v", err)
+	}
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	source.now = clock.Now
+	return source, clock
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type mockRequest struct {
+	connect.AnyRequest
+	spec   connect.Spec
+	header http.Header
+}
+
+func (r *mockRequest) Spec() connect.Spec {
+	return r.spec
+}
+
+func (r *mockRequest) Header() http.Header {
+	return r.header
+}
+
+type mockClientConn struct {
+	connect.StreamingClientConn
+	header http.Header
+}
+
+func (c *mockClientConn) RequestHeader() http.Header {
+	return c.header
+}
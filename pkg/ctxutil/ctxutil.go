@@ -2,7 +2,10 @@
 // request-scoped values in context.Context.
 package ctxutil
 
-import "context"
+import (
+	"context"
+	"sync"
+)
 
 // ctxKey is an unexported type for context keys to prevent collisions.
 type ctxKey int
@@ -10,6 +13,8 @@ type ctxKey int
 const (
 	requestIDKey ctxKey = iota
 	claimsKey
+	claimsRecorderKey
+	customClaimsKey
 )
 
 // Claims holds JWT-related identity information.
@@ -32,10 +37,43 @@ func RequestID(ctx context.Context) (string, bool) {
 }
 
 // WithClaims returns a new context with the claims set.
+// If ctx was derived from WithClaimsRecorder, the claims are also recorded.
 func WithClaims(ctx context.Context, claims Claims) context.Context {
+	if rec, ok := ctx.Value(claimsRecorderKey).(*claimsRecorder); ok {
+		rec.set(claims)
+	}
 	return context.WithValue(ctx, claimsKey, claims)
 }
 
+// WithClaimsRecorder returns a new context in which WithClaims also records
+// the claims, and a function that returns the last claims recorded. It lets
+// code that runs before authentication, such as a metrics interceptor, read
+// the claims once the rest of the request chain has returned.
+func WithClaimsRecorder(ctx context.Context) (context.Context, func() (Claims, bool)) {
+	rec := &claimsRecorder{}
+	return context.WithValue(ctx, claimsRecorderKey, rec), rec.get
+}
+
+// claimsRecorder holds the claims recorded by WithClaims.
+type claimsRecorder struct {
+	mu     sync.Mutex
+	claims Claims
+	ok     bool
+}
+
+func (r *claimsRecorder) set(claims Claims) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.claims = claims
+	r.ok = true
+}
+
+func (r *claimsRecorder) get() (Claims, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.claims, r.ok
+}
+
 // GetClaims returns the claims from the context.
 func GetClaims(ctx context.Context) (Claims, bool) {
 	claims, ok := ctx.Value(claimsKey).(Claims)
@@ -77,3 +115,17 @@ func Permissions(ctx context.Context) ([]string, bool) {
 	}
 	return claims.Permissions, true
 }
+
+// WithCustomClaims returns a new context with application-specific claims set
+// (see jwtauth.WithCustomClaims). They are stored apart from Claims, so they do
+// not end up wherever Claims is copied, such as error reports and logs.
+func WithCustomClaims(ctx context.Context, custom any) context.Context {
+	return context.WithValue(ctx, customClaimsKey, custom)
+}
+
+// CustomClaims returns the typed custom claims from the context.
+// Returns false if there are no custom claims or they are not of type T.
+func CustomClaims[T any](ctx context.Context) (T, bool) {
+	custom, ok := ctx.Value(customClaimsKey).(T)
+	return custom, ok
+}
@@ -180,3 +180,69 @@ func TestIndividualClaimAccessorsEmptyContext(t *testing.T) {
 		t.Errorf("Permissions() on empty context = %v, %v, want nil, false", perms, ok)
 	}
 }
+
+func TestCustomClaims(t *testing.T) {
+	t.Parallel()
+
+	type orgClaims struct {
+		OrgID string
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   orgClaims
+		wantOK bool
+	}{
+		{
+			name:   "matching type",
+			ctx:    WithCustomClaims(context.Background(), orgClaims{OrgID: "org-1"}),
+			want:   orgClaims{OrgID: "org-1"},
+			wantOK: true,
+		},
+		{
+			name:   "other type",
+			ctx:    WithCustomClaims(context.Background(), "org-1"),
+			wantOK: false,
+		},
+		{
+			name:   "claims without custom claims",
+			ctx:    WithClaims(context.Background(), Claims{UserID: "user-123"}),
+			wantOK: false,
+		},
+		{
+			name:   "empty context",
+			ctx:    context.Background(),
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := CustomClaims[orgClaims](tt.ctx)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("CustomClaims() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestWithClaimsRecorder(t *testing.T) {
+	t.Parallel()
+
+	ctx, recorded := WithClaimsRecorder(context.Background())
+	if _, ok := recorded(); ok {
+		t.Fatal("recorded() ok = true before WithClaims")
+	}
+
+	_ = WithClaims(WithRequestID(ctx, "req-123"), Claims{UserID: "user-123", TenantID: "tenant-456"})
+
+	got, ok := recorded()
+	if !ok || got.UserID != "user-123" || got.TenantID != "tenant-456" {
+		t.Errorf("recorded() = %+v, %v, want user-123/tenant-456, true", got, ok)
+	}
+	if _, ok := GetClaims(ctx); ok {
+		t.Error("GetClaims() ok = true on the recorder context")
+	}
+}
@@ -1,6 +1,136 @@
 package postgres
 
-import "errors"
+import (
+	"errors"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
 
 // ErrDSNRequired is returned when DSN is empty in Config.
 var ErrDSNRequired = errors.New("dsn is required")
+
+// Kinds of database errors mapped by MapError.
+// Use errors.Is to check for them; errors.As with *Error gives the constraint.
+var (
+	// ErrNotFound is returned when a query expected a row but found none.
+	ErrNotFound = errors.New("not found")
+
+	// ErrUniqueViolation is returned when a unique constraint is violated.
+	ErrUniqueViolation = errors.New("already exists")
+
+	// ErrForeignKeyViolation is returned when a foreign key constraint is violated.
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+
+	// ErrSerializationFailure is returned when a transaction could not be
+	// serialized with concurrent transactions and should be retried.
+	ErrSerializationFailure = errors.New("serialization failure")
+
+	// ErrQueryCanceled is returned when a statement was canceled, e.g. by statement_timeout.
+	ErrQueryCanceled = errors.New("query canceled")
+)
+
+// PostgreSQL error codes (SQLSTATE) mapped by MapError.
+const (
+	codeUniqueViolation      = "23505"
+	codeForeignKeyViolation  = "23503"
+	codeSerializationFailure = "40001"
+	codeQueryCanceled        = "57014"
+)
+
+// Error is a database error mapped to a Connect code.
+// It implements errors.ConnectCoder and errors.PublicMessager of
+// pkg/connectrpc/errors, so the errors interceptor returns it with that code
+// instead of a sanitized CodeInternal.
+//
+// Error() includes the original error for logs; clients only receive the
+// kind of error, so that no SQL or schema details reach them. The original
+// error, usually a *pgconn.PgError, stays available through errors.As.
+type Error struct {
+	// Constraint is the name of the violated constraint, e.g. "users_email_key".
+	// Handlers can map it to a field-level message.
+	Constraint string
+
+	// Table is the table of the violated constraint.
+	Table string
+
+	// Column is the column of the violated constraint, if the server reports one.
+	Column string
+
+	kind error
+	code connect.Code
+	err  error
+}
+
+// Error returns the message of the error kind followed by the original error,
+// e.g. "already exists: ERROR: duplicate key value ... (SQLSTATE 23505)".
+func (e *Error) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+// PublicMessage returns the message of the error kind, e.g. "already exists".
+func (e *Error) PublicMessage() string {
+	return e.kind.Error()
+}
+
+// Unwrap returns the error kind and the original error.
+func (e *Error) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// ConnectCode returns the Connect code for the error kind.
+func (e *Error) ConnectCode() connect.Code {
+	return e.code
+}
+
+// MapError wraps database errors in an *Error:
+//
+//   - pgx.ErrNoRows → ErrNotFound (CodeNotFound)
+//   - unique_violation → ErrUniqueViolation (CodeAlreadyExists)
+//   - foreign_key_violation → ErrForeignKeyViolation (CodeFailedPrecondition)
+//   - serialization_failure → ErrSerializationFailure (CodeAborted)
+//   - query_canceled → ErrQueryCanceled (CodeDeadlineExceeded)
+//
+// Other errors, including nil, are returned unchanged. WithTx and
+// PgUnitOfWork apply MapError to their errors; call it on errors of queries
+// run directly on a pool.
+func MapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var mapped *Error
+	if errors.As(err, &mapped) {
+		return err
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &Error{kind: ErrNotFound, code: connect.CodeNotFound, err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+
+	mapped = &Error{
+		Constraint: pgErr.ConstraintName,
+		Table:      pgErr.TableName,
+		Column:     pgErr.ColumnName,
+		err:        err,
+	}
+	switch pgErr.Code {
+	case codeUniqueViolation:
+		mapped.kind, mapped.code = ErrUniqueViolation, connect.CodeAlreadyExists
+	case codeForeignKeyViolation:
+		mapped.kind, mapped.code = ErrForeignKeyViolation, connect.CodeFailedPrecondition
+	case codeSerializationFailure:
+		mapped.kind, mapped.code = ErrSerializationFailure, connect.CodeAborted
+	case codeQueryCanceled:
+		mapped.kind, mapped.code = ErrQueryCanceled, connect.CodeDeadlineExceeded
+	default:
+		return err
+	}
+	return mapped
+}
@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	connecterrors "github.com/deepworx/go-utils/pkg/connectrpc/errors"
+)
+
+func TestMapError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		err            error
+		wantKind       error
+		wantCode       connect.Code
+		wantConstraint string
+	}{
+		{
+			name:     "no rows",
+			err:      fmt.Errorf("get user: %w", pgx.ErrNoRows),
+			wantKind: ErrNotFound,
+			wantCode: connect.CodeNotFound,
+		},
+		{
+			name:           "unique violation",
+			err:            &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key", TableName: "users"},
+			wantKind:       ErrUniqueViolation,
+			wantCode:       connect.CodeAlreadyExists,
+			wantConstraint: "users_email_key",
+		},
+		{
+			name:           "foreign key violation",
+			err:            fmt.Errorf("insert order: %w", &pgconn.PgError{Code: "23503", ConstraintName: "orders_user_id_fkey"}),
+			wantKind:       ErrForeignKeyViolation,
+			wantCode:       connect.CodeFailedPrecondition,
+			wantConstraint: "orders_user_id_fkey",
+		},
+		{
+			name:     "serialization failure",
+			err:      &pgconn.PgError{Code: "40001"},
+			wantKind: ErrSerializationFailure,
+			wantCode: connect.CodeAborted,
+		},
+		{
+			name:     "query canceled",
+			err:      &pgconn.PgError{Code: "57014"},
+			wantKind: ErrQueryCanceled,
+			wantCode: connect.CodeDeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := MapError(tt.err)
+
+			if !errors.Is(err, tt.wantKind) {
+				t.Errorf("errors.Is(err, %v) = false", tt.wantKind)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Error("original error is not in the chain")
+			}
+			var mapped *Error
+			if !errors.As(err, &mapped) {
+				t.Fatalf("MapError() = %T, want *Error", err)
+			}
+			if mapped.ConnectCode() != tt.wantCode {
+				t.Errorf("ConnectCode() = %v, want %v", mapped.ConnectCode(), tt.wantCode)
+			}
+			if mapped.Constraint != tt.wantConstraint {
+				t.Errorf("Constraint = %q, want %q", mapped.Constraint, tt.wantConstraint)
+			}
+			if want := tt.wantKind.Error() + ": " + tt.err.Error(); err.Error() != want {
+				t.Errorf("Error() = %q, want %q", err.Error(), want)
+			}
+			if mapped.PublicMessage() != tt.wantKind.Error() {
+				t.Errorf("PublicMessage() = %q, want %q", mapped.PublicMessage(), tt.wantKind.Error())
+			}
+		})
+	}
+}
+
+func TestMapError_Unmapped(t *testing.T) {
+	t.Parallel()
+
+	plain := errors.New("connection refused")
+	checkViolation := &pgconn.PgError{Code: "23514"}
+	mapped := MapError(pgx.ErrNoRows)
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "nil"},
+		{name: "plain error", err: plain},
+		{name: "other sqlstate", err: checkViolation},
+		{name: "already mapped", err: mapped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := MapError(tt.err); got != tt.err {
+				t.Errorf("MapError() = %v, want %v unchanged", got, tt.err)
+			}
+		})
+	}
+}
+
+// compile-time check
+var (
+	_ connecterrors.ConnectCoder   = (*Error)(nil)
+	_ connecterrors.PublicMessager = (*Error)(nil)
+)
@@ -7,6 +7,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/deepworx/go-utils/pkg/shutdown"
@@ -101,6 +102,7 @@ func Ping(ctx context.Context, pool *pgxpool.Pool) error {
 // WithTx executes fn within a database transaction.
 // The transaction is committed if fn returns nil; rolled back otherwise.
 // Panics within fn cause rollback and re-panic.
+// Errors of fn and of the commit are mapped with MapError.
 func WithTx(ctx context.Context, pool *pgxpool.Pool, fn func(tx pgx.Tx) error) error {
 	tx, err := pool.Begin(ctx)
 	if err != nil {
@@ -118,16 +120,22 @@ func WithTx(ctx context.Context, pool *pgxpool.Pool, fn func(tx pgx.Tx) error) e
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
 			return fmt.Errorf("rollback transaction: %w (original: %v)", rbErr, err)
 		}
-		return err
+		return MapError(err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("commit transaction: %w", err)
+		return fmt.Errorf("commit transaction: %w", MapError(err))
 	}
 
 	return nil
 }
 
+// QuoteTable quotes a table name for use in SQL. A schema-qualified name such
+// as "auth.api_keys" is split on "." and each part is quoted separately.
+func QuoteTable(name string) string {
+	return pgx.Identifier(strings.Split(name, ".")).Sanitize()
+}
+
 func applyDefaults(poolCfg *pgxpool.Config, cfg Config) {
 	if cfg.MaxConns > 0 {
 		poolCfg.MaxConns = cfg.MaxConns
@@ -148,3 +148,26 @@ func TestWithTx_PanicRecovery(t *testing.T) {
 	// The actual panic behavior would be tested in integration tests.
 	// Here we just verify the function signature and error handling pattern.
 }
+
+func TestQuoteTable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		table string
+		want  string
+	}{
+		{name: "bare name", table: "api_keys", want: `"api_keys"`},
+		{name: "schema-qualified", table: "auth.api_keys", want: `"auth"."api_keys"`},
+		{name: "quotes escaped", table: `odd"name`, want: `"odd""name"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := QuoteTable(tt.table); got != tt.want {
+				t.Errorf("QuoteTable(%q) = %s, want %s", tt.table, got, tt.want)
+			}
+		})
+	}
+}
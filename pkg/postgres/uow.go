@@ -30,6 +30,7 @@ func NewUnitOfWork(pool *pgxpool.Pool) *PgUnitOfWork {
 
 // Execute runs fn within a transaction.
 // Commits on success, rolls back on error or panic.
+// Database errors are mapped with MapError.
 func (u *PgUnitOfWork) Execute(ctx context.Context, fn func(ctx context.Context, tx Transaction) error) error {
 	return WithTx(ctx, u.pool, func(tx pgx.Tx) error {
 		return fn(ctx, &pgTransaction{tx: tx})